@@ -0,0 +1,184 @@
+// Command mlmock serves a deterministic stand-in for the machine learning
+// service so the backend can be run and exercised locally. Point ML_BASE_URL
+// at it, e.g. ML_BASE_URL=http://localhost:8090.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const mockModelVersion = "mock-1"
+
+var faceShapes = []string{"circle", "heart", "oblong", "oval", "square", "triangle"}
+
+type mock struct {
+	apiKey   string
+	latency  time.Duration
+	failRate float64
+	products int
+}
+
+func main() {
+	m := mock{}
+	addr := flag.String("addr", ":8090", "address to listen on")
+	flag.StringVar(&m.apiKey, "api-key", "", "reject requests without this X-API-KEY when set")
+	flag.DurationVar(&m.latency, "latency", 0, "delay added to every response")
+	flag.Float64Var(&m.failRate, "fail-rate", 0, "fraction of requests answered with 503, between 0 and 1")
+	flag.IntVar(&m.products, "products", 50, "product ids (1..n) used for recommendations and search")
+	flag.Parse()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/face_shape", m.handle(http.MethodPost, m.faceShape))
+	mux.HandleFunc("/is_human", m.handle(http.MethodPost, m.isHuman))
+	mux.HandleFunc("/detect_personality", m.handle(http.MethodPost, m.detectPersonality))
+	mux.HandleFunc("/product_recommendation", m.handle(http.MethodGet, m.productRecommendation))
+	mux.HandleFunc("/product_search", m.handle(http.MethodGet, m.productSearch))
+
+	log.Printf("mock machine learning service listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+func (m mock) handle(method string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if m.latency > 0 {
+			time.Sleep(m.latency)
+		}
+		if r.Method != method {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"message": "method not allowed"})
+			return
+		}
+		if m.apiKey != "" && r.Header.Get("X-API-KEY") != m.apiKey {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "invalid api key"})
+			return
+		}
+		if m.failRate > 0 && rand.Float64() < m.failRate {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"message": "simulated failure"})
+			return
+		}
+
+		w.Header().Set("X-Model-Version", mockModelVersion)
+		next(w, r)
+	}
+}
+
+// decodeImage reads the {"image": "<base64>"} payload shared by the image endpoints.
+func decodeImage(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	var payload struct {
+		Image string `json:"image"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Image == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "image is required"})
+		return nil, false
+	}
+
+	image, err := base64.StdEncoding.DecodeString(payload.Image)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "image is not valid base64"})
+		return nil, false
+	}
+
+	return image, true
+}
+
+// the same image always yields the same shape
+func (m mock) faceShape(w http.ResponseWriter, r *http.Request) {
+	image, ok := decodeImage(w, r)
+	if !ok {
+		return
+	}
+
+	sum := sha256.Sum256(image)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"is_human":      true,
+		"shape":         faceShapes[int(sum[0])%len(faceShapes)],
+		"confidence":    0.5 + float64(sum[1])/510,
+		"model_version": mockModelVersion,
+	})
+}
+
+func (m mock) isHuman(w http.ResponseWriter, r *http.Request) {
+	if _, ok := decodeImage(w, r); !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"result": true, "face_count": 1})
+}
+
+// each trait is the mean of its ten 1-5 answers scaled to a percentage
+func (m mock) detectPersonality(w http.ResponseWriter, r *http.Request) {
+	var answers map[string]int
+	if err := json.NewDecoder(r.Body).Decode(&answers); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "invalid answers"})
+		return
+	}
+
+	traits := map[string]string{
+		"AGR": "percentage_of_agreeable",
+		"CSN": "percentage_of_conscientious",
+		"EXT": "percentage_of_extraversion",
+		"EST": "percentage_of_neurotic",
+		"OPN": "percentage_of_openess",
+	}
+
+	res := map[string]interface{}{"model_version": mockModelVersion}
+	for prefix, field := range traits {
+		total := 0
+		for i := 1; i <= 10; i++ {
+			total += answers[prefix+strconv.Itoa(i)]
+		}
+		res[field] = float64(total) / 50 * 100
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+// every product is recommended the five ids that follow it
+func (m mock) productRecommendation(w http.ResponseWriter, r *http.Request) {
+	res := map[string][]string{}
+	for id := 1; id <= m.products; id++ {
+		var ids []string
+		for offset := 1; offset <= 5 && offset < m.products; offset++ {
+			ids = append(ids, strconv.Itoa((id+offset-1)%m.products+1))
+		}
+		res[strconv.Itoa(id)] = ids
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+// a query matches up to five ids derived from its text
+func (m mock) productSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(strings.ToLower(r.URL.Query().Get("query")))
+	res := []map[string]string{}
+	if query != "" && m.products > 0 {
+		sum := sha256.Sum256([]byte(query))
+		seen := map[int]bool{}
+		for i := 0; i < 5; i++ {
+			id := int(sum[i])%m.products + 1
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			res = append(res, map[string]string{"id": strconv.Itoa(id)})
+		}
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Println("failed to write response:", err)
+	}
+}
@@ -0,0 +1,10 @@
+package constant
+
+const (
+	AssetKindProduct  = "product"
+	AssetKindBrand    = "brand"
+	AssetKindAvatar   = "avatar"
+	AssetKindFaceScan = "face_scan"
+
+	AssetKindMarketplace = "marketplace"
+)
@@ -1,6 +1,14 @@
 package constant
 
+// Recommendation sources a category can be configured with.
 const (
-	GlassesCategoryID = 1
-	MakeupCategoryID  = 2
+	RecommendationFaceShape   = "face_shape"
+	RecommendationPersonality = "personality"
+)
+
+const (
+	AttributeTypeText    = "text"
+	AttributeTypeNumber  = "number"
+	AttributeTypeBoolean = "boolean"
+	AttributeTypeSelect  = "select"
 )
@@ -1,8 +1,12 @@
 package constant
 
-var Marketplace = map[uint64]string{
-	1: "Website",
-	2: "Tokopedia",
-	3: "Shopee",
-	4: "Bukalapak",
-}
+// TrackingParams are query parameters dropped from marketplace listing links;
+// any parameter starting with one of TrackingParamPrefixes is dropped as well.
+var (
+	TrackingParams = []string{
+		"fbclid", "gclid", "dclid", "msclkid", "igshid", "mc_cid", "mc_eid",
+		"ref", "referrer", "src", "source", "extParam", "trkid", "whid",
+		"sp_atk", "xptdk", "af_click_lookback", "is_from_login",
+	}
+	TrackingParamPrefixes = []string{"utm_", "af_", "smtt", "_branch"}
+)
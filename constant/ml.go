@@ -0,0 +1,20 @@
+package constant
+
+// ML result types used by the feedback export and the model version report.
+const (
+	MLResultFaceShape   = "face_shape"
+	MLResultPersonality = "personality"
+
+	// MLModelVersionUnknown groups results stored before versions were tracked.
+	MLModelVersionUnknown = "unknown"
+
+	// where a personality result was computed
+	PersonalitySourceML    = "ml"
+	PersonalitySourceLocal = "local"
+
+	// PERSONALITY_SCORING modes
+	PersonalityScoringFallback = "fallback" // ML, local scorer when the ML service is down
+	PersonalityScoringML       = "ml"       // ML only
+	PersonalityScoringLocal    = "local"    // local scorer only
+	PersonalityScoringCompare  = "compare"  // ML, logging how far the local scorer differs
+)
@@ -0,0 +1,6 @@
+package constant
+
+const (
+	NotificationTypePriceDrop       = "price_drop"
+	NotificationTypeFaceShapeResult = "face_shape_result"
+)
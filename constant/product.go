@@ -7,3 +7,26 @@ const (
 	StatusWaitingPayment = "PAYMENT REVIEW"
 	StatusSubscribed     = "SUBSCRIBED"
 )
+
+const (
+	OfferSortPrice     = "price"
+	OfferSortPriceDesc = "price_desc"
+	OfferSortDistance  = "distance"
+)
+
+// similar product score weights, summing to 1
+const (
+	SimilarWeightTags      = 0.35
+	SimilarWeightCategory  = 0.2
+	SimilarWeightBrand     = 0.1
+	SimilarWeightPrice     = 0.1
+	SimilarWeightBehaviour = 0.25
+)
+
+const (
+	SimilarReasonSharedTags   = "shared_tags"
+	SimilarReasonSameCategory = "same_category"
+	SimilarReasonSameBrand    = "same_brand"
+	SimilarReasonSimilarPrice = "similar_price"
+	SimilarReasonAlsoLiked    = "also_liked"
+)
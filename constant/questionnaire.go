@@ -0,0 +1,16 @@
+package constant
+
+// Languages the personality questionnaire is written in.
+const (
+	QuestionnaireLanguageIndonesian = "id"
+	QuestionnaireLanguageEnglish    = "en"
+
+	// QuestionnaireDefaultLanguage is required for every question and used
+	// when a question is not translated to the requested language.
+	QuestionnaireDefaultLanguage = QuestionnaireLanguageIndonesian
+)
+
+var QuestionnaireLanguages = []string{
+	QuestionnaireLanguageIndonesian,
+	QuestionnaireLanguageEnglish,
+}
@@ -0,0 +1,7 @@
+package constant
+
+const (
+	StockStatusInStock    = "in_stock"
+	StockStatusLow        = "low"
+	StockStatusOutOfStock = "out_of_stock"
+)
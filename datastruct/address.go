@@ -10,6 +10,8 @@ type Address struct {
 	DistrictID    uint64    `gorm:"column:district_id" json:"district_id"`
 	SubdistrictID uint64    `gorm:"column:subdistrict_id" json:"subdistrict_id"`
 	PostalCodeID  uint64    `gorm:"column:postal_code_id" json:"postal_code_id"`
+	Latitude      *float64  `gorm:"column:latitude" json:"latitude"`
+	Longitude     *float64  `gorm:"column:longitude" json:"longitude"`
 	CreatedAt     time.Time `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt     time.Time `gorm:"column:updated_at" json:"updated_at"`
 }
@@ -0,0 +1,34 @@
+package datastruct
+
+import "time"
+
+type (
+	Asset struct {
+		ID           uint64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+		ObjectName   string     `gorm:"column:object_name" json:"object_name"`
+		URL          string     `gorm:"column:url" json:"url"`
+		Kind         string     `gorm:"column:kind" json:"kind"`
+		OwnerID      uint64     `gorm:"column:owner_id" json:"owner_id"`
+		Size         int64      `gorm:"column:size" json:"size"`
+		ReferencedAt *time.Time `gorm:"column:referenced_at" json:"referenced_at"`
+		DeletedAt    *time.Time `gorm:"column:deleted_at" json:"deleted_at"`
+		CreatedAt    time.Time  `gorm:"column:created_at" json:"created_at"`
+		UpdatedAt    time.Time  `gorm:"column:updated_at" json:"updated_at"`
+	}
+
+	AssetSweepReport struct {
+		DryRun           bool     `json:"dry_run"`
+		GracePeriodHours int      `json:"grace_period_hours"`
+		Scanned          int      `json:"scanned"`
+		Referenced       int      `json:"referenced"`
+		Registered       int      `json:"registered"`
+		Deleted          int      `json:"deleted"`
+		ReclaimedBytes   int64    `json:"reclaimed_bytes"`
+		DeletedObjects   []string `json:"deleted_objects"`
+		Errors           []string `json:"errors"`
+	}
+)
+
+func (Asset) TableName() string {
+	return "assets"
+}
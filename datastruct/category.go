@@ -2,13 +2,59 @@ package datastruct
 
 import "time"
 
-type Category struct {
-	ID        uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
-	Name      string    `gorm:"column:name" json:"name"`
-	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
-	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`
-}
+type (
+	Category struct {
+		ID                   uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+		Name                 string    `gorm:"column:name" json:"name"`
+		ParentID             uint64    `gorm:"column:parent_id" json:"parent_id"`
+		RecommendationSource string    `gorm:"column:recommendation_source" json:"recommendation_source"`
+		CreatedAt            time.Time `gorm:"column:created_at" json:"created_at"`
+		UpdatedAt            time.Time `gorm:"column:updated_at" json:"updated_at"`
+	}
+
+	CategoryAttribute struct {
+		ID         uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+		CategoryID uint64    `gorm:"column:category_id" json:"category_id"`
+		Code       string    `gorm:"column:code" json:"code"`
+		Name       string    `gorm:"column:name" json:"name"`
+		Type       string    `gorm:"column:type" json:"type"`
+		Options    string    `gorm:"column:options" json:"-"`
+		OptionList []string  `gorm:"-" json:"options"`
+		Unit       string    `gorm:"column:unit" json:"unit"`
+		IsRequired bool      `gorm:"column:is_required" json:"is_required"`
+		CreatedAt  time.Time `gorm:"column:created_at" json:"created_at"`
+		UpdatedAt  time.Time `gorm:"column:updated_at" json:"updated_at"`
+	}
+
+	CategoryInput struct {
+		Name                 string `json:"name" validate:"required"`
+		ParentID             uint64 `json:"parent_id"`
+		RecommendationSource string `json:"recommendation_source"`
+	}
+
+	CategoryAttributeInput struct {
+		Code       string   `json:"code" validate:"required"`
+		Name       string   `json:"name" validate:"required"`
+		Type       string   `json:"type" validate:"required"`
+		Options    []string `json:"options"`
+		Unit       string   `json:"unit"`
+		IsRequired bool     `json:"is_required"`
+	}
+
+	CategoryTree struct {
+		ID                   uint64              `json:"id"`
+		Name                 string              `json:"name"`
+		ParentID             uint64              `json:"parent_id"`
+		RecommendationSource string              `json:"recommendation_source"`
+		Attributes           []CategoryAttribute `json:"attributes"`
+		Children             []CategoryTree      `json:"children"`
+	}
+)
 
 func (Category) TableName() string {
 	return "categories"
 }
+
+func (CategoryAttribute) TableName() string {
+	return "category_attributes"
+}
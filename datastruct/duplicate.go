@@ -0,0 +1,38 @@
+package datastruct
+
+import "time"
+
+type (
+	ProductImageHash struct {
+		ID        uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+		ProductID uint64    `gorm:"column:product_id" json:"product_id"`
+		ImageURL  string    `gorm:"column:image_url" json:"image_url"`
+		Hash      uint64    `gorm:"column:hash" json:"hash"`
+		CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+		UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`
+	}
+
+	// ProductImageHashBackfillReport covers one batch of products hashed by the
+	// backfill; pass LastProductID as after_id to continue.
+	ProductImageHashBackfillReport struct {
+		Products      int    `json:"products"`
+		Hashed        int    `json:"hashed"`
+		Skipped       int    `json:"skipped"`
+		Failed        int    `json:"failed"`
+		LastProductID uint64 `json:"last_product_id"`
+		Remaining     int64  `json:"remaining"`
+	}
+
+	DuplicateProduct struct {
+		ID              uint64  `gorm:"column:id" json:"id"`
+		Name            string  `gorm:"column:name" json:"name"`
+		Image           string  `gorm:"column:images" json:"image"`
+		Confidence      float64 `gorm:"-" json:"confidence"`
+		NameSimilarity  float64 `gorm:"-" json:"name_similarity"`
+		ImageSimilarity float64 `gorm:"-" json:"image_similarity"`
+	}
+)
+
+func (ProductImageHash) TableName() string {
+	return "product_image_hashes"
+}
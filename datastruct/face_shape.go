@@ -12,7 +12,6 @@ type (
 
 	DetailFaceShapeTag struct {
 		ID          uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
-		Name        string    `gorm:"column:name" json:"name"`
 		FaceShapeID uint64    `gorm:"column:face_shape_id" json:"face_shape_id"`
 		TagID       uint64    `gorm:"column:tag_id" json:"tag_id"`
 		CreatedAt   time.Time `gorm:"column:created_at" json:"created_at"`
@@ -24,17 +23,59 @@ type (
 	}
 
 	IsHumanRes struct {
-		Result bool `json:"result"`
+		Result    bool `json:"result"`
+		FaceCount *int `json:"face_count"`
 	}
 
 	FaceTestRes struct {
-		IsHuman bool   `json:"is_human"`
-		Shape   string `json:"shape"`
+		IsHuman      bool     `json:"is_human"`
+		Shape        string   `json:"shape"`
+		Confidence   *float64 `json:"confidence"`
+		ModelVersion string   `json:"model_version"`
+	}
+
+	FaceScan struct {
+		ID           uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+		UserID       uint64    `gorm:"column:user_id" json:"-"`
+		ImageUrl     *string   `gorm:"column:image_url" json:"image_url"`
+		FaceShapeID  uint64    `gorm:"column:face_shape_id" json:"face_shape_id"`
+		FaceShape    string    `gorm:"->;column:face_shape" json:"face_shape"`
+		Confidence   *float64  `gorm:"column:confidence" json:"confidence"`
+		ModelVersion *string   `gorm:"column:model_version" json:"model_version"`
+		IsActive     bool      `gorm:"column:is_active" json:"is_active"`
+		CreatedAt    time.Time `gorm:"column:created_at" json:"created_at"`
+		UpdatedAt    time.Time `gorm:"column:updated_at" json:"updated_at"`
+	}
+
+	FaceShapeJob struct {
+		ID            uint64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+		UserID        uint64     `gorm:"column:user_id" json:"user_id"`
+		Status        string     `gorm:"column:status" json:"status"`
+		ImageUrl      string     `gorm:"column:image_url" json:"image_url"`
+		ObjectName    string     `gorm:"column:object_name" json:"-"`
+		Attempts      int        `gorm:"column:attempts" json:"attempts"`
+		Result        *string    `gorm:"column:result" json:"result"`
+		FaceShapeID   *uint64    `gorm:"column:face_shape_id" json:"face_shape_id"`
+		FaceScanID    *uint64    `gorm:"column:face_scan_id" json:"face_scan_id"`
+		ErrorMessage  *string    `gorm:"column:error_message" json:"error_message"`
+		NextAttemptAt *time.Time `gorm:"column:next_attempt_at" json:"-"`
+		StartedAt     *time.Time `gorm:"column:started_at" json:"started_at"`
+		CompletedAt   *time.Time `gorm:"column:completed_at" json:"completed_at"`
+		CreatedAt     time.Time  `gorm:"column:created_at" json:"created_at"`
+		UpdatedAt     time.Time  `gorm:"column:updated_at" json:"updated_at"`
 	}
 )
 
 func (FaceShape) TableName() string {
-	return "face_shape"
+	return "face_shapes"
+}
+
+func (FaceScan) TableName() string {
+	return "face_scans"
+}
+
+func (FaceShapeJob) TableName() string {
+	return "face_shape_jobs"
 }
 
 func (DetailFaceShapeTag) TableName() string {
@@ -0,0 +1,74 @@
+package datastruct
+
+import "time"
+
+type (
+	FaceScanFeedback struct {
+		ID                   uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+		FaceScanID           uint64    `gorm:"column:face_scan_id" json:"face_scan_id"`
+		UserID               uint64    `gorm:"column:user_id" json:"-"`
+		IsCorrect            bool      `gorm:"column:is_correct" json:"is_correct"`
+		CorrectedFaceShapeID *uint64   `gorm:"column:corrected_face_shape_id" json:"corrected_face_shape_id"`
+		Comment              *string   `gorm:"column:comment" json:"comment"`
+		CreatedAt            time.Time `gorm:"column:created_at" json:"created_at"`
+		UpdatedAt            time.Time `gorm:"column:updated_at" json:"updated_at"`
+	}
+
+	PersonalityFeedback struct {
+		ID                uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+		UserPersonalityID uint64    `gorm:"column:user_personality_id" json:"user_personality_id"`
+		UserID            uint64    `gorm:"column:user_id" json:"-"`
+		Rating            int       `gorm:"column:rating" json:"rating"`
+		Comment           *string   `gorm:"column:comment" json:"comment"`
+		CreatedAt         time.Time `gorm:"column:created_at" json:"created_at"`
+		UpdatedAt         time.Time `gorm:"column:updated_at" json:"updated_at"`
+	}
+
+	FaceScanFeedbackInput struct {
+		IsCorrect *bool `json:"is_correct" validate:"required"`
+		// FaceShape is the shape the user says is right, required when IsCorrect is false
+		FaceShape string `json:"face_shape"`
+		Comment   string `json:"comment" validate:"max=255"`
+	}
+
+	PersonalityFeedbackInput struct {
+		Rating  int    `json:"rating" validate:"required,min=1,max=5"`
+		Comment string `json:"comment" validate:"max=255"`
+	}
+
+	// FaceShapeTrainingSample is one exported line of labelled face scans.
+	FaceShapeTrainingSample struct {
+		FaceScanID     uint64    `gorm:"column:face_scan_id" json:"face_scan_id"`
+		ImageUrl       string    `gorm:"column:image_url" json:"image_url"`
+		PredictedShape string    `gorm:"column:predicted_shape" json:"predicted_shape"`
+		LabelShape     string    `gorm:"column:label_shape" json:"label_shape"`
+		IsCorrect      bool      `gorm:"column:is_correct" json:"is_correct"`
+		Confidence     *float64  `gorm:"column:confidence" json:"confidence"`
+		ModelVersion   *string   `gorm:"column:model_version" json:"model_version"`
+		Comment        *string   `gorm:"column:comment" json:"comment"`
+		ScannedAt      time.Time `gorm:"column:scanned_at" json:"scanned_at"`
+		LabelledAt     time.Time `gorm:"column:labelled_at" json:"labelled_at"`
+	}
+
+	// PersonalityTrainingSample is one exported line of rated questionnaire results.
+	PersonalityTrainingSample struct {
+		UserPersonalityID      uint64                 `json:"user_personality_id"`
+		QuestionnaireVersionID uint64                 `json:"questionnaire_version_id"`
+		Answers                map[string]int         `json:"answers"`
+		Predicted              PersonalityPercentages `json:"predicted"`
+		Rating                 int                    `json:"rating"`
+		Comment                *string                `json:"comment"`
+		ModelVersion           *string                `json:"model_version"`
+		Source                 string                 `json:"source"`
+		AnsweredAt             time.Time              `json:"answered_at"`
+		LabelledAt             time.Time              `json:"labelled_at"`
+	}
+)
+
+func (FaceScanFeedback) TableName() string {
+	return "face_scan_feedbacks"
+}
+
+func (PersonalityFeedback) TableName() string {
+	return "personality_feedbacks"
+}
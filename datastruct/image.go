@@ -0,0 +1,10 @@
+package datastruct
+
+type ImageRenditions struct {
+	Thumbnail     string `json:"thumbnail"`
+	List          string `json:"list"`
+	Detail        string `json:"detail"`
+	ThumbnailWebP string `json:"thumbnail_webp,omitempty"`
+	ListWebP      string `json:"list_webp,omitempty"`
+	DetailWebP    string `json:"detail_webp,omitempty"`
+}
@@ -1,22 +1,52 @@
 package datastruct
 
-import "time"
+import (
+	"mime/multipart"
+	"time"
+)
 
-type Marketplace struct {
-	ID        uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
-	Name      string    `gorm:"column:name" json:"name"`
-	Image     string    `gorm:"column:image" json:"image"`
-	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
-	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`
-}
+type (
+	Marketplace struct {
+		ID                uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+		Name              string    `gorm:"column:name" json:"name"`
+		Image             string    `gorm:"column:image" json:"image"`
+		WebsiteURL        string    `gorm:"column:website_url" json:"website_url"`
+		HostPatterns      string    `gorm:"column:host_patterns" json:"host_patterns"`
+		DeepLinkTemplate  string    `gorm:"column:deep_link_template" json:"deep_link_template"`
+		ShortLinkHosts    string    `gorm:"column:short_link_hosts" json:"short_link_hosts"`
+		IdentifierPattern string    `gorm:"column:identifier_pattern" json:"identifier_pattern"`
+		IsActive          bool      `gorm:"column:is_active" json:"is_active"`
+		CreatedAt         time.Time `gorm:"column:created_at" json:"created_at"`
+		UpdatedAt         time.Time `gorm:"column:updated_at" json:"updated_at"`
+	}
 
-type MerchantMarketplace struct {
-	Name      string  `gorm:"column:name" json:"name"`
-	Clicked   uint64  `gorm:"column:clicked" json:"clicked"`
-	Link      *string `gorm:"column:link" json:"link"`
-	Address   *string `gorm:"column:address" json:"address"`
-	AddressID uint64  `gorm:"column:addresses_id" json:"-"`
-}
+	MarketplaceInput struct {
+		Name              string                `form:"name" json:"name" validate:"required"`
+		Image             *multipart.FileHeader `form:"image" json:"-"`
+		WebsiteURL        string                `form:"website_url" json:"website_url"`
+		HostPatterns      string                `form:"host_patterns" json:"host_patterns"`
+		DeepLinkTemplate  string                `form:"deep_link_template" json:"deep_link_template"`
+		ShortLinkHosts    string                `form:"short_link_hosts" json:"short_link_hosts"`
+		IdentifierPattern string                `form:"identifier_pattern" json:"identifier_pattern"`
+		IsActive          *bool                 `form:"is_active" json:"is_active"`
+	}
+
+	// MarketplaceLink is a listing link after short link resolution and
+	// tracking parameter removal, with the identifiers taken from its path.
+	MarketplaceLink struct {
+		Link              string
+		ShopIdentifier    string
+		ProductIdentifier string
+	}
+
+	MerchantMarketplace struct {
+		Name      string  `gorm:"column:name" json:"name"`
+		Clicked   uint64  `gorm:"column:clicked" json:"clicked"`
+		Link      *string `gorm:"column:link" json:"link"`
+		Address   *string `gorm:"column:address" json:"address"`
+		AddressID uint64  `gorm:"column:addresses_id" json:"-"`
+	}
+)
 
 func (Marketplace) TableName() string {
 	return "marketplaces"
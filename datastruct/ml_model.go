@@ -0,0 +1,27 @@
+package datastruct
+
+import "time"
+
+type (
+	// ModelVersionReport summarises the stored results produced by one model version.
+	ModelVersionReport struct {
+		ModelVersion  string    `gorm:"column:model_version" json:"model_version"`
+		Results       int       `gorm:"column:results" json:"results"`
+		Users         int       `gorm:"column:users" json:"users"`
+		ActiveResults int       `gorm:"column:active_results" json:"active_results"`
+		Feedbacks     int       `gorm:"column:feedbacks" json:"feedbacks"`
+		Corrections   *int      `gorm:"column:corrections" json:"corrections,omitempty"`
+		AverageRating *float64  `gorm:"column:average_rating" json:"average_rating,omitempty"`
+		FirstSeen     time.Time `gorm:"column:first_seen" json:"first_seen"`
+		LastSeen      time.Time `gorm:"column:last_seen" json:"last_seen"`
+	}
+
+	// ModelAffectedUser is a user whose current result came from a given model version.
+	ModelAffectedUser struct {
+		UserID    uint64    `gorm:"column:user_id" json:"user_id"`
+		Email     string    `gorm:"column:email" json:"email"`
+		FullName  string    `gorm:"column:full_name" json:"full_name"`
+		ResultID  uint64    `gorm:"column:result_id" json:"result_id"`
+		CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+	}
+)
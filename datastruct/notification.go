@@ -0,0 +1,19 @@
+package datastruct
+
+import "time"
+
+type Notification struct {
+	ID          uint64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID      uint64     `gorm:"column:user_id" json:"user_id"`
+	Type        string     `gorm:"column:type" json:"type"`
+	Title       string     `gorm:"column:title" json:"title"`
+	Body        string     `gorm:"column:body" json:"body"`
+	ReferenceID uint64     `gorm:"column:reference_id" json:"reference_id"`
+	ReadAt      *time.Time `gorm:"column:read_at" json:"read_at"`
+	CreatedAt   time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (Notification) TableName() string {
+	return "notifications"
+}
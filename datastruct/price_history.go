@@ -0,0 +1,42 @@
+package datastruct
+
+import "time"
+
+type (
+	PriceHistory struct {
+		ID            uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+		ProductID     uint64    `gorm:"column:product_id" json:"product_id"`
+		Price         float64   `gorm:"column:price" json:"price"`
+		PreviousPrice *float64  `gorm:"column:previous_price" json:"previous_price"`
+		CreatedAt     time.Time `gorm:"column:created_at" json:"created_at"`
+		UpdatedAt     time.Time `gorm:"column:updated_at" json:"updated_at"`
+	}
+
+	PriceHistoryResponse struct {
+		ProductID    uint64         `json:"product_id"`
+		CurrentPrice float64        `json:"current_price"`
+		LowestPrice  float64        `json:"lowest_price"`
+		HighestPrice float64        `json:"highest_price"`
+		Histories    []PriceHistory `json:"histories"`
+	}
+
+	PriceAlertReport struct {
+		Checked  int `json:"checked"`
+		Notified int `json:"notified"`
+	}
+
+	PriceDropCandidate struct {
+		WishlistID   uint64   `gorm:"column:wishlist_id"`
+		UserID       uint64   `gorm:"column:user_id"`
+		ListingID    uint64   `gorm:"column:listing_id"`
+		ProductName  string   `gorm:"column:product_name"`
+		Marketplace  string   `gorm:"column:marketplace"`
+		SavedPrice   float64  `gorm:"column:saved_price"`
+		AlertedPrice *float64 `gorm:"column:alerted_price"`
+		CurrentPrice float64  `gorm:"column:current_price"`
+	}
+)
+
+func (PriceHistory) TableName() string {
+	return "price_histories"
+}
@@ -16,6 +16,7 @@ type (
 		IsSubscriptionActive bool      `gorm:"column:is_subscription_active" json:"is_subscription_active"`
 		RejectedNote         string    `gorm:"column:rejected_note" json:"rejected_note"`
 		Price                float64   `gorm:"column:price" json:"price"`
+		Attributes           *string   `gorm:"column:attributes" json:"-"`
 		CreatedAt            time.Time `gorm:"column:created_at" json:"created_at"`
 		UpdatedAt            time.Time `gorm:"column:updated_at" json:"updated_at"`
 	}
@@ -66,15 +67,17 @@ type (
 	}
 
 	DetailProductMarketplace struct {
-		ID              uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
-		MarketplaceID   uint64    `gorm:"column:marketplace_id" json:"marketplace_id"`
-		ParentProductID uint64    `gorm:"column:parent_product_id" json:"parent_product_id"`
-		ProductID       uint64    `gorm:"column:product_id" json:"product_id"`
-		AddressID       uint64    `gorm:"column:addresses_id" json:"addresses_id"`
-		Link            string    `gorm:"column:link" json:"link"`
-		Clicked         uint64    `gorm:"column:clicked" json:"clicked"`
-		CreatedAt       time.Time `gorm:"column:created_at" json:"created_at"`
-		UpdatedAt       time.Time `gorm:"column:updated_at" json:"updated_at"`
+		ID                uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+		MarketplaceID     uint64    `gorm:"column:marketplace_id" json:"marketplace_id"`
+		ParentProductID   uint64    `gorm:"column:parent_product_id" json:"parent_product_id"`
+		ProductID         uint64    `gorm:"column:product_id" json:"product_id"`
+		AddressID         uint64    `gorm:"column:addresses_id" json:"addresses_id"`
+		Link              string    `gorm:"column:link" json:"link"`
+		ShopIdentifier    string    `gorm:"column:shop_identifier" json:"shop_identifier"`
+		ProductIdentifier string    `gorm:"column:product_identifier" json:"product_identifier"`
+		Clicked           uint64    `gorm:"column:clicked" json:"clicked"`
+		CreatedAt         time.Time `gorm:"column:created_at" json:"created_at"`
+		UpdatedAt         time.Time `gorm:"column:updated_at" json:"updated_at"`
 	}
 
 	DetailLinkedProduct struct {
@@ -1,67 +1,108 @@
 package datastruct
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type (
+	QuestionnaireVersion struct {
+		ID            uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+		Name          string    `gorm:"column:name" json:"name"`
+		Version       int       `gorm:"column:version" json:"version"`
+		IsActive      bool      `gorm:"column:is_active" json:"is_active"`
+		QuestionCount int64     `gorm:"->;column:question_count" json:"question_count"`
+		ResultCount   int64     `gorm:"->;column:result_count" json:"result_count"`
+		CreatedAt     time.Time `gorm:"column:created_at" json:"created_at"`
+		UpdatedAt     time.Time `gorm:"column:updated_at" json:"updated_at"`
+	}
+
+	// Questionnaire is one question of a questionnaire version. Question and
+	// Language are only filled when read in a language.
 	Questionnaire struct {
-		ID       uint64 `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
-		Type     string `gorm:"column:type" json:"type"`
-		Question string `gorm:"column:question" json:"question"`
+		ID                     uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+		QuestionnaireVersionID uint64    `gorm:"column:questionnaire_version_id" json:"questionnaire_version_id"`
+		Type                   string    `gorm:"column:type" json:"type"`
+		Trait                  string    `gorm:"column:trait" json:"-"`
+		IsReverseKeyed         bool      `gorm:"column:is_reverse_keyed" json:"-"`
+		SortOrder              int       `gorm:"column:sort_order" json:"-"`
+		Question               string    `gorm:"->;column:question" json:"question"`
+		Language               string    `gorm:"->;column:language" json:"language"`
+		CreatedAt              time.Time `gorm:"column:created_at" json:"-"`
+		UpdatedAt              time.Time `gorm:"column:updated_at" json:"-"`
+	}
+
+	QuestionnaireTranslation struct {
+		ID              uint64    `gorm:"column:id;primaryKey;autoIncrement"`
+		QuestionnaireID uint64    `gorm:"column:questionnaire_id"`
+		Language        string    `gorm:"column:language"`
+		Question        string    `gorm:"column:question"`
+		CreatedAt       time.Time `gorm:"column:created_at"`
+		UpdatedAt       time.Time `gorm:"column:updated_at"`
+	}
+
+	// QuestionnaireItem is a question as seen by admins, with its scoring key
+	// and the text in every language.
+	QuestionnaireItem struct {
+		ID                     uint64            `json:"id"`
+		QuestionnaireVersionID uint64            `json:"questionnaire_version_id"`
+		Type                   string            `json:"type"`
+		Trait                  string            `json:"trait"`
+		IsReverseKeyed         bool              `json:"is_reverse_keyed"`
+		SortOrder              int               `json:"sort_order"`
+		Translations           map[string]string `json:"translations"`
+	}
+
+	QuestionnaireVersionInput struct {
+		Name string `json:"name" validate:"required,max=100"`
+		// CopyFromVersionID starts the version with the questions of another one
+		CopyFromVersionID uint64 `json:"copy_from_version_id"`
+	}
+
+	QuestionnaireItemInput struct {
+		Type           string `json:"type" validate:"required,max=50"`
+		Trait          string `json:"trait" validate:"required"`
+		IsReverseKeyed bool   `json:"is_reverse_keyed"`
+		SortOrder      int    `json:"sort_order"`
+		// Translations holds the question text keyed by language, e.g. {"id": "...", "en": "..."}
+		Translations map[string]string `json:"translations" validate:"required"`
 	}
 
+	// QuestionnaireRequest holds the answers keyed by question type, e.g. EXT1.
+	// An empty QuestionnaireVersionID means the active version.
 	QuestionnaireRequest struct {
-		EXT1  int `json:"EXT1"`
-		EXT2  int `json:"EXT2"`
-		EXT3  int `json:"EXT3"`
-		EXT4  int `json:"EXT4"`
-		EXT5  int `json:"EXT5"`
-		EXT6  int `json:"EXT6"`
-		EXT7  int `json:"EXT7"`
-		EXT8  int `json:"EXT8"`
-		EXT9  int `json:"EXT9"`
-		EXT10 int `json:"EXT10"`
-
-		EST1  int `json:"EST1"`
-		EST2  int `json:"EST2"`
-		EST3  int `json:"EST3"`
-		EST4  int `json:"EST4"`
-		EST5  int `json:"EST5"`
-		EST6  int `json:"EST6"`
-		EST7  int `json:"EST7"`
-		EST8  int `json:"EST8"`
-		EST9  int `json:"EST9"`
-		EST10 int `json:"EST10"`
-
-		AGR1  int `json:"AGR1"`
-		AGR2  int `json:"AGR2"`
-		AGR3  int `json:"AGR3"`
-		AGR4  int `json:"AGR4"`
-		AGR5  int `json:"AGR5"`
-		AGR6  int `json:"AGR6"`
-		AGR7  int `json:"AGR7"`
-		AGR8  int `json:"AGR8"`
-		AGR9  int `json:"AGR9"`
-		AGR10 int `json:"AGR10"`
-
-		CSN1  int `json:"CSN1"`
-		CSN2  int `json:"CSN2"`
-		CSN3  int `json:"CSN3"`
-		CSN4  int `json:"CSN4"`
-		CSN5  int `json:"CSN5"`
-		CSN6  int `json:"CSN6"`
-		CSN7  int `json:"CSN7"`
-		CSN8  int `json:"CSN8"`
-		CSN9  int `json:"CSN9"`
-		CSN10 int `json:"CSN10"`
-
-		OPN1  int `json:"OPN1"`
-		OPN2  int `json:"OPN2"`
-		OPN3  int `json:"OPN3"`
-		OPN4  int `json:"OPN4"`
-		OPN5  int `json:"OPN5"`
-		OPN6  int `json:"OPN6"`
-		OPN7  int `json:"OPN7"`
-		OPN8  int `json:"OPN8"`
-		OPN9  int `json:"OPN9"`
-		OPN10 int `json:"OPN10"`
+		QuestionnaireVersionID uint64         `json:"questionnaire_version_id"`
+		Answers                map[string]int `json:"answers"`
+	}
+
+	UserPersonalityAnswer struct {
+		ID                uint64    `gorm:"column:id;primaryKey;autoIncrement"`
+		UserPersonalityID uint64    `gorm:"column:user_personality_id"`
+		QuestionnaireID   uint64    `gorm:"column:questionnaire_id"`
+		Answer            int       `gorm:"column:answer"`
+		CreatedAt         time.Time `gorm:"column:created_at"`
+	}
+
+	// QuestionnaireDraftAnswer is an answer saved before the questionnaire is submitted.
+	QuestionnaireDraftAnswer struct {
+		ID              uint64    `gorm:"column:id;primaryKey;autoIncrement"`
+		UserID          uint64    `gorm:"column:user_id"`
+		QuestionnaireID uint64    `gorm:"column:questionnaire_id"`
+		Answer          int       `gorm:"column:answer"`
+		CreatedAt       time.Time `gorm:"column:created_at"`
+		UpdatedAt       time.Time `gorm:"column:updated_at"`
+	}
+
+	// QuestionnaireProgress shows how far a user got in the active questionnaire.
+	QuestionnaireProgress struct {
+		QuestionnaireVersionID uint64         `json:"questionnaire_version_id"`
+		Answered               int            `json:"answered"`
+		Total                  int            `json:"total"`
+		Percentage             float64        `json:"percentage"`
+		IsComplete             bool           `json:"is_complete"`
+		Answers                map[string]int `json:"answers"`
+		Missing                []string       `json:"missing"`
+		UpdatedAt              *time.Time     `json:"updated_at"`
 	}
 
 	PersonalityPercentages struct {
@@ -70,9 +111,58 @@ type (
 		Extraversion  float64 `json:"percentage_of_extraversion"`
 		Neurotic      float64 `json:"percentage_of_neurotic"`
 		Openness      float64 `json:"percentage_of_openess"`
+		ModelVersion  string  `json:"model_version,omitempty"`
+		Source        string  `json:"source,omitempty"`
 	}
 )
 
+// UnmarshalJSON also accepts the original request body, where the answers
+// are top level fields ({"EXT1": 4, ...}).
+func (r *QuestionnaireRequest) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	if raw, ok := fields["questionnaire_version_id"]; ok {
+		if err := json.Unmarshal(raw, &r.QuestionnaireVersionID); err != nil {
+			return err
+		}
+		delete(fields, "questionnaire_version_id")
+	}
+
+	if raw, ok := fields["answers"]; ok {
+		return json.Unmarshal(raw, &r.Answers)
+	}
+
+	r.Answers = make(map[string]int, len(fields))
+	for name, raw := range fields {
+		var answer int
+		if err := json.Unmarshal(raw, &answer); err != nil {
+			return err
+		}
+		r.Answers[name] = answer
+	}
+
+	return nil
+}
+
+func (QuestionnaireVersion) TableName() string {
+	return "questionnaire_versions"
+}
+
 func (Questionnaire) TableName() string {
 	return "personality_questionnaires"
 }
+
+func (QuestionnaireTranslation) TableName() string {
+	return "personality_questionnaire_translations"
+}
+
+func (UserPersonalityAnswer) TableName() string {
+	return "user_personality_answers"
+}
+
+func (QuestionnaireDraftAnswer) TableName() string {
+	return "questionnaire_draft_answers"
+}
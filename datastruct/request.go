@@ -39,6 +39,23 @@ type (
 		MerchantID            uint64                  `form:"merchant_id"`
 		DetailProductTags     string                  `form:"detail_product_tags" validate:"required"`
 		DetailProductVariants string                  `form:"detail_product_variants" validate:"required"`
+		Attributes            string                  `form:"attributes"`
+		ConfirmDuplicate      bool                    `form:"confirm_duplicate"`
+	}
+
+	PatchInitialProductInput struct {
+		Name                  *string                 `form:"name" json:"name"`
+		Description           *string                 `form:"description" json:"description"`
+		LinkExternal          *string                 `form:"link_external" json:"link_external"`
+		CategoryID            *uint64                 `form:"category_id" json:"category_id"`
+		BrandID               *uint64                 `form:"brand_id" json:"brand_id"`
+		Images                []*multipart.FileHeader `form:"images" json:"-"`
+		RemoveImages          string                  `form:"remove_images" json:"remove_images"`
+		ImageOrder            string                  `form:"image_order" json:"image_order"`
+		AddTags               string                  `form:"add_tags" json:"add_tags"`
+		RemoveTags            string                  `form:"remove_tags" json:"remove_tags"`
+		DetailProductVariants *string                 `form:"detail_product_variants" json:"detail_product_variants"`
+		Attributes            *string                 `form:"attributes" json:"attributes"`
 	}
 
 	CreateMerchantProductInput struct {
@@ -49,6 +66,7 @@ type (
 		MerchantID               uint64                  `form:"merchant_id"`
 		DetailProductMarketplace string                  `form:"detail_product_marketplaces" validate:"required"`
 		Price                    float64                 `form:"price" validate:"required"`
+		ConfirmDuplicate         bool                    `form:"confirm_duplicate"`
 	}
 
 	VerifyProductInput struct {
@@ -63,6 +81,42 @@ type (
 		Description string  `json:"description"`
 	}
 
+	EditMerchantProductInput struct {
+		Name               *string                 `form:"name" json:"name"`
+		Description        *string                 `form:"description" json:"description"`
+		Price              *float64                `form:"price" json:"price"`
+		Images             []*multipart.FileHeader `form:"images" json:"-"`
+		UpsertMarketplaces string                  `form:"upsert_marketplaces" json:"upsert_marketplaces"`
+		RemoveMarketplaces string                  `form:"remove_marketplaces" json:"remove_marketplaces"`
+	}
+
+	MerchantListingInput struct {
+		ID            uint64        `json:"id"`
+		MarketplaceID uint64        `json:"marketplace_id"`
+		Link          string        `json:"link"`
+		AddressID     uint64        `json:"addresses_id"`
+		Address       *AddressInput `json:"address"`
+
+		// filled from Link during validation
+		ShopIdentifier    string `json:"-"`
+		ProductIdentifier string `json:"-"`
+	}
+
+	AddressInput struct {
+		Street        string   `json:"street" validate:"required"`
+		ProvinceID    uint64   `json:"province_id" validate:"required"`
+		CityID        uint64   `json:"city_id" validate:"required"`
+		DistrictID    uint64   `json:"district_id" validate:"required"`
+		SubdistrictID uint64   `json:"subdistrict_id" validate:"required"`
+		PostalCodeID  uint64   `json:"postal_code_id" validate:"required"`
+		Latitude      *float64 `json:"latitude"`
+		Longitude     *float64 `json:"longitude"`
+	}
+
+	CompareProductsInput struct {
+		ProductIDs []uint64 `json:"product_ids" validate:"required,min=2,max=4,dive,required"`
+	}
+
 	BrandInput struct {
 		Name       string                `form:"name" json:"name"`
 		Image      *multipart.FileHeader `form:"image" json:"image"`
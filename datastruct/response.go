@@ -3,6 +3,12 @@ package datastruct
 import "time"
 
 type (
+	EditMerchantProductResponse struct {
+		ID       uint64 `json:"id"`
+		Status   string `json:"status"`
+		InReview bool   `json:"in_review"`
+	}
+
 	LoginRegisterResponse struct {
 		UserID uint64 `json:"user_id"`
 		Token  string `json:"token"`
@@ -20,8 +26,11 @@ type (
 		Extraversion  float64 `gorm:"column:ext_result" json:"percentage_of_extraversion"`
 		Neurotic      float64 `gorm:"column:est_result" json:"percentage_of_neurotic"`
 		Openness      float64 `gorm:"column:opn_result" json:"percentage_of_openess"`
+		ModelVersion  *string `gorm:"column:model_version" json:"model_version"`
+		Source        string  `gorm:"column:source" json:"source"`
 	}
 	FaceShapeResponse struct {
+		ScanID   uint64 `json:"scan_id"`
 		ImageUrl string `json:"image_url"`
 		Result   string `json:"result"`
 	}
@@ -41,6 +50,7 @@ type (
 		PersonalityID             uint64     `gorm:"column:personality_id" json:"-"`
 		FaceShapeTagID            uint64     `gorm:"column:face_shape_id" json:"-"`
 		FaceShape                 *string    `json:"face_shape"`
+		FaceShapeModelVersion     *string    `gorm:"-" json:"face_shape_model_version"`
 		PersonalityType           *string    `json:"personality_type"`
 		IsVerified                bool       `json:"is_verified"`
 		Avatar                    string     `json:"avatar"`
@@ -60,10 +70,12 @@ type (
 	InitialProductResponse struct {
 		InitialProduct
 		Images                []string                     `json:"images"`
+		ImageRenditions       []ImageRenditions            `json:"image_renditions"`
 		Variants              []InitialProductVariant      `json:"variants"`
 		ListMarketplace       []ProductMarketplaceWishlist `json:"marketplaces"`
 		Tags                  []string                     `json:"tags"`
 		RecommendationProduct []RecommendationProductML    `json:"recommendation_product"`
+		Attributes            map[string]interface{}       `json:"attributes"`
 	}
 
 	RecommendationProductML struct {
@@ -74,17 +86,18 @@ type (
 	}
 
 	InitialProduct struct {
-		ID                   uint64 `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
-		Name                 string `gorm:"column:name" json:"name"`
-		Description          string `gorm:"column:description" json:"description"`
-		LinkExternal         string `gorm:"column:link_external" json:"link_external"`
-		CategoryName         string `gorm:"column:category_name" json:"category_name"`
-		Status               string `gorm:"column:status" json:"status"`
-		IsWishlisted         bool   `gorm:"column:is_wishlisted" json:"is_wishlisted"`
-		IsSubscriptionActive bool   `gorm:"column:is_subscription_active" json:"is_subscription_active"`
-		RejectedNote         string `gorm:"column:rejected_note" json:"rejected_note"`
-		BrandName            string `gorm:"column:brand_name" json:"brand_name"`
-		Images               string `gorm:"column:images" json:"-"`
+		ID                   uint64  `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+		Name                 string  `gorm:"column:name" json:"name"`
+		Description          string  `gorm:"column:description" json:"description"`
+		LinkExternal         string  `gorm:"column:link_external" json:"link_external"`
+		CategoryName         string  `gorm:"column:category_name" json:"category_name"`
+		Status               string  `gorm:"column:status" json:"status"`
+		IsWishlisted         bool    `gorm:"column:is_wishlisted" json:"is_wishlisted"`
+		IsSubscriptionActive bool    `gorm:"column:is_subscription_active" json:"is_subscription_active"`
+		RejectedNote         string  `gorm:"column:rejected_note" json:"rejected_note"`
+		BrandName            string  `gorm:"column:brand_name" json:"brand_name"`
+		Images               string  `gorm:"column:images" json:"-"`
+		RawAttributes        *string `gorm:"column:attributes" json:"-"`
 	}
 
 	InitialProductVariant struct {
@@ -102,8 +115,9 @@ type (
 	}
 
 	CategoryResponse struct {
-		ID   uint64 `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
-		Name string `gorm:"column:name" json:"name"`
+		ID       uint64 `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+		Name     string `gorm:"column:name" json:"name"`
+		ParentID uint64 `gorm:"column:parent_id" json:"parent_id"`
 	}
 
 	ProductRecommendationResponse struct {
@@ -142,6 +156,9 @@ type (
 		Address         *string `json:"address"`
 		Marketplace     *string `json:"marketplace_name"`
 		MarketplaceLink *string `gorm:"column:marketplace_link" json:"marketplace_link"`
+		MarketplaceLogo *string `json:"marketplace_logo"`
+		DeepLink        *string `json:"deep_link"`
+		StockStatus     *string `json:"stock_status"`
 		MarketplaceID   uint64  `gorm:"column:marketplace_id" json:"-"`
 		AddressID       uint64  `gorm:"column:addresses_id" json:"-"`
 	}
@@ -155,6 +172,7 @@ type (
 		Image           string                  `gorm:"column:images" json:"-"`
 		IsWishlisted    bool                    `gorm:"column:is_wishlisted" json:"is_wishlisted"`
 		Images          []string                `json:"images"`
+		ImageRenditions []ImageRenditions       `gorm:"-" json:"image_renditions"`
 		Price           float64                 `gorm:"column:price" json:"price"`
 		Merchant        string                  `gorm:"column:merchant" json:"merchant"`
 		MerchantID      uint64                  `gorm:"column:merchant_id" json:"-"`
@@ -162,11 +180,90 @@ type (
 		Address         *string                 `json:"address"`
 		Marketplace     *string                 `json:"marketplace_name"`
 		MarketplaceLink *string                 `gorm:"column:marketplace_link" json:"marketplace_link"`
+		MarketplaceLogo *string                 `json:"marketplace_logo"`
+		DeepLink        *string                 `json:"deep_link"`
+		StockStatus     *string                 `json:"stock_status"`
+		Stocks          []VariantStock          `json:"stocks"`
 		MarketplaceID   uint64                  `gorm:"column:marketplace_id" json:"-"`
 		AddressID       uint64                  `gorm:"column:addresses_id" json:"-"`
 		Variants        []InitialProductVariant `json:"variants"`
 	}
 
+	ProductOffer struct {
+		ID              uint64   `gorm:"column:id" json:"id"`
+		ProductID       uint64   `gorm:"column:product_id" json:"product_id"`
+		Price           float64  `gorm:"column:price" json:"price"`
+		MerchantID      uint64   `gorm:"column:merchant_id" json:"merchant_id"`
+		Merchant        string   `gorm:"column:merchant" json:"merchant"`
+		IsBoosted       bool     `gorm:"column:is_subscription_active" json:"is_boosted"`
+		Type            string   `json:"store_type"`
+		Marketplace     *string  `json:"marketplace_name"`
+		MarketplaceLink *string  `gorm:"column:marketplace_link" json:"marketplace_link"`
+		MarketplaceLogo *string  `json:"marketplace_logo"`
+		DeepLink        *string  `json:"deep_link"`
+		StockStatus     *string  `json:"stock_status"`
+		MarketplaceID   uint64   `gorm:"column:marketplace_id" json:"-"`
+		Address         *string  `json:"address"`
+		Location        *string  `json:"location"`
+		AddressID       uint64   `gorm:"column:addresses_id" json:"-"`
+		Latitude        *float64 `gorm:"column:latitude" json:"latitude"`
+		Longitude       *float64 `gorm:"column:longitude" json:"longitude"`
+		DistanceKm      *float64 `gorm:"-" json:"distance_km"`
+	}
+
+	ProductOffersResponse struct {
+		InitialProductID uint64         `json:"initial_product_id"`
+		Name             string         `json:"name"`
+		LowestPrice      float64        `json:"lowest_price"`
+		HighestPrice     float64        `json:"highest_price"`
+		Offers           []ProductOffer `json:"offers"`
+	}
+
+	ProductComparisonResponse struct {
+		Products            []ProductComparison `json:"products"`
+		DifferingAttributes []string            `json:"differing_attributes"`
+	}
+
+	ProductComparison struct {
+		ID                 uint64                  `gorm:"column:id" json:"id"`
+		Type               string                  `gorm:"-" json:"type"`
+		InitialProductID   uint64                  `gorm:"-" json:"initial_product_id"`
+		Name               string                  `gorm:"column:name" json:"name"`
+		Image              string                  `gorm:"column:images" json:"image"`
+		BrandName          string                  `gorm:"column:brand_name" json:"brand_name"`
+		CategoryName       string                  `gorm:"column:category_name" json:"category_name"`
+		Tags               []string                `gorm:"-" json:"tags"`
+		SuitableFaceShapes []string                `gorm:"-" json:"suitable_face_shapes"`
+		Variants           []InitialProductVariant `gorm:"-" json:"variants"`
+		Price              *float64                `gorm:"-" json:"price"`
+		PriceRange         *ComparisonPriceRange   `gorm:"-" json:"price_range"`
+		Rating             *float64                `gorm:"-" json:"rating"`
+		Attributes         map[string]interface{}  `gorm:"-" json:"attributes"`
+		MerchantID         uint64                  `gorm:"column:merchant_id" json:"-"`
+		MerchantPrice      float64                 `gorm:"column:price" json:"-"`
+		RawAttributes      *string                 `gorm:"column:attributes" json:"-"`
+	}
+
+	ComparisonPriceRange struct {
+		InitialProductID uint64  `gorm:"column:initial_product_id" json:"-"`
+		LowestPrice      float64 `gorm:"column:lowest_price" json:"lowest_price"`
+		HighestPrice     float64 `gorm:"column:highest_price" json:"highest_price"`
+		MerchantCount    int     `gorm:"column:merchant_count" json:"merchant_count"`
+	}
+
+	SimilarProduct struct {
+		ID           uint64   `gorm:"column:id" json:"id"`
+		Name         string   `gorm:"column:name" json:"name"`
+		Image        string   `gorm:"column:images" json:"image"`
+		BrandName    string   `gorm:"column:brand_name" json:"brand_name"`
+		CategoryName string   `gorm:"column:category_name" json:"category_name"`
+		LowestPrice  *float64 `gorm:"-" json:"lowest_price"`
+		Score        float64  `gorm:"-" json:"score"`
+		Reasons      []string `gorm:"-" json:"reasons"`
+		BrandID      uint64   `gorm:"column:brand_id" json:"-"`
+		CategoryID   uint64   `gorm:"column:category_id" json:"-"`
+	}
+
 	HomeMerchantResponse struct {
 		MerchantID uint64        `gorm:"column:merchant_id" json:"merchant_id"`
 		Name       string        `gorm:"column:merchant_name" json:"merchant_name"`
@@ -182,11 +279,12 @@ type (
 	}
 
 	HomeProduct struct {
-		ID    uint64   `gorm:"column:id" json:"id"`
-		Name  string   `gorm:"column:name" json:"name"`
-		Brand string   `gorm:"column:brand" json:"brand"`
-		Image string   `gorm:"column:images" json:"image"`
-		Tags  []string `json:"tags"`
+		ID              uint64          `gorm:"column:id" json:"id"`
+		Name            string          `gorm:"column:name" json:"name"`
+		Brand           string          `gorm:"column:brand" json:"brand"`
+		Image           string          `gorm:"column:images" json:"image"`
+		ImageRenditions ImageRenditions `gorm:"-" json:"image_renditions"`
+		Tags            []string        `json:"tags"`
 	}
 
 	MerchantHome struct {
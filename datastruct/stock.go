@@ -0,0 +1,47 @@
+package datastruct
+
+import "time"
+
+type (
+	ProductStock struct {
+		ID                         uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+		DetailProductMarketplaceID uint64    `gorm:"column:detail_product_marketplace_id" json:"detail_product_marketplace_id"`
+		DetailProductVariantID     uint64    `gorm:"column:detail_product_variant_id" json:"variant_id"`
+		Quantity                   int       `gorm:"column:quantity" json:"quantity"`
+		CreatedAt                  time.Time `gorm:"column:created_at" json:"created_at"`
+		UpdatedAt                  time.Time `gorm:"column:updated_at" json:"updated_at"`
+	}
+
+	StockInput struct {
+		Stocks []StockItemInput `json:"stocks" validate:"required,min=1,dive"`
+	}
+
+	StockItemInput struct {
+		DetailProductMarketplaceID uint64 `json:"detail_product_marketplace_id" validate:"required"`
+		VariantID                  uint64 `json:"variant_id" validate:"required"`
+		Quantity                   *int   `json:"quantity" validate:"required,min=0"`
+	}
+
+	StockImportReport struct {
+		Updated int      `json:"updated"`
+		Errors  []string `json:"errors"`
+	}
+
+	ListingStock struct {
+		DetailProductMarketplaceID uint64         `json:"detail_product_marketplace_id"`
+		Address                    *string        `json:"address"`
+		StockStatus                *string        `json:"stock_status"`
+		Variants                   []VariantStock `json:"variants"`
+	}
+
+	VariantStock struct {
+		VariantID   uint64 `gorm:"column:variant_id" json:"variant_id"`
+		VariantName string `gorm:"column:variant_name" json:"variant_name"`
+		Quantity    int    `gorm:"column:quantity" json:"quantity"`
+		StockStatus string `gorm:"-" json:"stock_status"`
+	}
+)
+
+func (ProductStock) TableName() string {
+	return "detail_product_stocks"
+}
@@ -2,14 +2,60 @@ package datastruct
 
 import "time"
 
-type Tag struct {
-	ID         uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
-	Name       string    `gorm:"column:name" json:"name"`
-	CategoryID uint64    `gorm:"column:category_id" json:"category_id"`
-	CreatedAt  time.Time `gorm:"column:created_at" json:"created_at"`
-	UpdatedAt  time.Time `gorm:"column:updated_at" json:"updated_at"`
-}
+type (
+	Tag struct {
+		ID         uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+		Name       string    `gorm:"column:name" json:"name"`
+		CategoryID uint64    `gorm:"column:category_id" json:"category_id"`
+		CreatedAt  time.Time `gorm:"column:created_at" json:"created_at"`
+		UpdatedAt  time.Time `gorm:"column:updated_at" json:"updated_at"`
+	}
+
+	PersonalityTrait struct {
+		ID        uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+		Code      string    `gorm:"column:code" json:"code"`
+		Name      string    `gorm:"column:name" json:"name"`
+		CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+		UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`
+	}
+
+	DetailPersonalityTag struct {
+		ID                 uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+		PersonalityTraitID uint64    `gorm:"column:personality_trait_id" json:"personality_trait_id"`
+		TagID              uint64    `gorm:"column:tag_id" json:"tag_id"`
+		CreatedAt          time.Time `gorm:"column:created_at" json:"created_at"`
+		UpdatedAt          time.Time `gorm:"column:updated_at" json:"updated_at"`
+	}
+
+	TagInput struct {
+		Name       string `json:"name" validate:"required"`
+		CategoryID uint64 `json:"category_id" validate:"required"`
+	}
+
+	TagMappingInput struct {
+		TagIDs []uint64 `json:"tag_ids"`
+	}
+
+	TagMapping struct {
+		ID     uint64   `json:"id"`
+		Name   string   `json:"name"`
+		TagIDs []uint64 `json:"tag_ids"`
+	}
+
+	TagMappingResponse struct {
+		FaceShapes    []TagMapping `json:"face_shapes"`
+		Personalities []TagMapping `json:"personalities"`
+	}
+)
 
 func (Tag) TableName() string {
 	return "tags"
 }
+
+func (PersonalityTrait) TableName() string {
+	return "personality_traits"
+}
+
+func (DetailPersonalityTag) TableName() string {
+	return "detail_personality_tags"
+}
@@ -7,6 +7,8 @@ type Wishlist struct {
 	UserID                     uint64    `gorm:"column:user_id" json:"user_id"`
 	ProductID                  *uint64   `gorm:"column:product_id" json:"product_id"`
 	DetailProductMarketplaceID *uint64   `gorm:"column:detail_product_marketplace_id" json:"detail_product_marketplace_id"`
+	SavedPrice                 *float64  `gorm:"column:saved_price" json:"saved_price"`
+	AlertedPrice               *float64  `gorm:"column:alerted_price" json:"-"`
 	CreatedAt                  time.Time `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt                  time.Time `gorm:"column:updated_at" json:"updated_at"`
 }
@@ -2,17 +2,31 @@ package handler
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/labstack/echo/v4"
+	"github.com/yusufwib/arvigo-backend/datastruct"
 	"github.com/yusufwib/arvigo-backend/middleware"
 	"github.com/yusufwib/arvigo-backend/repository"
 	"github.com/yusufwib/arvigo-backend/utils"
 )
 
+// attributeFilterPrefix marks query params filtering products by attribute, e.g. attr_frame_material=metal.
+const attributeFilterPrefix = "attr_"
+
 func RegisterCategoryRoutes(e *echo.Echo) {
 	v1Group := e.Group("/v1")
 	catGroup := v1Group.Group("/categories", middleware.AuthMiddleware)
 	catGroup.GET("", getCategories)
+	catGroup.GET("/tree", getCategoryTree)
+	catGroup.POST("", createCategory, middleware.DashboardMiddleware)
+	catGroup.PUT("/:id", updateCategory, middleware.DashboardMiddleware)
+	catGroup.DELETE("/:id", deleteCategory, middleware.DashboardMiddleware)
+
+	catGroup.GET("/:id/attributes", getCategoryAttributes)
+	catGroup.POST("/:id/attributes", createCategoryAttribute, middleware.DashboardMiddleware)
+	catGroup.PUT("/:id/attributes/:attribute_id", updateCategoryAttribute, middleware.DashboardMiddleware)
+	catGroup.DELETE("/:id/attributes/:attribute_id", deleteCategoryAttribute, middleware.DashboardMiddleware)
 
 	catGroup.GET("/:id/list-product", getListProductByCategory)
 }
@@ -26,13 +40,164 @@ func getCategories(c echo.Context) error {
 	return utils.ResponseJSON(c, "Success get data", data, http.StatusOK)
 }
 
+func getCategoryTree(c echo.Context) error {
+	data, statusCode, err := repository.GetCategoryTree()
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success get data", data, statusCode)
+}
+
+func createCategory(c echo.Context) error {
+	var data datastruct.CategoryInput
+	if err := c.Bind(&data); err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, http.StatusBadRequest)
+	}
+
+	validationErrors := utils.ValidateStruct(data)
+	if len(validationErrors) > 0 {
+		return utils.ResponseJSON(c, "The data is not valid", validationErrors, http.StatusBadRequest)
+	}
+
+	res, statusCode, err := repository.CreateCategory(data)
+	if err != nil {
+		return utils.ResponseJSON(c, "Failed create category", err.Error(), statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Category created", res, statusCode)
+}
+
+func updateCategory(c echo.Context) error {
+	catID := utils.StrToUint64(c.Param("id"), 0)
+	if catID == 0 {
+		return utils.ResponseJSON(c, "Invalid category ID", nil, http.StatusBadRequest)
+	}
+
+	var data datastruct.CategoryInput
+	if err := c.Bind(&data); err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, http.StatusBadRequest)
+	}
+
+	validationErrors := utils.ValidateStruct(data)
+	if len(validationErrors) > 0 {
+		return utils.ResponseJSON(c, "The data is not valid", validationErrors, http.StatusBadRequest)
+	}
+
+	res, statusCode, err := repository.UpdateCategory(catID, data)
+	if err != nil {
+		return utils.ResponseJSON(c, "Failed update category", err.Error(), statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Category updated", res, statusCode)
+}
+
+func deleteCategory(c echo.Context) error {
+	catID := utils.StrToUint64(c.Param("id"), 0)
+	if catID == 0 {
+		return utils.ResponseJSON(c, "Invalid category ID", nil, http.StatusBadRequest)
+	}
+
+	statusCode, err := repository.DeleteCategory(catID)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", nil, statusCode)
+}
+
+func getCategoryAttributes(c echo.Context) error {
+	catID := utils.StrToUint64(c.Param("id"), 0)
+	if catID == 0 {
+		return utils.ResponseJSON(c, "Invalid category ID", nil, http.StatusBadRequest)
+	}
+
+	data, statusCode, err := repository.GetCategoryAttributes(catID)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", data, statusCode)
+}
+
+func createCategoryAttribute(c echo.Context) error {
+	catID := utils.StrToUint64(c.Param("id"), 0)
+	if catID == 0 {
+		return utils.ResponseJSON(c, "Invalid category ID", nil, http.StatusBadRequest)
+	}
+
+	var data datastruct.CategoryAttributeInput
+	if err := c.Bind(&data); err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, http.StatusBadRequest)
+	}
+
+	validationErrors := utils.ValidateStruct(data)
+	if len(validationErrors) > 0 {
+		return utils.ResponseJSON(c, "The data is not valid", validationErrors, http.StatusBadRequest)
+	}
+
+	res, statusCode, err := repository.CreateCategoryAttribute(catID, data)
+	if err != nil {
+		return utils.ResponseJSON(c, "Failed create attribute", err.Error(), statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Attribute created", res, statusCode)
+}
+
+func updateCategoryAttribute(c echo.Context) error {
+	catID := utils.StrToUint64(c.Param("id"), 0)
+	attributeID := utils.StrToUint64(c.Param("attribute_id"), 0)
+	if catID == 0 || attributeID == 0 {
+		return utils.ResponseJSON(c, "Invalid category or attribute ID", nil, http.StatusBadRequest)
+	}
+
+	var data datastruct.CategoryAttributeInput
+	if err := c.Bind(&data); err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, http.StatusBadRequest)
+	}
+
+	validationErrors := utils.ValidateStruct(data)
+	if len(validationErrors) > 0 {
+		return utils.ResponseJSON(c, "The data is not valid", validationErrors, http.StatusBadRequest)
+	}
+
+	res, statusCode, err := repository.UpdateCategoryAttribute(catID, attributeID, data)
+	if err != nil {
+		return utils.ResponseJSON(c, "Failed update attribute", err.Error(), statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Attribute updated", res, statusCode)
+}
+
+func deleteCategoryAttribute(c echo.Context) error {
+	catID := utils.StrToUint64(c.Param("id"), 0)
+	attributeID := utils.StrToUint64(c.Param("attribute_id"), 0)
+	if catID == 0 || attributeID == 0 {
+		return utils.ResponseJSON(c, "Invalid category or attribute ID", nil, http.StatusBadRequest)
+	}
+
+	statusCode, err := repository.DeleteCategoryAttribute(catID, attributeID)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", nil, statusCode)
+}
+
 func getListProductByCategory(c echo.Context) error {
 	catID := utils.StrToUint64(c.Param("id"), 0)
 	if catID == 0 {
 		return utils.ResponseJSON(c, "Invalid category ID", nil, http.StatusBadRequest)
 	}
 
-	data, statusCode, err := repository.GetListProductByCategory(catID)
+	attributeFilters := make(map[string]string)
+	for key, values := range c.QueryParams() {
+		if strings.HasPrefix(key, attributeFilterPrefix) && len(values) > 0 {
+			attributeFilters[strings.TrimPrefix(key, attributeFilterPrefix)] = values[0]
+		}
+	}
+
+	data, statusCode, err := repository.GetListProductByCategory(catID, attributeFilters)
 	if err != nil {
 		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
 	}
@@ -2,12 +2,16 @@ package handler
 
 import (
 	"github.com/labstack/echo/v4"
+	"github.com/yusufwib/arvigo-backend/middleware"
 	"github.com/yusufwib/arvigo-backend/repository"
 	"github.com/yusufwib/arvigo-backend/utils"
 )
 
 func RegisterCronJobRoutes(e *echo.Echo) {
 	e.POST("/v1/cron-job/subscription", subscriptionCronJob)
+	e.POST("/v1/cron-job/asset-sweep", assetSweepCronJob, middleware.ApiKeyMiddleware)
+	e.POST("/v1/cron-job/price-alert", priceAlertCronJob, middleware.ApiKeyMiddleware)
+	e.POST("/v1/cron-job/product-image-hashes", productImageHashBackfillCronJob, middleware.ApiKeyMiddleware)
 }
 
 func subscriptionCronJob(c echo.Context) error {
@@ -18,3 +22,35 @@ func subscriptionCronJob(c echo.Context) error {
 
 	return utils.ResponseJSON(c, "Success", nil, statusCode)
 }
+
+func assetSweepCronJob(c echo.Context) error {
+	dryRun := c.QueryParam("dry_run") == "true"
+
+	data, statusCode, err := repository.SweepOrphanedAssets(dryRun)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), data, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", data, statusCode)
+}
+
+func priceAlertCronJob(c echo.Context) error {
+	data, statusCode, err := repository.PriceDropAlertCronJob()
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), data, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", data, statusCode)
+}
+
+func productImageHashBackfillCronJob(c echo.Context) error {
+	afterID := utils.StrToUint64(c.QueryParam("after_id"), 0)
+	limit := utils.StrToInt(c.QueryParam("limit"), 0)
+
+	data, statusCode, err := repository.BackfillProductImageHashes(afterID, limit)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), data, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", data, statusCode)
+}
@@ -14,6 +14,11 @@ func RegisterFaceShapeRoutes(e *echo.Echo) {
 	v1Group := e.Group("/v1")
 	locationGroup := v1Group.Group("/face-shape", middleware.AuthMiddleware)
 	locationGroup.POST("/check", faceShapeRecognitionHandler)
+	locationGroup.POST("/jobs", submitFaceShapeJobHandler)
+	locationGroup.GET("/jobs/:id", getFaceShapeJobHandler)
+	locationGroup.GET("/scans", getFaceScansHandler)
+	locationGroup.PUT("/scans/:id/active", activateFaceScanHandler)
+	locationGroup.POST("/scans/:id/feedback", submitFaceScanFeedbackHandler)
 }
 
 func faceShapeRecognitionHandler(c echo.Context) error {
@@ -31,3 +36,83 @@ func faceShapeRecognitionHandler(c echo.Context) error {
 
 	return utils.ResponseJSON(c, "Success post data", data, http.StatusOK)
 }
+
+func submitFaceShapeJobHandler(c echo.Context) error {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return utils.ResponseJSON(c, "Failed to parse form data", nil, http.StatusBadRequest)
+	}
+
+	userAuth := c.Get("userAuth").(*datastruct.UserAuth)
+	data, statusCode, err := repository.SubmitFaceShapeJob(form, userAuth.ID)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Face scan queued", data, statusCode)
+}
+
+func getFaceShapeJobHandler(c echo.Context) error {
+	jobID := utils.StrToUint64(c.Param("id"), 0)
+	if jobID == 0 {
+		return utils.ResponseJSON(c, "Invalid job ID", nil, http.StatusBadRequest)
+	}
+
+	userAuth := c.Get("userAuth").(*datastruct.UserAuth)
+	data, statusCode, err := repository.GetFaceShapeJob(userAuth.ID, jobID)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", data, statusCode)
+}
+
+func getFaceScansHandler(c echo.Context) error {
+	userAuth := c.Get("userAuth").(*datastruct.UserAuth)
+	data, statusCode, err := repository.GetFaceScans(userAuth.ID)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", data, statusCode)
+}
+
+func activateFaceScanHandler(c echo.Context) error {
+	scanID := utils.StrToUint64(c.Param("id"), 0)
+	if scanID == 0 {
+		return utils.ResponseJSON(c, "Invalid scan ID", nil, http.StatusBadRequest)
+	}
+
+	userAuth := c.Get("userAuth").(*datastruct.UserAuth)
+	data, statusCode, err := repository.ActivateFaceScan(userAuth.ID, scanID)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", data, statusCode)
+}
+
+func submitFaceScanFeedbackHandler(c echo.Context) error {
+	scanID := utils.StrToUint64(c.Param("id"), 0)
+	if scanID == 0 {
+		return utils.ResponseJSON(c, "Invalid scan ID", nil, http.StatusBadRequest)
+	}
+
+	var data datastruct.FaceScanFeedbackInput
+	if err := c.Bind(&data); err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, http.StatusBadRequest)
+	}
+
+	validationErrors := utils.ValidateStruct(data)
+	if len(validationErrors) > 0 {
+		return utils.ResponseJSON(c, "The data is not valid", validationErrors, http.StatusBadRequest)
+	}
+
+	userAuth := c.Get("userAuth").(*datastruct.UserAuth)
+	res, statusCode, err := repository.SubmitFaceScanFeedback(userAuth.ID, scanID, data)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Thanks for your feedback", res, statusCode)
+}
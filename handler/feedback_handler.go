@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/yusufwib/arvigo-backend/constant"
+	"github.com/yusufwib/arvigo-backend/datastruct"
+	"github.com/yusufwib/arvigo-backend/middleware"
+	"github.com/yusufwib/arvigo-backend/repository"
+	"github.com/yusufwib/arvigo-backend/utils"
+)
+
+func RegisterFeedbackRoutes(e *echo.Echo) {
+	v1Group := e.Group("/v1")
+	feedbackGroup := v1Group.Group("/ml-feedback", middleware.AuthMiddleware, middleware.DashboardMiddleware)
+
+	feedbackGroup.GET("/export", exportFeedback)
+}
+
+// exportFeedback streams labelled samples as JSON Lines, one sample per line,
+// for retraining the ML models.
+func exportFeedback(c echo.Context) error {
+	var since *time.Time
+	if sinceParam := c.QueryParam("since"); sinceParam != "" {
+		parsed, err := time.Parse(constant.DateOnly, sinceParam)
+		if err != nil {
+			return utils.ResponseJSON(c, "Invalid since date, use YYYY-MM-DD", nil, http.StatusBadRequest)
+		}
+		since = &parsed
+	}
+
+	var (
+		samples    []interface{}
+		statusCode int
+		err        error
+	)
+	exportType := c.QueryParam("type")
+	switch exportType {
+	case constant.MLResultFaceShape:
+		var data []datastruct.FaceShapeTrainingSample
+		data, statusCode, err = repository.GetFaceShapeTrainingSamples(since)
+		for _, v := range data {
+			samples = append(samples, v)
+		}
+	case constant.MLResultPersonality:
+		var data []datastruct.PersonalityTrainingSample
+		data, statusCode, err = repository.GetPersonalityTrainingSamples(since)
+		for _, v := range data {
+			samples = append(samples, v)
+		}
+	default:
+		return utils.ResponseJSON(c, "Type must be face_shape or personality", nil, http.StatusBadRequest)
+	}
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	filename := fmt.Sprintf("%s_feedback_%s.jsonl", exportType, time.Now().Format("20060102"))
+	c.Response().Header().Set(echo.HeaderContentType, "application/x-ndjson")
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	c.Response().WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Response())
+	for _, v := range samples {
+		if err := encoder.Encode(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
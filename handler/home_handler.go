@@ -42,7 +42,9 @@ func getHome(c echo.Context) error {
 }
 
 func getHomeMerchant(c echo.Context) error {
-	data, statusCode, err := repository.GetHomeMerchant()
+	hideOutOfStock := c.QueryParam("hide_out_of_stock") == "true"
+
+	data, statusCode, err := repository.GetHomeMerchant(hideOutOfStock)
 	if err != nil {
 		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
 	}
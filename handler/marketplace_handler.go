@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/yusufwib/arvigo-backend/datastruct"
+	"github.com/yusufwib/arvigo-backend/middleware"
+	"github.com/yusufwib/arvigo-backend/repository"
+	"github.com/yusufwib/arvigo-backend/utils"
+)
+
+func RegisterMarketplaceRoutes(e *echo.Echo) {
+	v1Group := e.Group("/v1")
+	marketplaceGroup := v1Group.Group("/marketplaces", middleware.AuthMiddleware)
+
+	marketplaceGroup.GET("", getMarketplaces)
+	marketplaceGroup.POST("", createMarketplace, middleware.DashboardMiddleware)
+	marketplaceGroup.PUT("/:id", updateMarketplace, middleware.DashboardMiddleware)
+	marketplaceGroup.DELETE("/:id", deleteMarketplace, middleware.DashboardMiddleware)
+}
+
+func getMarketplaces(c echo.Context) error {
+	includeInactive := c.QueryParam("include_inactive") == "true"
+
+	data, statusCode, err := repository.GetMarketplaces(includeInactive)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success get data", data, statusCode)
+}
+
+func createMarketplace(c echo.Context) error {
+	data, err := bindMarketplaceInput(c)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, http.StatusBadRequest)
+	}
+
+	validationErrors := utils.ValidateStruct(data)
+	if len(validationErrors) > 0 {
+		return utils.ResponseJSON(c, "The data is not valid", validationErrors, http.StatusBadRequest)
+	}
+
+	res, statusCode, err := repository.CreateMarketplace(data)
+	if err != nil {
+		return utils.ResponseJSON(c, "Failed create marketplace", err.Error(), statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Marketplace created", res, statusCode)
+}
+
+func updateMarketplace(c echo.Context) error {
+	marketplaceID := utils.StrToUint64(c.Param("id"), 0)
+	if marketplaceID == 0 {
+		return utils.ResponseJSON(c, "Invalid marketplace ID", nil, http.StatusBadRequest)
+	}
+
+	data, err := bindMarketplaceInput(c)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, http.StatusBadRequest)
+	}
+
+	validationErrors := utils.ValidateStruct(data)
+	if len(validationErrors) > 0 {
+		return utils.ResponseJSON(c, "The data is not valid", validationErrors, http.StatusBadRequest)
+	}
+
+	res, statusCode, err := repository.UpdateMarketplace(marketplaceID, data)
+	if err != nil {
+		return utils.ResponseJSON(c, "Failed update marketplace", err.Error(), statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Marketplace updated", res, statusCode)
+}
+
+func deleteMarketplace(c echo.Context) error {
+	marketplaceID := utils.StrToUint64(c.Param("id"), 0)
+	if marketplaceID == 0 {
+		return utils.ResponseJSON(c, "Invalid marketplace ID", nil, http.StatusBadRequest)
+	}
+
+	statusCode, err := repository.DeleteMarketplace(marketplaceID)
+	if err != nil {
+		return utils.ResponseJSON(c, "Failed delete marketplace", err.Error(), statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Marketplace deleted", nil, statusCode)
+}
+
+// bindMarketplaceInput accepts JSON or a multipart form, the latter carrying
+// an optional logo in the image field.
+func bindMarketplaceInput(c echo.Context) (data datastruct.MarketplaceInput, err error) {
+	if err = c.Bind(&data); err != nil {
+		return
+	}
+
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		form, err := c.MultipartForm()
+		if err != nil {
+			return data, err
+		}
+		if images := form.File["image"]; len(images) > 0 {
+			data.Image = images[0]
+		}
+	}
+
+	return
+}
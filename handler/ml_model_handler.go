@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/yusufwib/arvigo-backend/middleware"
+	"github.com/yusufwib/arvigo-backend/repository"
+	"github.com/yusufwib/arvigo-backend/utils"
+)
+
+func RegisterMLModelRoutes(e *echo.Echo) {
+	v1Group := e.Group("/v1")
+	mlModelGroup := v1Group.Group("/ml-models", middleware.AuthMiddleware, middleware.DashboardMiddleware)
+
+	mlModelGroup.GET("/report", getModelVersionReport)
+	mlModelGroup.GET("/affected-users", getModelAffectedUsers)
+}
+
+func getModelVersionReport(c echo.Context) error {
+	data, statusCode, err := repository.GetModelVersionReport(c.QueryParam("type"))
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", data, statusCode)
+}
+
+func getModelAffectedUsers(c echo.Context) error {
+	modelVersion := strings.TrimSpace(c.QueryParam("model_version"))
+	if modelVersion == "" {
+		return utils.ResponseJSON(c, "Model version is required", nil, http.StatusBadRequest)
+	}
+
+	data, statusCode, err := repository.GetModelAffectedUsers(c.QueryParam("type"), modelVersion)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", data, statusCode)
+}
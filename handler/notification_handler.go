@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/yusufwib/arvigo-backend/datastruct"
+	"github.com/yusufwib/arvigo-backend/middleware"
+	"github.com/yusufwib/arvigo-backend/repository"
+	"github.com/yusufwib/arvigo-backend/utils"
+)
+
+func RegisterNotificationRoutes(e *echo.Echo) {
+	v1Group := e.Group("/v1")
+	notificationGroup := v1Group.Group("/notifications", middleware.AuthMiddleware)
+
+	notificationGroup.GET("", getUserNotifications)
+	notificationGroup.PUT("/:id/read", readNotification)
+}
+
+func getUserNotifications(c echo.Context) error {
+	userAuth := c.Get("userAuth").(*datastruct.UserAuth)
+	data, statusCode, err := repository.GetUserNotifications(userAuth.ID)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", data, statusCode)
+}
+
+func readNotification(c echo.Context) error {
+	notificationID := utils.StrToUint64(c.Param("id"), 0)
+	if notificationID == 0 {
+		return utils.ResponseJSON(c, "Invalid notification ID", nil, http.StatusBadRequest)
+	}
+
+	userAuth := c.Get("userAuth").(*datastruct.UserAuth)
+	statusCode, err := repository.ReadNotification(userAuth.ID, notificationID)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", nil, statusCode)
+}
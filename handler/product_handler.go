@@ -2,6 +2,8 @@ package handler
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 
@@ -18,17 +20,26 @@ func RegisterProductRoutes(e *echo.Echo) {
 	v1Group.GET("/merchants/product", getDashboardMerchant, middleware.AuthMiddleware)
 	productGroup := v1Group.Group("/products", middleware.AuthMiddleware)
 	productGroup.DELETE("/:id", delProductByID)
+	productGroup.POST("/compare", compareProducts)
 	initialProductGroup := productGroup.Group("/initials")
 	initialProductGroup.GET("/:id", getInitalProductByID)
 	initialProductGroup.GET("/marketplace/:id", getMarketplaceProductByID)
 
 	initialProductGroup.POST("", createInitialProductHandler)
 	initialProductGroup.PUT("/:id", updateInitialProductHandler)
+	initialProductGroup.PATCH("/:id", patchInitialProductHandler, middleware.DashboardMiddleware)
 	initialProductGroup.GET("/category/:id", getInitalProductByCategoryID)
+	initialProductGroup.GET("/:id/offers", getInitialProductOffers)
+	initialProductGroup.GET("/:id/similar", getSimilarProducts)
 
 	merchantProductGroup := productGroup.Group("/merchants")
 	merchantProductGroup.POST("", createMerchantProductHandler)
 	merchantProductGroup.PUT("", updateMerchantProduct)
+	merchantProductGroup.PUT("/:id", editMerchantProduct)
+	merchantProductGroup.GET("/:id/price-history", getPriceHistory)
+	merchantProductGroup.GET("/:id/stocks", getMerchantProductStocks)
+	merchantProductGroup.PUT("/:id/stocks", updateMerchantProductStocks)
+	merchantProductGroup.POST("/stocks/import", importMerchantStocks)
 	merchantProductGroup.PUT("/verify", verifyMerchantProduct)
 }
 
@@ -54,8 +65,11 @@ func createInitialProductHandler(c echo.Context) error {
 	}
 
 	data.Images = images
-	statusCode, err := repository.CreateInitialProduct(data)
+	duplicates, statusCode, err := repository.CreateInitialProduct(data)
 	if err != nil {
+		if len(duplicates) > 0 {
+			return utils.ResponseJSON(c, err.Error(), duplicates, statusCode)
+		}
 		return utils.ResponseJSON(c, "Failed create product", err.Error(), statusCode)
 	}
 
@@ -96,6 +110,33 @@ func updateInitialProductHandler(c echo.Context) error {
 	return utils.ResponseJSON(c, "Product updated", nil, statusCode)
 }
 
+func patchInitialProductHandler(c echo.Context) error {
+	pID := utils.StrToUint64(c.Param("id"), 0)
+	if pID == 0 {
+		return utils.ResponseJSON(c, "Invalid product ID", nil, http.StatusBadRequest)
+	}
+	var data datastruct.PatchInitialProductInput
+	if err := c.Bind(&data); err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, http.StatusBadRequest)
+	}
+
+	// new images are optional, so a plain form or JSON body is accepted too
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		form, err := c.MultipartForm()
+		if err != nil {
+			return utils.ResponseJSON(c, "Failed to parse form data", nil, http.StatusBadRequest)
+		}
+		data.Images = form.File["images"]
+	}
+
+	statusCode, err := repository.PatchInitialProduct(data, pID)
+	if err != nil {
+		return utils.ResponseJSON(c, "Failed update product", err.Error(), statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Product updated", nil, statusCode)
+}
+
 func createMerchantProductHandler(c echo.Context) error {
 	var data datastruct.CreateMerchantProductInput
 	if err := c.Bind(&data); err != nil {
@@ -119,8 +160,11 @@ func createMerchantProductHandler(c echo.Context) error {
 
 	data.Images = images
 
-	statusCode, err := repository.CreateMerchantProduct(data)
+	duplicates, statusCode, err := repository.CreateMerchantProduct(data)
 	if err != nil {
+		if len(duplicates) > 0 {
+			return utils.ResponseJSON(c, err.Error(), duplicates, statusCode)
+		}
 		return utils.ResponseJSON(c, "Failed create product", err.Error(), statusCode)
 	}
 
@@ -173,6 +217,67 @@ func getInitalProductByID(c echo.Context) error {
 	return utils.ResponseJSON(c, "Success", data, statusCode)
 }
 
+func getInitialProductOffers(c echo.Context) error {
+	pID := utils.StrToUint64(c.Param("id"), 0)
+	if pID == 0 {
+		return utils.ResponseJSON(c, "Invalid product ID", nil, http.StatusBadRequest)
+	}
+
+	var lat, lng *float64
+	if c.QueryParam("lat") != "" || c.QueryParam("lng") != "" {
+		latValue, latErr := strconv.ParseFloat(c.QueryParam("lat"), 64)
+		lngValue, lngErr := strconv.ParseFloat(c.QueryParam("lng"), 64)
+		if latErr != nil || lngErr != nil {
+			return utils.ResponseJSON(c, "Invalid lat or lng", nil, http.StatusBadRequest)
+		}
+		lat, lng = &latValue, &lngValue
+	}
+
+	hideOutOfStock := c.QueryParam("hide_out_of_stock") == "true"
+
+	data, statusCode, err := repository.GetInitialProductOffers(pID, c.QueryParam("sort"), lat, lng, hideOutOfStock)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", data, statusCode)
+}
+
+func getSimilarProducts(c echo.Context) error {
+	pID := utils.StrToUint64(c.Param("id"), 0)
+	if pID == 0 {
+		return utils.ResponseJSON(c, "Invalid product ID", nil, http.StatusBadRequest)
+	}
+
+	limit := utils.StrToInt(c.QueryParam("limit"), 10)
+
+	data, statusCode, err := repository.GetSimilarProducts(pID, limit)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", data, statusCode)
+}
+
+func compareProducts(c echo.Context) error {
+	var data datastruct.CompareProductsInput
+	if err := c.Bind(&data); err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, http.StatusBadRequest)
+	}
+
+	validationErrors := utils.ValidateStruct(data)
+	if len(validationErrors) > 0 {
+		return utils.ResponseJSON(c, "The data is not valid", validationErrors, http.StatusBadRequest)
+	}
+
+	res, statusCode, err := repository.CompareProducts(data.ProductIDs)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", res, statusCode)
+}
+
 func getMarketplaceProductByID(c echo.Context) error {
 	pID := utils.StrToUint64(c.Param("id"), 0)
 	if pID == 0 {
@@ -225,6 +330,104 @@ func updateMerchantProduct(c echo.Context) error {
 	return utils.ResponseJSON(c, "Product updated", nil, statusCode)
 }
 
+func editMerchantProduct(c echo.Context) error {
+	pID := utils.StrToUint64(c.Param("id"), 0)
+	if pID == 0 {
+		return utils.ResponseJSON(c, "Invalid product ID", nil, http.StatusBadRequest)
+	}
+	var data datastruct.EditMerchantProductInput
+	if err := c.Bind(&data); err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, http.StatusBadRequest)
+	}
+
+	// replacement images are optional, so a plain form or JSON body is accepted too
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		form, err := c.MultipartForm()
+		if err != nil {
+			return utils.ResponseJSON(c, "Failed to parse form data", nil, http.StatusBadRequest)
+		}
+		data.Images = form.File["images"]
+	}
+
+	userAuth := c.Get("userAuth").(*datastruct.UserAuth)
+	res, statusCode, err := repository.EditMerchantProduct(data, pID, userAuth.ID)
+	if err != nil {
+		return utils.ResponseJSON(c, "Failed update product", err.Error(), statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Product updated", res, statusCode)
+}
+
+func getPriceHistory(c echo.Context) error {
+	pID := utils.StrToUint64(c.Param("id"), 0)
+	if pID == 0 {
+		return utils.ResponseJSON(c, "Invalid product ID", nil, http.StatusBadRequest)
+	}
+	days := utils.StrToInt(c.QueryParam("days"), 0)
+
+	data, statusCode, err := repository.GetPriceHistory(pID, days)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", data, statusCode)
+}
+
+func getMerchantProductStocks(c echo.Context) error {
+	pID := utils.StrToUint64(c.Param("id"), 0)
+	if pID == 0 {
+		return utils.ResponseJSON(c, "Invalid product ID", nil, http.StatusBadRequest)
+	}
+
+	userAuth := c.Get("userAuth").(*datastruct.UserAuth)
+	data, statusCode, err := repository.GetMerchantProductStocks(pID, userAuth.ID)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", data, statusCode)
+}
+
+func updateMerchantProductStocks(c echo.Context) error {
+	pID := utils.StrToUint64(c.Param("id"), 0)
+	if pID == 0 {
+		return utils.ResponseJSON(c, "Invalid product ID", nil, http.StatusBadRequest)
+	}
+
+	var data datastruct.StockInput
+	if err := c.Bind(&data); err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, http.StatusBadRequest)
+	}
+
+	validationErrors := utils.ValidateStruct(data)
+	if len(validationErrors) > 0 {
+		return utils.ResponseJSON(c, "The data is not valid", validationErrors, http.StatusBadRequest)
+	}
+
+	userAuth := c.Get("userAuth").(*datastruct.UserAuth)
+	statusCode, err := repository.UpdateMerchantProductStocks(pID, userAuth.ID, data)
+	if err != nil {
+		return utils.ResponseJSON(c, "Failed update stock", err.Error(), statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Stock updated", nil, statusCode)
+}
+
+func importMerchantStocks(c echo.Context) error {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return utils.ResponseJSON(c, "File must be filled", nil, http.StatusBadRequest)
+	}
+
+	userAuth := c.Get("userAuth").(*datastruct.UserAuth)
+	res, statusCode, err := repository.ImportMerchantStocks(userAuth.ID, file)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), res, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Stock imported", res, statusCode)
+}
+
 func delProductByID(c echo.Context) error {
 	pID := utils.StrToUint64(c.Param("id"), 0)
 	if pID == 0 {
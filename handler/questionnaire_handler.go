@@ -15,10 +15,28 @@ func RegisterQuestionnaireRoutes(e *echo.Echo) {
 	questionnaireGroup := v1Group.Group("/questionnaires", middleware.AuthMiddleware)
 	questionnaireGroup.GET("", getQuestionnaire)
 	questionnaireGroup.POST("", generateQuestionnaireResult)
+	questionnaireGroup.POST("/feedback", submitPersonalityFeedback)
+	questionnaireGroup.GET("/progress", getQuestionnaireProgress)
+	questionnaireGroup.PUT("/answers", saveQuestionnaireAnswers)
+	questionnaireGroup.DELETE("/answers", deleteQuestionnaireAnswers)
+
+	versionGroup := v1Group.Group("/questionnaire-versions", middleware.AuthMiddleware, middleware.DashboardMiddleware)
+	versionGroup.GET("", getQuestionnaireVersions)
+	versionGroup.POST("", createQuestionnaireVersion)
+	versionGroup.PUT("/:id/active", activateQuestionnaireVersion)
+	versionGroup.GET("/:id/questions", getQuestionnaireItems)
+	versionGroup.POST("/:id/questions", createQuestionnaireItem)
+	versionGroup.PUT("/:id/questions/:question_id", updateQuestionnaireItem)
+	versionGroup.DELETE("/:id/questions/:question_id", deleteQuestionnaireItem)
 }
 
 func getQuestionnaire(c echo.Context) error {
-	data, statusCode, err := repository.GetQuestionnaire()
+	language := c.QueryParam("lang")
+	if language == "" {
+		language = c.Request().Header.Get("Accept-Language")
+	}
+
+	data, statusCode, err := repository.GetQuestionnaire(language)
 	if err != nil {
 		return utils.ResponseJSON(c, err.Error(), data, statusCode)
 	}
@@ -45,3 +63,178 @@ func generateQuestionnaireResult(c echo.Context) error {
 
 	return utils.ResponseJSON(c, "Success generate data", result, http.StatusOK)
 }
+
+func getQuestionnaireProgress(c echo.Context) error {
+	userAuth := c.Get("userAuth").(*datastruct.UserAuth)
+	data, statusCode, err := repository.GetQuestionnaireProgress(userAuth.ID)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success get data", data, statusCode)
+}
+
+func saveQuestionnaireAnswers(c echo.Context) error {
+	var data datastruct.QuestionnaireRequest
+	if err := c.Bind(&data); err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, http.StatusBadRequest)
+	}
+
+	userAuth := c.Get("userAuth").(*datastruct.UserAuth)
+	res, statusCode, err := repository.SaveQuestionnaireAnswers(userAuth.ID, data)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Answers saved", res, statusCode)
+}
+
+func deleteQuestionnaireAnswers(c echo.Context) error {
+	userAuth := c.Get("userAuth").(*datastruct.UserAuth)
+	statusCode, err := repository.DeleteQuestionnaireAnswers(userAuth.ID)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", nil, statusCode)
+}
+
+func submitPersonalityFeedback(c echo.Context) error {
+	var data datastruct.PersonalityFeedbackInput
+	if err := c.Bind(&data); err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, http.StatusBadRequest)
+	}
+
+	validationErrors := utils.ValidateStruct(data)
+	if len(validationErrors) > 0 {
+		return utils.ResponseJSON(c, "The data is not valid", validationErrors, http.StatusBadRequest)
+	}
+
+	userAuth := c.Get("userAuth").(*datastruct.UserAuth)
+	res, statusCode, err := repository.SubmitPersonalityFeedback(userAuth.ID, data)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Thanks for your feedback", res, statusCode)
+}
+
+func getQuestionnaireVersions(c echo.Context) error {
+	data, statusCode, err := repository.GetQuestionnaireVersions()
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success get data", data, statusCode)
+}
+
+func createQuestionnaireVersion(c echo.Context) error {
+	var data datastruct.QuestionnaireVersionInput
+	if err := c.Bind(&data); err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, http.StatusBadRequest)
+	}
+
+	validationErrors := utils.ValidateStruct(data)
+	if len(validationErrors) > 0 {
+		return utils.ResponseJSON(c, "The data is not valid", validationErrors, http.StatusBadRequest)
+	}
+
+	res, statusCode, err := repository.CreateQuestionnaireVersion(data)
+	if err != nil {
+		return utils.ResponseJSON(c, "Failed create questionnaire version", err.Error(), statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Questionnaire version created", res, statusCode)
+}
+
+func activateQuestionnaireVersion(c echo.Context) error {
+	versionID := utils.StrToUint64(c.Param("id"), 0)
+	if versionID == 0 {
+		return utils.ResponseJSON(c, "Invalid questionnaire version ID", nil, http.StatusBadRequest)
+	}
+
+	res, statusCode, err := repository.ActivateQuestionnaireVersion(versionID)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Questionnaire version activated", res, statusCode)
+}
+
+func getQuestionnaireItems(c echo.Context) error {
+	versionID := utils.StrToUint64(c.Param("id"), 0)
+	if versionID == 0 {
+		return utils.ResponseJSON(c, "Invalid questionnaire version ID", nil, http.StatusBadRequest)
+	}
+
+	data, statusCode, err := repository.GetQuestionnaireItems(versionID)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success get data", data, statusCode)
+}
+
+func createQuestionnaireItem(c echo.Context) error {
+	versionID := utils.StrToUint64(c.Param("id"), 0)
+	if versionID == 0 {
+		return utils.ResponseJSON(c, "Invalid questionnaire version ID", nil, http.StatusBadRequest)
+	}
+
+	var data datastruct.QuestionnaireItemInput
+	if err := c.Bind(&data); err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, http.StatusBadRequest)
+	}
+
+	validationErrors := utils.ValidateStruct(data)
+	if len(validationErrors) > 0 {
+		return utils.ResponseJSON(c, "The data is not valid", validationErrors, http.StatusBadRequest)
+	}
+
+	res, statusCode, err := repository.CreateQuestionnaireItem(versionID, data)
+	if err != nil {
+		return utils.ResponseJSON(c, "Failed create question", err.Error(), statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Question created", res, statusCode)
+}
+
+func updateQuestionnaireItem(c echo.Context) error {
+	versionID := utils.StrToUint64(c.Param("id"), 0)
+	questionID := utils.StrToUint64(c.Param("question_id"), 0)
+	if versionID == 0 || questionID == 0 {
+		return utils.ResponseJSON(c, "Invalid questionnaire version or question ID", nil, http.StatusBadRequest)
+	}
+
+	var data datastruct.QuestionnaireItemInput
+	if err := c.Bind(&data); err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, http.StatusBadRequest)
+	}
+
+	validationErrors := utils.ValidateStruct(data)
+	if len(validationErrors) > 0 {
+		return utils.ResponseJSON(c, "The data is not valid", validationErrors, http.StatusBadRequest)
+	}
+
+	res, statusCode, err := repository.UpdateQuestionnaireItem(versionID, questionID, data)
+	if err != nil {
+		return utils.ResponseJSON(c, "Failed update question", err.Error(), statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Question updated", res, statusCode)
+}
+
+func deleteQuestionnaireItem(c echo.Context) error {
+	versionID := utils.StrToUint64(c.Param("id"), 0)
+	questionID := utils.StrToUint64(c.Param("question_id"), 0)
+	if versionID == 0 || questionID == 0 {
+		return utils.ResponseJSON(c, "Invalid questionnaire version or question ID", nil, http.StatusBadRequest)
+	}
+
+	statusCode, err := repository.DeleteQuestionnaireItem(versionID, questionID)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", nil, statusCode)
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/yusufwib/arvigo-backend/datastruct"
+	"github.com/yusufwib/arvigo-backend/middleware"
+	"github.com/yusufwib/arvigo-backend/repository"
+	"github.com/yusufwib/arvigo-backend/utils"
+)
+
+func RegisterTagRoutes(e *echo.Echo) {
+	v1Group := e.Group("/v1")
+	tagGroup := v1Group.Group("/tags", middleware.AuthMiddleware)
+
+	tagGroup.GET("", getTags)
+	tagGroup.POST("", createTag, middleware.DashboardMiddleware)
+	tagGroup.PUT("/:id", updateTag, middleware.DashboardMiddleware)
+	tagGroup.DELETE("/:id", deleteTag, middleware.DashboardMiddleware)
+
+	tagGroup.GET("/mappings", getTagMappings)
+	tagGroup.PUT("/mappings/face-shapes/:id", updateFaceShapeTags, middleware.DashboardMiddleware)
+	tagGroup.PUT("/mappings/personalities/:id", updatePersonalityTags, middleware.DashboardMiddleware)
+}
+
+func getTags(c echo.Context) error {
+	categoryID := utils.StrToUint64(c.QueryParam("category_id"), 0)
+
+	data, statusCode, err := repository.GetTags(categoryID)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", data, statusCode)
+}
+
+func createTag(c echo.Context) error {
+	var data datastruct.TagInput
+	if err := c.Bind(&data); err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, http.StatusBadRequest)
+	}
+
+	validationErrors := utils.ValidateStruct(data)
+	if len(validationErrors) > 0 {
+		return utils.ResponseJSON(c, "The data is not valid", validationErrors, http.StatusBadRequest)
+	}
+
+	res, statusCode, err := repository.CreateTag(data)
+	if err != nil {
+		return utils.ResponseJSON(c, "Failed create tag", err.Error(), statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Tag created", res, statusCode)
+}
+
+func updateTag(c echo.Context) error {
+	tagID := utils.StrToUint64(c.Param("id"), 0)
+	if tagID == 0 {
+		return utils.ResponseJSON(c, "Invalid tag ID", nil, http.StatusBadRequest)
+	}
+
+	var data datastruct.TagInput
+	if err := c.Bind(&data); err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, http.StatusBadRequest)
+	}
+
+	validationErrors := utils.ValidateStruct(data)
+	if len(validationErrors) > 0 {
+		return utils.ResponseJSON(c, "The data is not valid", validationErrors, http.StatusBadRequest)
+	}
+
+	res, statusCode, err := repository.UpdateTag(tagID, data)
+	if err != nil {
+		return utils.ResponseJSON(c, "Failed update tag", err.Error(), statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Tag updated", res, statusCode)
+}
+
+func deleteTag(c echo.Context) error {
+	tagID := utils.StrToUint64(c.Param("id"), 0)
+	if tagID == 0 {
+		return utils.ResponseJSON(c, "Invalid tag ID", nil, http.StatusBadRequest)
+	}
+
+	statusCode, err := repository.DeleteTag(tagID)
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", nil, statusCode)
+}
+
+func getTagMappings(c echo.Context) error {
+	data, statusCode, err := repository.GetTagMappings()
+	if err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Success", data, statusCode)
+}
+
+func updateFaceShapeTags(c echo.Context) error {
+	faceShapeID := utils.StrToUint64(c.Param("id"), 0)
+	if faceShapeID == 0 {
+		return utils.ResponseJSON(c, "Invalid face shape ID", nil, http.StatusBadRequest)
+	}
+
+	var data datastruct.TagMappingInput
+	if err := c.Bind(&data); err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, http.StatusBadRequest)
+	}
+
+	statusCode, err := repository.UpdateFaceShapeTags(faceShapeID, data)
+	if err != nil {
+		return utils.ResponseJSON(c, "Failed update face shape tags", err.Error(), statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Face shape tags updated", nil, statusCode)
+}
+
+func updatePersonalityTags(c echo.Context) error {
+	traitID := utils.StrToUint64(c.Param("id"), 0)
+	if traitID == 0 {
+		return utils.ResponseJSON(c, "Invalid personality ID", nil, http.StatusBadRequest)
+	}
+
+	var data datastruct.TagMappingInput
+	if err := c.Bind(&data); err != nil {
+		return utils.ResponseJSON(c, err.Error(), nil, http.StatusBadRequest)
+	}
+
+	statusCode, err := repository.UpdatePersonalityTags(traitID, data)
+	if err != nil {
+		return utils.ResponseJSON(c, "Failed update personality tags", err.Error(), statusCode)
+	}
+
+	return utils.ResponseJSON(c, "Personality tags updated", nil, statusCode)
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,6 +16,8 @@ import (
 
 	"github.com/yusufwib/arvigo-backend/pkg/cache"
 	"github.com/yusufwib/arvigo-backend/pkg/database"
+	"github.com/yusufwib/arvigo-backend/pkg/storage"
+	"github.com/yusufwib/arvigo-backend/repository"
 	"github.com/yusufwib/arvigo-backend/route"
 )
 
@@ -58,6 +61,20 @@ func main() {
 		sqlDB.Close()
 	}()
 
+	// Initialize the shared object storage client; uploads retry a failed
+	// initialization, so the API still starts while storage is unreachable
+	if _, err := storage.Default(); err != nil {
+		log.Println("Failed to initialize storage:", err)
+	}
+	defer func() {
+		if err := storage.Close(); err != nil {
+			log.Println("Failed to close storage client:", err)
+		}
+	}()
+
+	// Start the background face shape analysis workers
+	stopFaceShapeWorkers := repository.StartFaceShapeWorkers()
+
 	// Create a new Echo instance
 	e := echo.New()
 	// Add middleware
@@ -65,6 +82,11 @@ func main() {
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORS())
 
+	// Serve uploaded files when using the local storage driver
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("STORAGE_DRIVER")), storage.DriverLocal) {
+		e.Static(storage.LocalURLPrefix, storage.LocalDir())
+	}
+
 	// Register routes
 	route.RegisterAllRoutes(e)
 
@@ -100,5 +122,7 @@ func main() {
 		log.Fatal("Error shutting down the server:", err)
 	}
 
+	stopFaceShapeWorkers()
+
 	log.Println("Server gracefully stopped")
 }
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yusufwib/arvigo-backend/constant"
+	"github.com/yusufwib/arvigo-backend/datastruct"
+)
+
+// DashboardMiddleware only lets admin dashboard accounts through. It must run
+// after AuthMiddleware, which sets userAuth.
+func DashboardMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userAuth, ok := c.Get("userAuth").(*datastruct.UserAuth)
+		if !ok || userAuth.RoleID != constant.Dashboard {
+			return echo.NewHTTPError(http.StatusForbidden, "Only admins can access this resource")
+		}
+
+		return next(c)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -40,3 +41,27 @@ func ConnectRedis() (*redis.Client, error) {
 
 	return client, nil
 }
+
+var (
+	defaultClient *redis.Client
+	defaultMu     sync.Mutex
+)
+
+// Default returns a Redis client shared by callers that use Redis as a cache.
+// It connects on first use; a failed connection is retried on the next call.
+func Default() (*redis.Client, error) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	if defaultClient != nil {
+		return defaultClient, nil
+	}
+
+	client, err := ConnectRedis()
+	if err != nil {
+		return nil, err
+	}
+
+	defaultClient = client
+	return defaultClient, nil
+}
@@ -46,4 +46,36 @@ drop table if exists detail_product_marketplace_clicked;
 
 drop table if exists detail_user_subscription_products;
 
+drop table if exists assets;
+
+drop table if exists price_histories;
+
+drop table if exists notifications;
+
+drop table if exists personality_traits;
+
+drop table if exists detail_personality_tags;
+
+drop table if exists category_attributes;
+
+drop table if exists detail_product_stocks;
+
+drop table if exists product_image_hashes;
+
+drop table if exists face_shape_jobs;
+
+drop table if exists face_scans;
+
+drop table if exists face_scan_feedbacks;
+
+drop table if exists personality_feedbacks;
+
+drop table if exists questionnaire_versions;
+
+drop table if exists personality_questionnaire_translations;
+
+drop table if exists user_personality_answers;
+
+drop table if exists questionnaire_draft_answers;
+
 drop table if exists schema_migrations;
\ No newline at end of file
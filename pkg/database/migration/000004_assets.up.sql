@@ -0,0 +1,18 @@
+-- auto-generated definition
+DROP TABLE IF EXISTS assets;
+CREATE TABLE assets
+(
+    id int unsigned auto_increment primary key,
+    object_name varchar(300) not null,
+    url varchar(500) not null,
+    kind varchar(20) not null,
+    owner_id int default 0 not null,
+    size int default 0 not null,
+    referenced_at timestamp null,
+    deleted_at timestamp null,
+    created_at timestamp default CURRENT_TIMESTAMP null,
+    updated_at timestamp default CURRENT_TIMESTAMP null on update CURRENT_TIMESTAMP,
+    constraint idx_unique_assets1 unique (object_name)
+);
+CREATE INDEX idx_assets_1 ON assets (deleted_at, kind);
+CREATE INDEX idx_assets_2 ON assets (url);
@@ -0,0 +1,46 @@
+-- auto-generated definition
+DROP TABLE IF EXISTS price_histories;
+CREATE TABLE price_histories
+(
+    id int unsigned auto_increment primary key,
+    product_id int not null,
+    price double default 0 not null,
+    previous_price double null,
+    created_at timestamp default CURRENT_TIMESTAMP null,
+    updated_at timestamp default CURRENT_TIMESTAMP null on update CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_price_histories_1 ON price_histories (product_id, created_at);
+
+
+-- auto-generated definition
+DROP TABLE IF EXISTS notifications;
+CREATE TABLE notifications
+(
+    id int unsigned auto_increment primary key,
+    user_id int not null,
+    type varchar(30) not null,
+    title varchar(100) not null,
+    body text not null,
+    reference_id int default 0 not null,
+    read_at timestamp null,
+    created_at timestamp default CURRENT_TIMESTAMP null,
+    updated_at timestamp default CURRENT_TIMESTAMP null on update CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_notifications_1 ON notifications (user_id, read_at);
+
+
+ALTER TABLE wishlists
+    ADD COLUMN saved_price double null,
+    ADD COLUMN alerted_price double null;
+
+-- backfill the price at save time for existing listing wishlists
+UPDATE wishlists w
+    JOIN detail_product_marketplaces dpm ON dpm.id = w.detail_product_marketplace_id
+    JOIN products p ON p.id = dpm.product_id
+SET w.saved_price = p.price
+WHERE w.detail_product_marketplace_id IS NOT NULL;
+
+INSERT INTO price_histories (product_id, price, previous_price, created_at, updated_at)
+SELECT id, price, NULL, updated_at, updated_at
+FROM products
+WHERE merchant_id != 0;
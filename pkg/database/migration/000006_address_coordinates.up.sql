@@ -0,0 +1,3 @@
+ALTER TABLE addresses
+    ADD COLUMN latitude double null,
+    ADD COLUMN longitude double null;
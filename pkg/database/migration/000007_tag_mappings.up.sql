@@ -0,0 +1,52 @@
+-- auto-generated definition
+DROP TABLE IF EXISTS personality_traits;
+CREATE TABLE personality_traits
+(
+    id int unsigned auto_increment primary key,
+    code varchar(30) not null,
+    name varchar(50) not null,
+    created_at timestamp default CURRENT_TIMESTAMP null,
+    updated_at timestamp default CURRENT_TIMESTAMP null on update CURRENT_TIMESTAMP,
+    constraint idx_unique_personality_traits1 unique (code)
+);
+
+-- auto-generated definition
+DROP TABLE IF EXISTS detail_personality_tags;
+CREATE TABLE detail_personality_tags
+(
+    id int unsigned auto_increment primary key,
+    personality_trait_id int not null,
+    tag_id int not null,
+    created_at timestamp default CURRENT_TIMESTAMP null,
+    updated_at timestamp default CURRENT_TIMESTAMP null on update CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_detail_personality_tags_1 ON detail_personality_tags (personality_trait_id, tag_id);
+
+CREATE INDEX idx_detail_face_shape_tags_1 ON detail_face_shape_tags (face_shape_id, tag_id);
+
+-- code matches the trait field returned by the personality model
+INSERT INTO personality_traits (code, name, created_at, updated_at)
+VALUES
+    ('Extraversion', 'Extraversion', DEFAULT, DEFAULT),
+    ('Neurotic', 'Neurotic', DEFAULT, DEFAULT),
+    ('Agreeable', 'Agreeable', DEFAULT, DEFAULT),
+    ('Conscientious', 'Conscientious', DEFAULT, DEFAULT),
+    ('Openness', 'Openness', DEFAULT, DEFAULT);
+
+INSERT INTO detail_personality_tags (personality_trait_id, tag_id, created_at, updated_at)
+VALUES
+    (1, 7, DEFAULT, DEFAULT),
+    (1, 8, DEFAULT, DEFAULT),
+    (1, 9, DEFAULT, DEFAULT),
+    (2, 10, DEFAULT, DEFAULT),
+    (2, 11, DEFAULT, DEFAULT),
+    (2, 12, DEFAULT, DEFAULT),
+    (3, 13, DEFAULT, DEFAULT),
+    (3, 14, DEFAULT, DEFAULT),
+    (3, 15, DEFAULT, DEFAULT),
+    (4, 16, DEFAULT, DEFAULT),
+    (4, 17, DEFAULT, DEFAULT),
+    (4, 18, DEFAULT, DEFAULT),
+    (5, 19, DEFAULT, DEFAULT),
+    (5, 20, DEFAULT, DEFAULT),
+    (5, 8, DEFAULT, DEFAULT);
@@ -0,0 +1,30 @@
+ALTER TABLE categories
+    ADD COLUMN parent_id int default 0 not null,
+    ADD COLUMN recommendation_source varchar(20) default '' not null;
+CREATE INDEX idx_categories_1 ON categories (parent_id);
+
+-- drives the face shape and personality sections on home
+UPDATE categories SET recommendation_source = 'face_shape' WHERE id = 1;
+UPDATE categories SET recommendation_source = 'personality' WHERE id = 2;
+
+
+-- auto-generated definition
+DROP TABLE IF EXISTS category_attributes;
+CREATE TABLE category_attributes
+(
+    id int unsigned auto_increment primary key,
+    category_id int not null,
+    code varchar(50) not null,
+    name varchar(100) not null,
+    type varchar(20) not null,
+    options text null,
+    unit varchar(20) default '' not null,
+    is_required int default 0 not null,
+    created_at timestamp default CURRENT_TIMESTAMP null,
+    updated_at timestamp default CURRENT_TIMESTAMP null on update CURRENT_TIMESTAMP,
+    constraint idx_unique_category_attributes1 unique (category_id, code)
+);
+
+
+ALTER TABLE products
+    ADD COLUMN attributes text null;
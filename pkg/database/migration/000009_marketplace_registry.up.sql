@@ -0,0 +1,15 @@
+ALTER TABLE marketplaces
+    ADD COLUMN website_url varchar(200) default '' not null,
+    ADD COLUMN host_patterns text null,
+    ADD COLUMN deep_link_template varchar(300) default '' not null,
+    ADD COLUMN is_active int default 1 not null;
+
+-- host patterns are comma separated; a pattern also matches its subdomains and * matches any host
+UPDATE marketplaces SET host_patterns = '*' WHERE name = 'Website';
+UPDATE marketplaces SET website_url = 'https://www.tokopedia.com', host_patterns = 'tokopedia.com,tokopedia.link', deep_link_template = 'tokopedia://{path}' WHERE name = 'Tokopedia';
+UPDATE marketplaces SET website_url = 'https://shopee.co.id', host_patterns = 'shopee.co.id,shp.ee', deep_link_template = 'shopeeid://{path}' WHERE name = 'Shopee';
+
+-- previously only known to the code
+INSERT INTO marketplaces (name, image, website_url, host_patterns, deep_link_template, created_at, updated_at)
+SELECT 'Bukalapak', '', 'https://www.bukalapak.com', 'bukalapak.com', 'bukalapak://{path}', NOW(), NOW()
+WHERE NOT EXISTS (SELECT 1 FROM marketplaces WHERE name = 'Bukalapak');
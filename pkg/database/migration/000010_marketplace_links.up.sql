@@ -0,0 +1,15 @@
+ALTER TABLE detail_product_marketplaces
+    MODIFY COLUMN link varchar(500) null,
+    ADD COLUMN shop_identifier varchar(100) null,
+    ADD COLUMN product_identifier varchar(100) null;
+CREATE INDEX idx_detail_product_marketplaces_3 ON detail_product_marketplaces (marketplace_id, product_identifier);
+
+ALTER TABLE marketplaces
+    ADD COLUMN short_link_hosts text null,
+    ADD COLUMN identifier_pattern varchar(300) default '' not null;
+
+-- short link hosts are resolved to the full listing URL before validation;
+-- identifier patterns run against the URL path and may capture (?P<shop>) and (?P<product>)
+UPDATE marketplaces SET host_patterns = 'tokopedia.com', short_link_hosts = 'tokopedia.link,tkp.me', identifier_pattern = '^/(?P<shop>[^/]+)/(?P<product>[^/]+)$' WHERE name = 'Tokopedia';
+UPDATE marketplaces SET host_patterns = 'shopee.co.id', short_link_hosts = 'shp.ee,shope.ee', identifier_pattern = '(?:-i\\.|/product/)(?P<shop>[0-9]+)[./](?P<product>[0-9]+)$' WHERE name = 'Shopee';
+UPDATE marketplaces SET short_link_hosts = 'bl.id', identifier_pattern = '^/p/(?:[^/]+/)*(?P<product>[0-9a-z]+)-[^/]*$' WHERE name = 'Bukalapak';
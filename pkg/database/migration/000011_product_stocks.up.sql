@@ -0,0 +1,12 @@
+-- auto-generated definition
+DROP TABLE IF EXISTS detail_product_stocks;
+CREATE TABLE detail_product_stocks
+(
+    id int unsigned auto_increment primary key,
+    detail_product_marketplace_id int not null,
+    detail_product_variant_id int not null,
+    quantity int default 0 not null,
+    created_at timestamp default CURRENT_TIMESTAMP null,
+    updated_at timestamp default CURRENT_TIMESTAMP null on update CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX idx_detail_product_stocks_1 ON detail_product_stocks (detail_product_marketplace_id, detail_product_variant_id);
@@ -0,0 +1,12 @@
+-- auto-generated definition
+DROP TABLE IF EXISTS product_image_hashes;
+CREATE TABLE product_image_hashes
+(
+    id int unsigned auto_increment primary key,
+    product_id int not null,
+    image_url varchar(500) not null,
+    hash bigint unsigned not null,
+    created_at timestamp default CURRENT_TIMESTAMP null,
+    updated_at timestamp default CURRENT_TIMESTAMP null on update CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_product_image_hashes_1 ON product_image_hashes (product_id);
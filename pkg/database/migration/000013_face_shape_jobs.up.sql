@@ -0,0 +1,21 @@
+-- auto-generated definition
+DROP TABLE IF EXISTS face_shape_jobs;
+CREATE TABLE face_shape_jobs
+(
+    id int unsigned auto_increment primary key,
+    user_id int not null,
+    status varchar(20) default 'pending' not null,
+    image_url varchar(500) not null,
+    object_name varchar(500) not null,
+    attempts int default 0 not null,
+    result varchar(50) null,
+    face_shape_id int null,
+    error_message varchar(255) null,
+    next_attempt_at timestamp null,
+    started_at timestamp null,
+    completed_at timestamp null,
+    created_at timestamp default CURRENT_TIMESTAMP null,
+    updated_at timestamp default CURRENT_TIMESTAMP null on update CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_face_shape_jobs_1 ON face_shape_jobs (user_id);
+CREATE INDEX idx_face_shape_jobs_2 ON face_shape_jobs (status, next_attempt_at);
@@ -0,0 +1,24 @@
+-- auto-generated definition
+DROP TABLE IF EXISTS face_scans;
+CREATE TABLE face_scans
+(
+    id int unsigned auto_increment primary key,
+    user_id int not null,
+    image_url varchar(500) null,
+    face_shape_id int not null,
+    confidence decimal(5, 4) null,
+    model_version varchar(50) null,
+    is_active tinyint(1) default 0 not null,
+    created_at timestamp default CURRENT_TIMESTAMP null,
+    updated_at timestamp default CURRENT_TIMESTAMP null on update CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_face_scans_1 ON face_scans (user_id, is_active);
+
+-- keep the shape of users who scanned before the history existed
+INSERT INTO face_scans (user_id, face_shape_id, is_active, created_at, updated_at)
+SELECT id, face_shape_id, 1, updated_at, updated_at
+FROM users
+WHERE is_complete_face_test = 1 AND face_shape_id IS NOT NULL AND face_shape_id <> 0;
+
+ALTER TABLE face_shape_jobs
+    ADD face_scan_id int null AFTER face_shape_id;
@@ -0,0 +1,30 @@
+-- auto-generated definition
+ALTER TABLE user_personalities
+    ADD model_version varchar(50) null AFTER opn_result;
+
+DROP TABLE IF EXISTS face_scan_feedbacks;
+CREATE TABLE face_scan_feedbacks
+(
+    id int unsigned auto_increment primary key,
+    face_scan_id int not null,
+    user_id int not null,
+    is_correct tinyint(1) not null,
+    corrected_face_shape_id int null,
+    comment varchar(255) null,
+    created_at timestamp default CURRENT_TIMESTAMP null,
+    updated_at timestamp default CURRENT_TIMESTAMP null on update CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX idx_face_scan_feedbacks_1 ON face_scan_feedbacks (face_scan_id);
+
+DROP TABLE IF EXISTS personality_feedbacks;
+CREATE TABLE personality_feedbacks
+(
+    id int unsigned auto_increment primary key,
+    user_personality_id int not null,
+    user_id int not null,
+    rating tinyint not null,
+    comment varchar(255) null,
+    created_at timestamp default CURRENT_TIMESTAMP null,
+    updated_at timestamp default CURRENT_TIMESTAMP null on update CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX idx_personality_feedbacks_1 ON personality_feedbacks (user_personality_id);
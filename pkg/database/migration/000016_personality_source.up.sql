@@ -0,0 +1,3 @@
+-- auto-generated definition
+ALTER TABLE user_personalities
+    ADD source varchar(20) default 'ml' not null AFTER model_version;
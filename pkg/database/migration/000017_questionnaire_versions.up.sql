@@ -0,0 +1,230 @@
+-- auto-generated definition
+DROP TABLE IF EXISTS questionnaire_versions;
+CREATE TABLE questionnaire_versions
+(
+    id int unsigned auto_increment primary key,
+    name varchar(100) not null,
+    version int unsigned not null,
+    is_active tinyint(1) default 0 not null,
+    created_at timestamp default CURRENT_TIMESTAMP null,
+    updated_at timestamp default CURRENT_TIMESTAMP null on update CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX idx_questionnaire_versions_1 ON questionnaire_versions (version);
+
+INSERT INTO questionnaire_versions (id, name, version, is_active, created_at, updated_at)
+VALUES (1, 'IPIP Big-Five Factor Markers', 1, 1, DEFAULT, DEFAULT);
+
+-- the existing questions become version 1
+ALTER TABLE personality_questionnaires
+    ADD questionnaire_version_id int not null default 1 AFTER id,
+    ADD trait varchar(3) not null default '' AFTER type,
+    ADD is_reverse_keyed tinyint(1) default 0 not null AFTER trait,
+    ADD sort_order int default 0 not null AFTER is_reverse_keyed;
+
+UPDATE personality_questionnaires
+SET trait            = LEFT(type, 3),
+    sort_order       = id,
+    is_reverse_keyed = type IN ('EXT2', 'EXT4', 'EXT6', 'EXT8', 'EXT10', 'EST2', 'EST4', 'AGR1', 'AGR3', 'AGR5', 'AGR7', 'CSN2', 'CSN4', 'CSN6', 'CSN8', 'OPN2', 'OPN4', 'OPN6');
+
+CREATE UNIQUE INDEX idx_personality_questionnaires_1 ON personality_questionnaires (questionnaire_version_id, type);
+
+-- auto-generated definition
+DROP TABLE IF EXISTS personality_questionnaire_translations;
+CREATE TABLE personality_questionnaire_translations
+(
+    id int unsigned auto_increment primary key,
+    questionnaire_id int not null,
+    language varchar(5) not null,
+    question text not null,
+    created_at timestamp default CURRENT_TIMESTAMP null,
+    updated_at timestamp default CURRENT_TIMESTAMP null on update CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX idx_personality_questionnaire_translations_1 ON personality_questionnaire_translations (questionnaire_id, language);
+
+INSERT INTO personality_questionnaire_translations (questionnaire_id, language, question)
+SELECT id, 'id', question
+FROM personality_questionnaires;
+
+INSERT INTO personality_questionnaire_translations (questionnaire_id, language, question)
+SELECT pq.id, 'en', en.question
+FROM personality_questionnaires pq
+         JOIN (SELECT 'EXT1' AS type, 'I am the life of the party' AS question
+               UNION ALL SELECT 'EXT2', 'I don''t talk a lot'
+               UNION ALL SELECT 'EXT3', 'I feel comfortable around people'
+               UNION ALL SELECT 'EXT4', 'I keep in the background'
+               UNION ALL SELECT 'EXT5', 'I start conversations'
+               UNION ALL SELECT 'EXT6', 'I have little to say'
+               UNION ALL SELECT 'EXT7', 'I talk to a lot of different people at parties'
+               UNION ALL SELECT 'EXT8', 'I don''t like to draw attention to myself'
+               UNION ALL SELECT 'EXT9', 'I don''t mind being the center of attention'
+               UNION ALL SELECT 'EXT10', 'I am quiet around strangers'
+               UNION ALL SELECT 'EST1', 'I get stressed out easily'
+               UNION ALL SELECT 'EST2', 'I am relaxed most of the time'
+               UNION ALL SELECT 'EST3', 'I worry about things'
+               UNION ALL SELECT 'EST4', 'I seldom feel blue'
+               UNION ALL SELECT 'EST5', 'I am easily disturbed'
+               UNION ALL SELECT 'EST6', 'I get upset easily'
+               UNION ALL SELECT 'EST7', 'I change my mood a lot'
+               UNION ALL SELECT 'EST8', 'I have frequent mood swings'
+               UNION ALL SELECT 'EST9', 'I get irritated easily'
+               UNION ALL SELECT 'EST10', 'I often feel blue'
+               UNION ALL SELECT 'AGR1', 'I feel little concern for others'
+               UNION ALL SELECT 'AGR2', 'I am interested in people'
+               UNION ALL SELECT 'AGR3', 'I insult people'
+               UNION ALL SELECT 'AGR4', 'I sympathize with others'' feelings'
+               UNION ALL SELECT 'AGR5', 'I am not interested in other people''s problems'
+               UNION ALL SELECT 'AGR6', 'I have a soft heart'
+               UNION ALL SELECT 'AGR7', 'I am not really interested in others'
+               UNION ALL SELECT 'AGR8', 'I take time out for others'
+               UNION ALL SELECT 'AGR9', 'I feel others'' emotions'
+               UNION ALL SELECT 'AGR10', 'I make people feel at ease'
+               UNION ALL SELECT 'CSN1', 'I am always prepared'
+               UNION ALL SELECT 'CSN2', 'I leave my belongings around'
+               UNION ALL SELECT 'CSN3', 'I pay attention to details'
+               UNION ALL SELECT 'CSN4', 'I make a mess of things'
+               UNION ALL SELECT 'CSN5', 'I get chores done right away'
+               UNION ALL SELECT 'CSN6', 'I often forget to put things back in their proper place'
+               UNION ALL SELECT 'CSN7', 'I like order'
+               UNION ALL SELECT 'CSN8', 'I shirk my duties'
+               UNION ALL SELECT 'CSN9', 'I follow a schedule'
+               UNION ALL SELECT 'CSN10', 'I am exacting in my work'
+               UNION ALL SELECT 'OPN1', 'I have a rich vocabulary'
+               UNION ALL SELECT 'OPN2', 'I have difficulty understanding abstract ideas'
+               UNION ALL SELECT 'OPN3', 'I have a vivid imagination'
+               UNION ALL SELECT 'OPN4', 'I am not interested in abstract ideas'
+               UNION ALL SELECT 'OPN5', 'I have excellent ideas'
+               UNION ALL SELECT 'OPN6', 'I do not have a good imagination'
+               UNION ALL SELECT 'OPN7', 'I am quick to understand things'
+               UNION ALL SELECT 'OPN8', 'I use difficult words'
+               UNION ALL SELECT 'OPN9', 'I spend time reflecting on things'
+               UNION ALL SELECT 'OPN10', 'I am full of ideas') en ON en.type = pq.type
+WHERE pq.questionnaire_version_id = 1;
+
+ALTER TABLE personality_questionnaires
+    DROP COLUMN question;
+
+-- auto-generated definition
+DROP TABLE IF EXISTS user_personality_answers;
+CREATE TABLE user_personality_answers
+(
+    id int unsigned auto_increment primary key,
+    user_personality_id int not null,
+    questionnaire_id int not null,
+    answer tinyint not null,
+    created_at timestamp default CURRENT_TIMESTAMP null
+);
+CREATE UNIQUE INDEX idx_user_personality_answers_1 ON user_personality_answers (user_personality_id, questionnaire_id);
+
+ALTER TABLE user_personalities
+    ADD questionnaire_version_id int not null default 1 AFTER tag_ids;
+
+INSERT INTO user_personality_answers (user_personality_id, questionnaire_id, answer, created_at)
+SELECT up.id,
+       pq.id,
+       CASE pq.type
+           WHEN 'EXT1' THEN up.EXT1
+           WHEN 'EXT2' THEN up.EXT2
+           WHEN 'EXT3' THEN up.EXT3
+           WHEN 'EXT4' THEN up.EXT4
+           WHEN 'EXT5' THEN up.EXT5
+           WHEN 'EXT6' THEN up.EXT6
+           WHEN 'EXT7' THEN up.EXT7
+           WHEN 'EXT8' THEN up.EXT8
+           WHEN 'EXT9' THEN up.EXT9
+           WHEN 'EXT10' THEN up.EXT10
+           WHEN 'EST1' THEN up.EST1
+           WHEN 'EST2' THEN up.EST2
+           WHEN 'EST3' THEN up.EST3
+           WHEN 'EST4' THEN up.EST4
+           WHEN 'EST5' THEN up.EST5
+           WHEN 'EST6' THEN up.EST6
+           WHEN 'EST7' THEN up.EST7
+           WHEN 'EST8' THEN up.EST8
+           WHEN 'EST9' THEN up.EST9
+           WHEN 'EST10' THEN up.EST10
+           WHEN 'AGR1' THEN up.AGR1
+           WHEN 'AGR2' THEN up.AGR2
+           WHEN 'AGR3' THEN up.AGR3
+           WHEN 'AGR4' THEN up.AGR4
+           WHEN 'AGR5' THEN up.AGR5
+           WHEN 'AGR6' THEN up.AGR6
+           WHEN 'AGR7' THEN up.AGR7
+           WHEN 'AGR8' THEN up.AGR8
+           WHEN 'AGR9' THEN up.AGR9
+           WHEN 'AGR10' THEN up.AGR10
+           WHEN 'CSN1' THEN up.CSN1
+           WHEN 'CSN2' THEN up.CSN2
+           WHEN 'CSN3' THEN up.CSN3
+           WHEN 'CSN4' THEN up.CSN4
+           WHEN 'CSN5' THEN up.CSN5
+           WHEN 'CSN6' THEN up.CSN6
+           WHEN 'CSN7' THEN up.CSN7
+           WHEN 'CSN8' THEN up.CSN8
+           WHEN 'CSN9' THEN up.CSN9
+           WHEN 'CSN10' THEN up.CSN10
+           WHEN 'OPN1' THEN up.OPN1
+           WHEN 'OPN2' THEN up.OPN2
+           WHEN 'OPN3' THEN up.OPN3
+           WHEN 'OPN4' THEN up.OPN4
+           WHEN 'OPN5' THEN up.OPN5
+           WHEN 'OPN6' THEN up.OPN6
+           WHEN 'OPN7' THEN up.OPN7
+           WHEN 'OPN8' THEN up.OPN8
+           WHEN 'OPN9' THEN up.OPN9
+           WHEN 'OPN10' THEN up.OPN10
+           END,
+       up.created_at
+FROM user_personalities up
+         JOIN personality_questionnaires pq ON pq.questionnaire_version_id = 1;
+
+ALTER TABLE user_personalities
+    DROP COLUMN EXT1,
+    DROP COLUMN EXT2,
+    DROP COLUMN EXT3,
+    DROP COLUMN EXT4,
+    DROP COLUMN EXT5,
+    DROP COLUMN EXT6,
+    DROP COLUMN EXT7,
+    DROP COLUMN EXT8,
+    DROP COLUMN EXT9,
+    DROP COLUMN EXT10,
+    DROP COLUMN EST1,
+    DROP COLUMN EST2,
+    DROP COLUMN EST3,
+    DROP COLUMN EST4,
+    DROP COLUMN EST5,
+    DROP COLUMN EST6,
+    DROP COLUMN EST7,
+    DROP COLUMN EST8,
+    DROP COLUMN EST9,
+    DROP COLUMN EST10,
+    DROP COLUMN AGR1,
+    DROP COLUMN AGR2,
+    DROP COLUMN AGR3,
+    DROP COLUMN AGR4,
+    DROP COLUMN AGR5,
+    DROP COLUMN AGR6,
+    DROP COLUMN AGR7,
+    DROP COLUMN AGR8,
+    DROP COLUMN AGR9,
+    DROP COLUMN AGR10,
+    DROP COLUMN CSN1,
+    DROP COLUMN CSN2,
+    DROP COLUMN CSN3,
+    DROP COLUMN CSN4,
+    DROP COLUMN CSN5,
+    DROP COLUMN CSN6,
+    DROP COLUMN CSN7,
+    DROP COLUMN CSN8,
+    DROP COLUMN CSN9,
+    DROP COLUMN CSN10,
+    DROP COLUMN OPN1,
+    DROP COLUMN OPN2,
+    DROP COLUMN OPN3,
+    DROP COLUMN OPN4,
+    DROP COLUMN OPN5,
+    DROP COLUMN OPN6,
+    DROP COLUMN OPN7,
+    DROP COLUMN OPN8,
+    DROP COLUMN OPN9,
+    DROP COLUMN OPN10;
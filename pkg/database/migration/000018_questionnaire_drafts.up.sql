@@ -0,0 +1,12 @@
+-- auto-generated definition
+DROP TABLE IF EXISTS questionnaire_draft_answers;
+CREATE TABLE questionnaire_draft_answers
+(
+    id int unsigned auto_increment primary key,
+    user_id int not null,
+    questionnaire_id int not null,
+    answer tinyint not null,
+    created_at timestamp default CURRENT_TIMESTAMP null,
+    updated_at timestamp default CURRENT_TIMESTAMP null on update CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX idx_questionnaire_draft_answers_1 ON questionnaire_draft_answers (user_id, questionnaire_id);
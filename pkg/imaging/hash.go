@@ -0,0 +1,89 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"math/bits"
+	"net/http"
+)
+
+const (
+	hashWidth   = 9
+	hashHeight  = 8
+	hashMaxEdge = 64
+)
+
+// DifferenceHash returns the 64-bit perceptual dHash of an image: the picture
+// is shrunk to 9x8 grayscale cells and every bit tells whether a cell is
+// brighter than its right neighbour, so resized or re-encoded copies of the
+// same photo end up with (nearly) the same hash.
+func DifferenceHash(r io.Reader) (hash uint64, err error) {
+	maxSize := MaxUploadSize()
+	raw, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read image: %v", err)
+	}
+	if int64(len(raw)) > maxSize {
+		return 0, fmt.Errorf("%w: image exceeds %d MB", ErrInvalidImage, maxSize/1024/1024)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil || cfg.Width == 0 || cfg.Height == 0 || cfg.Width*cfg.Height > defaultMaxPixels {
+		return 0, fmt.Errorf("%w: malformed image", ErrInvalidImage)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return 0, fmt.Errorf("%w: malformed image", ErrInvalidImage)
+	}
+	if http.DetectContentType(raw) == "image/jpeg" {
+		src = applyOrientation(src, jpegOrientation(raw))
+	}
+
+	small := resizeToFit(src, hashMaxEdge)
+	bounds := small.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	var (
+		sum   [hashHeight][hashWidth]float64
+		count [hashHeight][hashWidth]float64
+	)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := small.RGBAAt(bounds.Min.X+x, bounds.Min.Y+y)
+			cellX, cellY := x*hashWidth/w, y*hashHeight/h
+			sum[cellY][cellX] += 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+			count[cellY][cellX]++
+		}
+	}
+
+	var cells [hashHeight][hashWidth]float64
+	for y := 0; y < hashHeight; y++ {
+		for x := 0; x < hashWidth; x++ {
+			if count[y][x] > 0 {
+				cells[y][x] = sum[y][x] / count[y][x]
+			} else if x > 0 {
+				// images narrower than the grid repeat the previous cell
+				cells[y][x] = cells[y][x-1]
+			}
+		}
+	}
+
+	for y := 0; y < hashHeight; y++ {
+		for x := 0; x < hashWidth-1; x++ {
+			hash <<= 1
+			if cells[y][x] > cells[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash, nil
+}
+
+// HashSimilarity compares two perceptual hashes, 1 meaning identical.
+func HashSimilarity(a, b uint64) float64 {
+	return 1 - float64(bits.OnesCount64(a^b))/64
+}
@@ -0,0 +1,236 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	// register decoders for the accepted upload formats
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/yusufwib/arvigo-backend/utils"
+)
+
+const (
+	RenditionThumbnail = "thumbnail"
+	RenditionList      = "list"
+	RenditionDetail    = "detail"
+
+	WebPMimeType = "image/webp"
+
+	defaultMaxSizeMB = 5
+	defaultMaxPixels = 40000000 // 40MP, guards against decompression bombs
+
+	jpegExtension = ".jpg"
+	jpegMimeType  = "image/jpeg"
+	jpegQuality   = 85
+	webpExtension = ".webp"
+	webpQuality   = 80
+)
+
+// ErrInvalidImage is wrapped by every validation error so callers can answer
+// with a client error instead of a server error.
+var ErrInvalidImage = errors.New("invalid image")
+
+var allowedMimeTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// Rendition is one resized, metadata-free encoding of an uploaded image.
+type Rendition struct {
+	Name        string
+	Width       int
+	Height      int
+	ContentType string
+	Extension   string
+	Data        []byte
+}
+
+// renditionSizes holds the longest edge in pixels for every rendition, from
+// the smallest to the largest.
+var renditionSizes = []struct {
+	name    string
+	maxEdge int
+}{
+	{RenditionThumbnail, 200},
+	{RenditionList, 480},
+	{RenditionDetail, 1080},
+}
+
+// MaxUploadSize returns the maximum accepted upload size in bytes.
+func MaxUploadSize() int64 {
+	return int64(utils.StrToInt(os.Getenv("IMAGE_MAX_SIZE_MB"), defaultMaxSizeMB)) * 1024 * 1024
+}
+
+// Process validates the uploaded bytes and returns the thumbnail, list and
+// detail renditions, each encoded as JPEG and as WebP. Re-encoding drops EXIF/GPS metadata; the EXIF orientation
+// is applied to the pixels first so the picture keeps its intended rotation.
+func Process(r io.Reader) (renditions []Rendition, err error) {
+	maxSize := MaxUploadSize()
+	raw, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %v", err)
+	}
+
+	if int64(len(raw)) > maxSize {
+		return nil, fmt.Errorf("%w: image exceeds %d MB", ErrInvalidImage, maxSize/1024/1024)
+	}
+
+	mimeType := http.DetectContentType(raw)
+	if !allowedMimeTypes[mimeType] {
+		return nil, fmt.Errorf("%w: unsupported image type %s", ErrInvalidImage, mimeType)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed image", ErrInvalidImage)
+	}
+	if cfg.Width == 0 || cfg.Height == 0 || cfg.Width*cfg.Height > defaultMaxPixels {
+		return nil, fmt.Errorf("%w: image dimensions %dx%d are not allowed", ErrInvalidImage, cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed image", ErrInvalidImage)
+	}
+
+	if mimeType == "image/jpeg" {
+		src = applyOrientation(src, jpegOrientation(raw))
+	}
+
+	// downscale the source once; every smaller size is resized from the next
+	// larger one instead of from the full resolution image
+	resized := make([]*image.RGBA, len(renditionSizes))
+	base := src
+	for i := len(renditionSizes) - 1; i >= 0; i-- {
+		resized[i] = resizeToFit(base, renditionSizes[i].maxEdge)
+		base = resized[i]
+	}
+
+	for i, size := range renditionSizes {
+		var jpegBuf, webpBuf bytes.Buffer
+		if err = jpeg.Encode(&jpegBuf, resized[i], &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, fmt.Errorf("failed to encode %s rendition: %v", size.name, err)
+		}
+		if err = encodeWebP(&webpBuf, resized[i], webpQuality); err != nil {
+			return nil, fmt.Errorf("failed to encode %s WebP rendition: %v", size.name, err)
+		}
+
+		width, height := resized[i].Bounds().Dx(), resized[i].Bounds().Dy()
+		renditions = append(renditions, Rendition{
+			Name:        size.name,
+			Width:       width,
+			Height:      height,
+			ContentType: jpegMimeType,
+			Extension:   jpegExtension,
+			Data:        jpegBuf.Bytes(),
+		}, Rendition{
+			Name:        size.name,
+			Width:       width,
+			Height:      height,
+			ContentType: WebPMimeType,
+			Extension:   webpExtension,
+			Data:        webpBuf.Bytes(),
+		})
+	}
+
+	return
+}
+
+// RenditionURL derives the URL of another rendition from a stored detail URL.
+// Images uploaded before renditions existed have no suffix and are returned as is.
+func RenditionURL(detailURL, rendition string) string {
+	suffix := "_" + RenditionDetail + jpegExtension
+	if !strings.HasSuffix(detailURL, suffix) {
+		return detailURL
+	}
+
+	return strings.TrimSuffix(detailURL, suffix) + "_" + rendition + jpegExtension
+}
+
+// RenditionWebPURL derives the URL of the WebP encoding of a rendition from a
+// stored detail URL. Images uploaded before renditions existed have no WebP
+// encoding and get an empty URL.
+func RenditionWebPURL(detailURL, rendition string) string {
+	suffix := "_" + RenditionDetail + jpegExtension
+	if !strings.HasSuffix(detailURL, suffix) {
+		return ""
+	}
+
+	return strings.TrimSuffix(detailURL, suffix) + "_" + rendition + webpExtension
+}
+
+// RenditionObjectName builds the object name for a rendition of the same upload.
+func RenditionObjectName(baseName string, rendition Rendition) string {
+	return baseName + "_" + rendition.Name + rendition.Extension
+}
+
+// resizeToFit scales the image down so its longest edge is at most maxEdge,
+// flattening transparency onto white since renditions are opaque. The source
+// is flattened one row at a time, so no full resolution copy is allocated.
+func resizeToFit(src image.Image, maxEdge int) *image.RGBA {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	dstW, dstH := srcW, srcH
+	if srcW >= srcH && srcW > maxEdge {
+		dstW = maxEdge
+		dstH = srcH * maxEdge / srcW
+	} else if srcH > srcW && srcH > maxEdge {
+		dstH = maxEdge
+		dstW = srcW * maxEdge / srcH
+	}
+	if dstW < 1 {
+		dstW = 1
+	}
+	if dstH < 1 {
+		dstH = 1
+	}
+
+	// box filter: every destination pixel averages the source pixels it
+	// covers; the image is only ever shrunk, so every source row and column
+	// belongs to exactly one destination row and column
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	row := image.NewRGBA(image.Rect(0, 0, srcW, 1))
+	sums := make([]uint32, 3*dstW)
+	for y := 0; y < dstH; y++ {
+		y0 := y * srcH / dstH
+		y1 := (y + 1) * srcH / dstH
+
+		for i := range sums {
+			sums[i] = 0
+		}
+		for sy := y0; sy < y1; sy++ {
+			draw.Draw(row, row.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+			draw.Draw(row, row.Bounds(), src, image.Pt(bounds.Min.X, bounds.Min.Y+sy), draw.Over)
+
+			for x := 0; x < dstW; x++ {
+				x0 := x * srcW / dstW
+				x1 := (x + 1) * srcW / dstW
+				for offset := 4 * x0; offset < 4*x1; offset += 4 {
+					sums[3*x] += uint32(row.Pix[offset])
+					sums[3*x+1] += uint32(row.Pix[offset+1])
+					sums[3*x+2] += uint32(row.Pix[offset+2])
+				}
+			}
+		}
+
+		for x := 0; x < dstW; x++ {
+			count := uint32((y1 - y0) * ((x+1)*srcW/dstW - x*srcW/dstW))
+			dst.SetRGBA(x, y, color.RGBA{R: uint8(sums[3*x] / count), G: uint8(sums[3*x+1] / count), B: uint8(sums[3*x+2] / count), A: 255})
+		}
+	}
+
+	return dst
+}
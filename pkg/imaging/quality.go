@@ -0,0 +1,84 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"net/http"
+)
+
+// qualityMaxEdge keeps the analysis cheap and makes sharpness comparable
+// between photos of different resolutions.
+const qualityMaxEdge = 512
+
+// Quality describes the properties an uploaded photo is checked against
+// before it is sent for analysis.
+type Quality struct {
+	MimeType string
+	Size     int
+	Width    int
+	Height   int
+	// Brightness is the mean luma from 0 (black) to 255 (white).
+	Brightness float64
+	// Sharpness is the variance of the Laplacian; blurry photos score low.
+	Sharpness float64
+}
+
+// Analyze decodes the image and measures its size, brightness and sharpness.
+// Format, byte size and dimension limits are left to the caller.
+func Analyze(raw []byte) (q Quality, err error) {
+	q.Size = len(raw)
+	q.MimeType = http.DetectContentType(raw)
+	if !allowedMimeTypes[q.MimeType] {
+		return q, fmt.Errorf("%w: unsupported image type %s", ErrInvalidImage, q.MimeType)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil || cfg.Width == 0 || cfg.Height == 0 || cfg.Width*cfg.Height > defaultMaxPixels {
+		return q, fmt.Errorf("%w: malformed image", ErrInvalidImage)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return q, fmt.Errorf("%w: malformed image", ErrInvalidImage)
+	}
+	if q.MimeType == "image/jpeg" {
+		src = applyOrientation(src, jpegOrientation(raw))
+	}
+	q.Width, q.Height = src.Bounds().Dx(), src.Bounds().Dy()
+
+	small := resizeToFit(src, qualityMaxEdge)
+	bounds := small.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	luma := make([]float64, w*h)
+	var total float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := small.RGBAAt(bounds.Min.X+x, bounds.Min.Y+y)
+			luma[y*w+x] = 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+			total += luma[y*w+x]
+		}
+	}
+	q.Brightness = total / float64(w*h)
+
+	// 4-neighbour Laplacian over the inner pixels
+	if w < 3 || h < 3 {
+		return q, nil
+	}
+
+	var sum, sumSq, count float64
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			i := y*w + x
+			lap := luma[i-w] + luma[i+w] + luma[i-1] + luma[i+1] - 4*luma[i]
+			sum += lap
+			sumSq += lap * lap
+			count++
+		}
+	}
+	mean := sum / count
+	q.Sharpness = sumSq/count - mean*mean
+
+	return q, nil
+}
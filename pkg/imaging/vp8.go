@@ -0,0 +1,913 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// A minimal lossy VP8 keyframe encoder (RFC 6386). Every macroblock uses one
+// of the four whole-block luma and chroma predictors, coefficients are coded
+// in a single partition, and the token probabilities are refitted to the
+// image before they are written.
+
+// Luma and chroma prediction modes (section 12.2).
+const (
+	vp8PredDC = iota
+	vp8PredVE
+	vp8PredHE
+	vp8PredTM
+	vp8NumPredModes
+)
+
+// Border samples the decoder assumes outside the frame (section 12.2).
+const (
+	vp8BorderTop  = 0x7f
+	vp8BorderLeft = 0x81
+)
+
+// boolEncoder is the boolean entropy encoder of section 7.3.
+type boolEncoder struct {
+	buf      []byte
+	rng      uint32
+	bottom   uint32
+	bitCount int
+}
+
+func newBoolEncoder() *boolEncoder {
+	return &boolEncoder{rng: 255, bitCount: 24}
+}
+
+// putBit writes bit, which is false with probability prob/256.
+func (e *boolEncoder) putBit(bit bool, prob uint8) {
+	split := 1 + ((e.rng - 1) * uint32(prob) >> 8)
+	if bit {
+		e.bottom += split
+		e.rng -= split
+	} else {
+		e.rng = split
+	}
+
+	for e.rng < 128 {
+		e.rng <<= 1
+		if e.bottom&(1<<31) != 0 {
+			e.carry()
+		}
+		e.bottom <<= 1
+		e.bitCount--
+		if e.bitCount == 0 {
+			e.buf = append(e.buf, byte(e.bottom>>24))
+			e.bottom &= 1<<24 - 1
+			e.bitCount = 8
+		}
+	}
+}
+
+// carry propagates an overflow of bottom into the bytes already written.
+func (e *boolEncoder) carry() {
+	i := len(e.buf) - 1
+	for ; i >= 0 && e.buf[i] == 255; i-- {
+		e.buf[i] = 0
+	}
+	if i >= 0 {
+		e.buf[i]++
+	}
+}
+
+// putLiteral writes the n low bits of v, most significant first.
+func (e *boolEncoder) putLiteral(v uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		e.putBit(v>>uint(i)&1 == 1, 128)
+	}
+}
+
+// putFlag writes a single bit with even probability.
+func (e *boolEncoder) putFlag(bit bool) {
+	e.putBit(bit, 128)
+}
+
+// bytes flushes the encoder and returns the coded partition.
+func (e *boolEncoder) bytes() []byte {
+	c := e.bitCount
+	v := e.bottom
+	if v&(1<<uint(32-c)) != 0 {
+		e.carry()
+	}
+	v <<= uint(c & 7)
+	for c >>= 3; c > 0; c-- {
+		v <<= 8
+	}
+	for i := 0; i < 4; i++ {
+		e.buf = append(e.buf, byte(v>>24))
+		v <<= 8
+	}
+
+	return e.buf
+}
+
+// tokenWriter receives the bits of the residual tokens. The frame writer
+// codes them, the counter collects statistics to refit the probabilities.
+type tokenWriter interface {
+	// putToken writes a bit coded with probability i of the given context.
+	putToken(bit bool, plane, band, ctx, i int)
+	// putBit writes a bit coded with a fixed probability.
+	putBit(bit bool, prob uint8)
+}
+
+type tokenEncoder struct {
+	*boolEncoder
+	probs *[vp8NumPlanes][vp8NumBands][vp8NumContexts][vp8NumProbs]uint8
+}
+
+func (e tokenEncoder) putToken(bit bool, plane, band, ctx, i int) {
+	e.putBit(bit, e.probs[plane][band][ctx][i])
+}
+
+type tokenCounter struct {
+	// counts holds how often each token probability coded a zero and a one
+	counts [vp8NumPlanes][vp8NumBands][vp8NumContexts][vp8NumProbs][2]uint32
+}
+
+func (c *tokenCounter) putToken(bit bool, plane, band, ctx, i int) {
+	if bit {
+		c.counts[plane][band][ctx][i][1]++
+	} else {
+		c.counts[plane][band][ctx][i][0]++
+	}
+}
+
+func (c *tokenCounter) putBit(bool, uint8) {}
+
+// vp8Macroblock holds the decisions and quantized levels of one macroblock.
+// Levels are stored in zigzag order.
+type vp8Macroblock struct {
+	yMode, uvMode int
+	skip          bool
+	y2            [16]int16
+	y             [16][16]int16
+	uv            [8][16]int16 // four U blocks, then four V blocks
+}
+
+type vp8Encoder struct {
+	width, height int
+	mbw, mbh      int
+
+	// source and reconstructed planes, padded to whole macroblocks. The
+	// reconstruction is what the decoder sees before loop filtering, which
+	// is what intra prediction works from.
+	yStride, uvStride int
+	srcY, srcU, srcV  []uint8
+	recY, recU, recV  []uint8
+
+	qIndex int
+	y1, y2 [2]int32 // dequantization factors: DC, AC
+	uvQ    [2]int32
+
+	mbs []vp8Macroblock
+}
+
+// encodeVP8 returns the VP8 keyframe of m at the given quality (0-100).
+func encodeVP8(m *image.RGBA, quality int) []byte {
+	e := newVP8Encoder(m, quality)
+	for mby := 0; mby < e.mbh; mby++ {
+		for mbx := 0; mbx < e.mbw; mbx++ {
+			e.encodeMacroblock(mbx, mby)
+		}
+	}
+
+	var counter tokenCounter
+	e.writeTokens(&counter)
+	probs := vp8DefaultTokenProb
+	updates := refitTokenProbs(&probs, &counter)
+
+	tokens := newBoolEncoder()
+	e.writeTokens(tokenEncoder{boolEncoder: tokens, probs: &probs})
+	first := e.writeHeader(&probs, updates)
+	second := tokens.bytes()
+
+	frame := make([]byte, 0, 10+len(first)+len(second))
+	tag := uint32(len(first))<<5 | 1<<4 // keyframe, version 0, shown
+	frame = append(frame, byte(tag), byte(tag>>8), byte(tag>>16))
+	frame = append(frame, 0x9d, 0x01, 0x2a)
+	frame = append(frame, byte(e.width), byte(e.width>>8), byte(e.height), byte(e.height>>8))
+	frame = append(frame, first...)
+	frame = append(frame, second...)
+
+	return frame
+}
+
+func newVP8Encoder(m *image.RGBA, quality int) *vp8Encoder {
+	bounds := m.Bounds()
+	e := &vp8Encoder{
+		width:  bounds.Dx(),
+		height: bounds.Dy(),
+		qIndex: vp8QuantIndex(quality),
+	}
+	e.mbw = (e.width + 15) / 16
+	e.mbh = (e.height + 15) / 16
+	e.yStride = 16 * e.mbw
+	e.uvStride = 8 * e.mbw
+	e.srcY = make([]uint8, e.yStride*16*e.mbh)
+	e.srcU = make([]uint8, e.uvStride*8*e.mbh)
+	e.srcV = make([]uint8, e.uvStride*8*e.mbh)
+	e.recY = make([]uint8, len(e.srcY))
+	e.recU = make([]uint8, len(e.srcU))
+	e.recV = make([]uint8, len(e.srcV))
+	e.mbs = make([]vp8Macroblock, e.mbw*e.mbh)
+
+	q := e.qIndex
+	e.y1 = [2]int32{int32(vp8DCTable[q]), int32(vp8ACTable[q])}
+	e.y2 = [2]int32{int32(vp8DCTable[q]) * 2, int32(vp8ACTable[q]) * 155 / 100}
+	if e.y2[1] < 8 {
+		e.y2[1] = 8
+	}
+	uvDC := q
+	if uvDC > 117 {
+		uvDC = 117
+	}
+	e.uvQ = [2]int32{int32(vp8DCTable[uvDC]), int32(vp8ACTable[q])}
+
+	e.convert(m)
+
+	return e
+}
+
+// vp8QuantIndex maps a 0-100 quality onto the quantizer index, following the
+// curve libwebp uses so the qualities are comparable.
+func vp8QuantIndex(quality int) int {
+	if quality < 0 {
+		quality = 0
+	}
+	if quality > 100 {
+		quality = 100
+	}
+
+	c := float64(quality) / 100
+	if c < 0.75 {
+		c *= 2.0 / 3.0
+	} else {
+		c = 2*c - 1
+	}
+
+	return int(math.Round(127 * (1 - math.Cbrt(c))))
+}
+
+// convert fills the source planes with the BT.601 studio swing YUV 4:2:0
+// samples of m, repeating the last row and column into the padding.
+func (e *vp8Encoder) convert(m *image.RGBA) {
+	bounds := m.Bounds()
+	pixel := func(x, y int) (r, g, b int32) {
+		if x >= e.width {
+			x = e.width - 1
+		}
+		if y >= e.height {
+			y = e.height - 1
+		}
+		i := m.PixOffset(bounds.Min.X+x, bounds.Min.Y+y)
+		return int32(m.Pix[i]), int32(m.Pix[i+1]), int32(m.Pix[i+2])
+	}
+
+	for y := 0; y < 16*e.mbh; y++ {
+		for x := 0; x < e.yStride; x++ {
+			r, g, b := pixel(x, y)
+			e.srcY[y*e.yStride+x] = uint8((16839*r + 33059*g + 6420*b + 1<<15 + 16<<16) >> 16)
+		}
+	}
+
+	for y := 0; y < 8*e.mbh; y++ {
+		for x := 0; x < e.uvStride; x++ {
+			var r, g, b int32
+			for i := 0; i < 4; i++ {
+				pr, pg, pb := pixel(2*x+i&1, 2*y+i>>1)
+				r, g, b = r+pr, g+pg, b+pb
+			}
+			e.srcU[y*e.uvStride+x] = clipChroma(-9719*r - 19081*g + 28800*b)
+			e.srcV[y*e.uvStride+x] = clipChroma(28800*r - 24116*g - 4684*b)
+		}
+	}
+}
+
+// clipChroma scales a chroma sum of four pixels back to a sample.
+func clipChroma(v int32) uint8 {
+	v = (v + 1<<17 + 128<<18) >> 18
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
+
+func clip8(v int32) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
+
+// predictor holds the samples around a block as the decoder builds them.
+type predictor struct {
+	top, left       [16]int32
+	corner          int32
+	hasTop, hasLeft bool
+}
+
+// newPredictor reads the border of the size x size block at (x, y) of the
+// reconstructed plane rec.
+func newPredictor(rec []uint8, stride, x, y, size int) predictor {
+	p := predictor{hasTop: y > 0, hasLeft: x > 0}
+	for i := 0; i < size; i++ {
+		p.top[i] = vp8BorderTop
+		p.left[i] = vp8BorderLeft
+		if p.hasTop {
+			p.top[i] = int32(rec[(y-1)*stride+x+i])
+		}
+		if p.hasLeft {
+			p.left[i] = int32(rec[(y+i)*stride+x-1])
+		}
+	}
+
+	switch {
+	case !p.hasTop:
+		p.corner = vp8BorderTop
+	case !p.hasLeft:
+		p.corner = vp8BorderLeft
+	default:
+		p.corner = int32(rec[(y-1)*stride+x-1])
+	}
+
+	return p
+}
+
+// predict fills dst (size x size, row major) with the prediction of mode.
+func (p *predictor) predict(dst []int32, mode, size int) {
+	switch mode {
+	case vp8PredDC:
+		shift := 3
+		if size == 16 {
+			shift = 4
+		}
+		var sum int32
+		dc := int32(0x80)
+		switch {
+		case p.hasTop && p.hasLeft:
+			for i := 0; i < size; i++ {
+				sum += p.top[i] + p.left[i]
+			}
+			dc = (sum + int32(size)) >> uint(shift+1)
+		case p.hasTop:
+			for i := 0; i < size; i++ {
+				sum += p.top[i]
+			}
+			dc = (sum + int32(size/2)) >> uint(shift)
+		case p.hasLeft:
+			for i := 0; i < size; i++ {
+				sum += p.left[i]
+			}
+			dc = (sum + int32(size/2)) >> uint(shift)
+		}
+		for i := range dst[:size*size] {
+			dst[i] = dc
+		}
+	case vp8PredVE:
+		for y := 0; y < size; y++ {
+			copy(dst[y*size:(y+1)*size], p.top[:size])
+		}
+	case vp8PredHE:
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				dst[y*size+x] = p.left[y]
+			}
+		}
+	case vp8PredTM:
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				dst[y*size+x] = int32(clip8(p.left[y] + p.top[x] - p.corner))
+			}
+		}
+	}
+}
+
+// bestMode returns the prediction mode closest to the source block, and its
+// prediction.
+func bestMode(preds []predictor, srcs [][]uint8, stride, x, y, size int, out [][]int32) int {
+	best, bestErr := 0, int64(-1)
+	scratch := make([][]int32, len(preds))
+	for i := range scratch {
+		scratch[i] = make([]int32, size*size)
+	}
+
+	for mode := 0; mode < vp8NumPredModes; mode++ {
+		var sse int64
+		for i := range preds {
+			preds[i].predict(scratch[i], mode, size)
+			for j := 0; j < size; j++ {
+				row := srcs[i][(y+j)*stride+x:]
+				for k := 0; k < size; k++ {
+					d := int64(row[k]) - int64(scratch[i][j*size+k])
+					sse += d * d
+				}
+			}
+		}
+		if bestErr < 0 || sse < bestErr {
+			best, bestErr = mode, sse
+			for i := range out {
+				copy(out[i], scratch[i])
+			}
+		}
+	}
+
+	return best
+}
+
+func (e *vp8Encoder) encodeMacroblock(mbx, mby int) {
+	mb := &e.mbs[mby*e.mbw+mbx]
+	nonzero := false
+
+	// luma: one 16x16 prediction, the block DCs go through the Y2 transform
+	x, y := 16*mbx, 16*mby
+	predY := make([]int32, 256)
+	mb.yMode = bestMode([]predictor{newPredictor(e.recY, e.yStride, x, y, 16)},
+		[][]uint8{e.srcY}, e.yStride, x, y, 16, [][]int32{predY})
+
+	var coeffs [16][16]int32
+	var dcs [16]int32
+	for n := 0; n < 16; n++ {
+		bx, by := x+4*(n&3), y+4*(n>>2)
+		var residual [16]int32
+		for j := 0; j < 4; j++ {
+			for i := 0; i < 4; i++ {
+				p := predY[(4*(n>>2)+j)*16+4*(n&3)+i]
+				residual[j*4+i] = int32(e.srcY[(by+j)*e.yStride+bx+i]) - p
+			}
+		}
+		forwardDCT(&residual, &coeffs[n])
+		dcs[n] = coeffs[n][0]
+	}
+
+	var y2 [16]int32
+	forwardWHT(&dcs, &y2)
+	if quantizeBlock(&y2, &mb.y2, e.y2, 0) {
+		nonzero = true
+	}
+	var dcRec [16]int32
+	inverseWHT(&y2, &dcRec)
+
+	for n := 0; n < 16; n++ {
+		if quantizeBlock(&coeffs[n], &mb.y[n], e.y1, 1) {
+			nonzero = true
+		}
+		coeffs[n][0] = dcRec[n]
+		bx, by := 4*(n&3), 4*(n>>2)
+		inverseDCT(&coeffs[n], predY[by*16+bx:], 16, e.recY[(y+by)*e.yStride+x+bx:], e.yStride)
+	}
+
+	// chroma: both planes share one 8x8 prediction mode
+	x, y = 8*mbx, 8*mby
+	predU, predV := make([]int32, 64), make([]int32, 64)
+	mb.uvMode = bestMode([]predictor{
+		newPredictor(e.recU, e.uvStride, x, y, 8),
+		newPredictor(e.recV, e.uvStride, x, y, 8),
+	}, [][]uint8{e.srcU, e.srcV}, e.uvStride, x, y, 8, [][]int32{predU, predV})
+
+	planes := [2]struct {
+		src, rec []uint8
+		pred     []int32
+	}{{e.srcU, e.recU, predU}, {e.srcV, e.recV, predV}}
+	for n := 0; n < 8; n++ {
+		plane := planes[n>>2]
+		bx, by := 4*(n&1), 4*(n>>1&1)
+		var residual, c [16]int32
+		for j := 0; j < 4; j++ {
+			for i := 0; i < 4; i++ {
+				p := plane.pred[(by+j)*8+bx+i]
+				residual[j*4+i] = int32(plane.src[(y+by+j)*e.uvStride+x+bx+i]) - p
+			}
+		}
+		forwardDCT(&residual, &c)
+		if quantizeBlock(&c, &mb.uv[n], e.uvQ, 0) {
+			nonzero = true
+		}
+		inverseDCT(&c, plane.pred[by*8+bx:], 8, plane.rec[(y+by)*e.uvStride+x+bx:], e.uvStride)
+	}
+
+	mb.skip = !nonzero
+}
+
+// quantizeBlock quantizes the raster ordered coefficients from index first
+// into levels (zigzag order) and replaces the coefficients with their
+// dequantized values. It reports whether any level is nonzero.
+func quantizeBlock(coeffs *[16]int32, levels *[16]int16, q [2]int32, first int) bool {
+	nonzero := false
+	for n := first; n < 16; n++ {
+		pos := vp8Zigzag[n]
+		factor := q[1]
+		if pos == 0 {
+			factor = q[0]
+		}
+
+		v := coeffs[pos]
+		sign := int32(1)
+		if v < 0 {
+			sign, v = -1, -v
+		}
+		// round the DC to nearest and AC with a small dead zone, which
+		// saves more bits than it costs in quality
+		bias := factor * 3 / 8
+		if pos == 0 {
+			bias = factor / 2
+		}
+		level := (v + bias) / factor
+		if max := int32(32767) / factor; level > max {
+			level = max
+		}
+		if level > 2047 {
+			level = 2047
+		}
+
+		levels[n] = int16(sign * level)
+		coeffs[pos] = sign * level * factor
+		if level != 0 {
+			nonzero = true
+		}
+	}
+
+	return nonzero
+}
+
+// forwardDCT transforms a 4x4 residual block.
+func forwardDCT(in, out *[16]int32) {
+	var tmp [16]int32
+	for i := 0; i < 4; i++ {
+		d0, d1, d2, d3 := in[i*4], in[i*4+1], in[i*4+2], in[i*4+3]
+		a0, a1, a2, a3 := d0+d3, d1+d2, d1-d2, d0-d3
+		tmp[i*4] = (a0 + a1) * 8
+		tmp[i*4+1] = (a2*2217 + a3*5352 + 1812) >> 9
+		tmp[i*4+2] = (a0 - a1) * 8
+		tmp[i*4+3] = (a3*2217 - a2*5352 + 937) >> 9
+	}
+	for i := 0; i < 4; i++ {
+		a0 := tmp[i] + tmp[12+i]
+		a1 := tmp[4+i] + tmp[8+i]
+		a2 := tmp[4+i] - tmp[8+i]
+		a3 := tmp[i] - tmp[12+i]
+		out[i] = (a0 + a1 + 7) >> 4
+		out[4+i] = (a2*2217 + a3*5352 + 12000) >> 16
+		if a3 != 0 {
+			out[4+i]++
+		}
+		out[8+i] = (a0 - a1 + 7) >> 4
+		out[12+i] = (a3*2217 - a2*5352 + 51000) >> 16
+	}
+}
+
+// inverseDCT adds the inverse transform of coeffs to the prediction and
+// stores the clipped result in dst, exactly as the decoder does (section 14.3).
+func inverseDCT(coeffs *[16]int32, pred []int32, predStride int, dst []uint8, dstStride int) {
+	const (
+		c1 = 85627 // 65536 * cos(pi/8) * sqrt(2)
+		c2 = 35468 // 65536 * sin(pi/8) * sqrt(2)
+	)
+
+	var m [4][4]int32
+	for i := 0; i < 4; i++ {
+		a := coeffs[i] + coeffs[8+i]
+		b := coeffs[i] - coeffs[8+i]
+		c := (coeffs[4+i] * c2 >> 16) - (coeffs[12+i] * c1 >> 16)
+		d := (coeffs[4+i] * c1 >> 16) + (coeffs[12+i] * c2 >> 16)
+		m[i] = [4]int32{a + d, b + c, b - c, a - d}
+	}
+	for j := 0; j < 4; j++ {
+		dc := m[0][j] + 4
+		a := dc + m[2][j]
+		b := dc - m[2][j]
+		c := (m[1][j] * c2 >> 16) - (m[3][j] * c1 >> 16)
+		d := (m[1][j] * c1 >> 16) + (m[3][j] * c2 >> 16)
+		row := [4]int32{(a + d) >> 3, (b + c) >> 3, (b - c) >> 3, (a - d) >> 3}
+		for i, v := range row {
+			dst[j*dstStride+i] = clip8(pred[j*predStride+i] + v)
+		}
+	}
+}
+
+// forwardWHT transforms the 16 luma DCs of a macroblock.
+func forwardWHT(in, out *[16]int32) {
+	var tmp [16]int32
+	for i := 0; i < 4; i++ {
+		a0 := in[i*4] + in[i*4+2]
+		a1 := in[i*4+1] + in[i*4+3]
+		a2 := in[i*4+1] - in[i*4+3]
+		a3 := in[i*4] - in[i*4+2]
+		tmp[i*4] = a0 + a1
+		tmp[i*4+1] = a3 + a2
+		tmp[i*4+2] = a3 - a2
+		tmp[i*4+3] = a0 - a1
+	}
+	for i := 0; i < 4; i++ {
+		a0 := tmp[i] + tmp[8+i]
+		a1 := tmp[4+i] + tmp[12+i]
+		a2 := tmp[4+i] - tmp[12+i]
+		a3 := tmp[i] - tmp[8+i]
+		out[i] = (a0 + a1) >> 1
+		out[4+i] = (a3 + a2) >> 1
+		out[8+i] = (a3 - a2) >> 1
+		out[12+i] = (a0 - a1) >> 1
+	}
+}
+
+// inverseWHT returns the luma DCs from the dequantized Y2 coefficients,
+// exactly as the decoder does (section 14.3).
+func inverseWHT(in, out *[16]int32) {
+	var m [16]int32
+	for i := 0; i < 4; i++ {
+		a0 := in[i] + in[12+i]
+		a1 := in[4+i] + in[8+i]
+		a2 := in[4+i] - in[8+i]
+		a3 := in[i] - in[12+i]
+		m[i] = a0 + a1
+		m[8+i] = a0 - a1
+		m[4+i] = a3 + a2
+		m[12+i] = a3 - a2
+	}
+	for i := 0; i < 4; i++ {
+		dc := m[i*4] + 3
+		a0 := dc + m[i*4+3]
+		a1 := m[i*4+1] + m[i*4+2]
+		a2 := m[i*4+1] - m[i*4+2]
+		a3 := dc - m[i*4+3]
+		out[i*4] = (a0 + a1) >> 3
+		out[i*4+1] = (a3 + a2) >> 3
+		out[i*4+2] = (a0 - a1) >> 3
+		out[i*4+3] = (a3 - a2) >> 3
+	}
+}
+
+// writeTokens codes the residuals of every macroblock (section 13).
+func (e *vp8Encoder) writeTokens(w tokenWriter) {
+	// nonzero flags of the blocks above: 4 luma, 2 U, 2 V and the Y2 block
+	upNz := make([][9]uint8, e.mbw)
+	for mby := 0; mby < e.mbh; mby++ {
+		var leftNz [9]uint8
+		for mbx := 0; mbx < e.mbw; mbx++ {
+			mb := &e.mbs[mby*e.mbw+mbx]
+			up := &upNz[mbx]
+			if mb.skip {
+				*up, leftNz = [9]uint8{}, [9]uint8{}
+				continue
+			}
+
+			nz := putCoeffs(w, &mb.y2, vp8PlaneY2, int(leftNz[8]+up[8]), 0)
+			leftNz[8], up[8] = nz, nz
+
+			for j := 0; j < 4; j++ {
+				for i := 0; i < 4; i++ {
+					nz := putCoeffs(w, &mb.y[4*j+i], vp8PlaneYAfterY2, int(leftNz[j]+up[i]), 1)
+					leftNz[j], up[i] = nz, nz
+				}
+			}
+
+			for c := 4; c < 8; c += 2 {
+				for j := 0; j < 2; j++ {
+					for i := 0; i < 2; i++ {
+						block := 2*(c-4) + 2*j + i
+						nz := putCoeffs(w, &mb.uv[block], vp8PlaneUV, int(leftNz[c+j]+up[c+i]), 0)
+						leftNz[c+j], up[c+i] = nz, nz
+					}
+				}
+			}
+		}
+	}
+}
+
+// putCoeffs codes the levels of one block from index first and returns 1 if
+// any of them was nonzero.
+func putCoeffs(w tokenWriter, levels *[16]int16, plane, ctx, first int) uint8 {
+	last := -1
+	for n := 15; n >= first; n-- {
+		if levels[n] != 0 {
+			last = n
+			break
+		}
+	}
+
+	band := int(vp8Bands[first])
+	if last < 0 {
+		w.putToken(false, plane, band, ctx, 0)
+		return 0
+	}
+	w.putToken(true, plane, band, ctx, 0)
+
+	for n := first; n <= last; {
+		v := int32(levels[n])
+		n++
+		if v == 0 {
+			w.putToken(false, plane, band, ctx, 1)
+			band, ctx = int(vp8Bands[n]), 0
+			continue
+		}
+		w.putToken(true, plane, band, ctx, 1)
+
+		abs := v
+		if abs < 0 {
+			abs = -abs
+		}
+		switch {
+		case abs == 1:
+			w.putToken(false, plane, band, ctx, 2)
+		case abs <= 4:
+			w.putToken(true, plane, band, ctx, 2)
+			w.putToken(false, plane, band, ctx, 3)
+			if abs == 2 {
+				w.putToken(false, plane, band, ctx, 4)
+			} else {
+				w.putToken(true, plane, band, ctx, 4)
+				w.putToken(abs == 4, plane, band, ctx, 5)
+			}
+		case abs <= 10:
+			w.putToken(true, plane, band, ctx, 2)
+			w.putToken(true, plane, band, ctx, 3)
+			w.putToken(false, plane, band, ctx, 6)
+			if abs <= 6 {
+				w.putToken(false, plane, band, ctx, 7)
+				w.putBit(abs == 6, 159)
+			} else {
+				w.putToken(true, plane, band, ctx, 7)
+				w.putBit((abs-7)&2 != 0, 165)
+				w.putBit((abs-7)&1 != 0, 145)
+			}
+		default:
+			w.putToken(true, plane, band, ctx, 2)
+			w.putToken(true, plane, band, ctx, 3)
+			w.putToken(true, plane, band, ctx, 6)
+			cat := 0
+			for cat < 3 && abs >= 3+(8<<uint(cat+1)) {
+				cat++
+			}
+			w.putToken(cat >= 2, plane, band, ctx, 8)
+			w.putToken(cat&1 == 1, plane, band, ctx, 9+cat>>1)
+			extra := abs - (3 + (8 << uint(cat)))
+			probs := vp8CatProbs[cat]
+			for i, prob := range probs {
+				w.putBit(extra>>uint(len(probs)-1-i)&1 == 1, prob)
+			}
+		}
+		w.putBit(v < 0, 128)
+
+		band = int(vp8Bands[n])
+		if abs == 1 {
+			ctx = 1
+		} else {
+			ctx = 2
+		}
+		if n == 16 {
+			return 1
+		}
+		w.putToken(n <= last, plane, band, ctx, 0)
+	}
+
+	return 1
+}
+
+// refitTokenProbs replaces the token probabilities that are cheaper to
+// update than to keep, and reports which ones changed.
+func refitTokenProbs(probs *[vp8NumPlanes][vp8NumBands][vp8NumContexts][vp8NumProbs]uint8, c *tokenCounter) (updated [vp8NumPlanes][vp8NumBands][vp8NumContexts][vp8NumProbs]bool) {
+	for i := range probs {
+		for j := range probs[i] {
+			for k := range probs[i][j] {
+				for l := range probs[i][j][k] {
+					zeros, ones := c.counts[i][j][k][l][0], c.counts[i][j][k][l][1]
+					if zeros+ones == 0 {
+						continue
+					}
+
+					p := uint8(255)
+					if ones > 0 {
+						fit := (255*uint64(zeros) + uint64(zeros+ones)/2) / uint64(zeros+ones)
+						switch {
+						case fit < 1:
+							p = 1
+						case fit > 255:
+							p = 255
+						default:
+							p = uint8(fit)
+						}
+					}
+
+					old := probs[i][j][k][l]
+					update := vp8TokenUpdateProb[i][j][k][l]
+					keep := bitCost(zeros, ones, old) + bitCost(1, 0, update)
+					refit := bitCost(zeros, ones, p) + bitCost(0, 1, update) + 8
+					if p != old && refit < keep {
+						probs[i][j][k][l] = p
+						updated[i][j][k][l] = true
+					}
+				}
+			}
+		}
+	}
+
+	return
+}
+
+// bitCost estimates the bits needed to code the given number of zeros and
+// ones with probability prob.
+func bitCost(zeros, ones uint32, prob uint8) float64 {
+	p := float64(prob) / 256
+	return -float64(zeros)*math.Log2(p) - float64(ones)*math.Log2(1-p)
+}
+
+// writeHeader codes the first partition: the frame header and the prediction
+// modes (sections 9 and 19.2).
+func (e *vp8Encoder) writeHeader(probs *[vp8NumPlanes][vp8NumBands][vp8NumContexts][vp8NumProbs]uint8, updated [vp8NumPlanes][vp8NumBands][vp8NumContexts][vp8NumProbs]bool) []byte {
+	w := newBoolEncoder()
+	w.putFlag(false) // color space
+	w.putFlag(false) // clamping required
+	w.putFlag(false) // segmentation
+
+	w.putFlag(false) // normal loop filter
+	w.putLiteral(uint32(vp8FilterLevel(e.qIndex)), 6)
+	w.putLiteral(0, 3) // sharpness
+	w.putFlag(false)   // loop filter deltas
+
+	w.putLiteral(0, 2) // one token partition
+	w.putLiteral(uint32(e.qIndex), 7)
+	for i := 0; i < 5; i++ {
+		w.putFlag(false) // no quantizer deltas
+	}
+	w.putFlag(false) // refresh entropy probs
+
+	for i := range probs {
+		for j := range probs[i] {
+			for k := range probs[i][j] {
+				for l := range probs[i][j][k] {
+					w.putBit(updated[i][j][k][l], vp8TokenUpdateProb[i][j][k][l])
+					if updated[i][j][k][l] {
+						w.putLiteral(uint32(probs[i][j][k][l]), 8)
+					}
+				}
+			}
+		}
+	}
+
+	skipped := 0
+	for i := range e.mbs {
+		if e.mbs[i].skip {
+			skipped++
+		}
+	}
+	w.putFlag(skipped > 0)
+	var skipProb uint8
+	if skipped > 0 {
+		fit := 255 * (len(e.mbs) - skipped) / len(e.mbs)
+		if fit < 1 {
+			fit = 1
+		}
+		skipProb = uint8(fit)
+		w.putLiteral(uint32(skipProb), 8)
+	}
+
+	for i := range e.mbs {
+		mb := &e.mbs[i]
+		if skipped > 0 {
+			w.putBit(mb.skip, skipProb)
+		}
+
+		w.putBit(true, 145) // 16x16 luma prediction
+		switch mb.yMode {
+		case vp8PredDC:
+			w.putBit(false, 156)
+			w.putBit(false, 163)
+		case vp8PredVE:
+			w.putBit(false, 156)
+			w.putBit(true, 163)
+		case vp8PredHE:
+			w.putBit(true, 156)
+			w.putBit(false, 128)
+		case vp8PredTM:
+			w.putBit(true, 156)
+			w.putBit(true, 128)
+		}
+
+		w.putBit(mb.uvMode != vp8PredDC, 142)
+		if mb.uvMode != vp8PredDC {
+			w.putBit(mb.uvMode != vp8PredVE, 114)
+			if mb.uvMode != vp8PredVE {
+				w.putBit(mb.uvMode == vp8PredTM, 183)
+			}
+		}
+	}
+
+	return w.bytes()
+}
+
+// vp8FilterLevel picks a loop filter strength that grows with the quantizer.
+func vp8FilterLevel(qIndex int) int {
+	level := qIndex * 3 / 8
+	if level > 63 {
+		level = 63
+	}
+	return level
+}
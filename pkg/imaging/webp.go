@@ -0,0 +1,42 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+)
+
+// webpMaxEdge is the largest width or height a VP8 frame can describe.
+const webpMaxEdge = 1<<14 - 1
+
+// encodeWebP writes m as a lossy WebP: a single VP8 keyframe in a RIFF
+// container. m is expected to be opaque, like every rendition.
+func encodeWebP(w io.Writer, m *image.RGBA, quality int) error {
+	bounds := m.Bounds()
+	if bounds.Empty() || bounds.Dx() > webpMaxEdge || bounds.Dy() > webpMaxEdge {
+		return fmt.Errorf("webp: cannot encode a %dx%d image", bounds.Dx(), bounds.Dy())
+	}
+
+	frame := encodeVP8(m, quality)
+	padding := len(frame) & 1
+
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(12+len(frame)+padding))
+	copy(header[8:], "WEBPVP8 ")
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(frame)))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(frame); err != nil {
+		return err
+	}
+	if padding == 1 {
+		_, err := w.Write([]byte{0})
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"testing"
+)
+
+func TestTransformsRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	pred := make([]int32, 16)
+	for i := range pred {
+		pred[i] = 128
+	}
+
+	for k := 0; k < 1000; k++ {
+		var residual, coeffs [16]int32
+		for i := range residual {
+			residual[i] = int32(r.Intn(255) - 127)
+		}
+		forwardDCT(&residual, &coeffs)
+		out := make([]uint8, 16)
+		inverseDCT(&coeffs, pred, 4, out, 4)
+		for i := range residual {
+			if d := int32(out[i]) - 128 - residual[i]; d < -1 || d > 1 {
+				t.Fatalf("DCT sample %d = %d, want %d", i, int32(out[i])-128, residual[i])
+			}
+		}
+
+		var dcs, y2, back [16]int32
+		for i := range dcs {
+			dcs[i] = int32(r.Intn(4081) - 2040)
+		}
+		forwardWHT(&dcs, &y2)
+		inverseWHT(&y2, &back)
+		for i := range dcs {
+			if d := back[i] - dcs[i]; d < -1 || d > 1 {
+				t.Fatalf("WHT DC %d = %d, want %d", i, back[i], dcs[i])
+			}
+		}
+	}
+}
+
+func TestEncodeWebPContainer(t *testing.T) {
+	m := image.NewRGBA(image.Rect(0, 0, 37, 21))
+	for i := range m.Pix {
+		m.Pix[i] = uint8(i * 7)
+	}
+
+	var buf bytes.Buffer
+	if err := encodeWebP(&buf, m, webpQuality); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	if string(data[0:4]) != "RIFF" || string(data[8:16]) != "WEBPVP8 " {
+		t.Fatalf("header = %q, want a RIFF WEBP VP8 header", data[:16])
+	}
+	if size := binary.LittleEndian.Uint32(data[4:]); int(size) != len(data)-8 {
+		t.Errorf("RIFF size = %d, want %d", size, len(data)-8)
+	}
+	if len(data)%2 != 0 {
+		t.Errorf("file length %d is odd, chunks must be padded", len(data))
+	}
+
+	frame := data[20:]
+	if frame[0]&1 != 0 || frame[3] != 0x9d || frame[4] != 0x01 || frame[5] != 0x2a {
+		t.Fatalf("frame header % x is not a keyframe", frame[:6])
+	}
+	if w, h := binary.LittleEndian.Uint16(frame[6:]), binary.LittleEndian.Uint16(frame[8:]); w != 37 || h != 21 {
+		t.Errorf("frame size = %dx%d, want 37x21", w, h)
+	}
+
+	if err := encodeWebP(&buf, image.NewRGBA(image.Rect(0, 0, webpMaxEdge+1, 1)), webpQuality); err == nil {
+		t.Error("encodeWebP accepted an image wider than VP8 allows")
+	}
+}
+
+func TestProcessRenditions(t *testing.T) {
+	// a half transparent image: renditions are flattened onto white
+	src := image.NewNRGBA(image.Rect(0, 0, 1600, 1200))
+	for y := 0; y < 1200; y++ {
+		for x := 0; x < 1600; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 90, A: uint8(255 * (x & 1))})
+		}
+	}
+	var raw bytes.Buffer
+	if err := png.Encode(&raw, src); err != nil {
+		t.Fatal(err)
+	}
+
+	renditions, err := Process(&raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		name          string
+		width, height int
+	}{
+		{RenditionThumbnail, 200, 150},
+		{RenditionList, 480, 360},
+		{RenditionDetail, 1080, 810},
+	}
+	if len(renditions) != 2*len(want) {
+		t.Fatalf("got %d renditions, want %d", len(renditions), 2*len(want))
+	}
+	for i, rendition := range renditions {
+		w := want[i/2]
+		if rendition.Name != w.name || rendition.Width != w.width || rendition.Height != w.height {
+			t.Errorf("rendition %d = %s %dx%d, want %s %dx%d", i, rendition.Name, rendition.Width, rendition.Height, w.name, w.width, w.height)
+		}
+	}
+	if renditions[0].ContentType != jpegMimeType || renditions[1].ContentType != WebPMimeType {
+		t.Errorf("content types = %s, %s, want JPEG then WebP", renditions[0].ContentType, renditions[1].ContentType)
+	}
+	if got := RenditionObjectName("images/x", renditions[1]); got != "images/x_thumbnail.webp" {
+		t.Errorf("RenditionObjectName = %q", got)
+	}
+}
+
+func TestRenditionWebPURL(t *testing.T) {
+	if got := RenditionWebPURL("https://cdn/x_detail.jpg", RenditionList); got != "https://cdn/x_list.webp" {
+		t.Errorf("RenditionWebPURL = %q, want the list WebP URL", got)
+	}
+	if got := RenditionWebPURL("https://cdn/legacy.png", RenditionList); got != "" {
+		t.Errorf("RenditionWebPURL(legacy) = %q, want empty", got)
+	}
+}
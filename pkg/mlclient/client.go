@@ -0,0 +1,242 @@
+package mlclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yusufwib/arvigo-backend/utils"
+	"gopkg.in/resty.v1"
+)
+
+// ModelVersionHeader is the response header the ML service uses to report
+// which model produced the answer.
+const ModelVersionHeader = "X-Model-Version"
+
+const (
+	defaultBaseURL                = "https://ml.arvigo.site"
+	defaultTimeoutSeconds         = 10
+	defaultMaxRetries             = 2
+	defaultRetryBackoffMillis     = 200
+	defaultBreakerThreshold       = 5
+	defaultBreakerCooldownSeconds = 30
+)
+
+// Config holds everything needed to talk to the machine learning service.
+type Config struct {
+	BaseURL string
+	APIKey  string
+	// Timeout applies to endpoints without an entry in EndpointTimeouts.
+	Timeout          time.Duration
+	EndpointTimeouts map[string]time.Duration
+	// MaxRetries is the number of extra attempts for idempotent (GET) calls.
+	MaxRetries       int
+	RetryBackoff     time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// ConfigFromEnv reads the ML_* variables, falling back to sane defaults.
+// Per-endpoint timeouts use ML_<ENDPOINT>_TIMEOUT_SECONDS, e.g.
+// ML_FACE_SHAPE_TIMEOUT_SECONDS.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		BaseURL:          os.Getenv("ML_BASE_URL"),
+		APIKey:           os.Getenv("X_API_KEY_SECRET_ML"),
+		Timeout:          time.Duration(utils.StrToInt(os.Getenv("ML_TIMEOUT_SECONDS"), defaultTimeoutSeconds)) * time.Second,
+		EndpointTimeouts: map[string]time.Duration{},
+		MaxRetries:       utils.StrToInt(os.Getenv("ML_MAX_RETRIES"), defaultMaxRetries),
+		RetryBackoff:     time.Duration(utils.StrToInt(os.Getenv("ML_RETRY_BACKOFF_MS"), defaultRetryBackoffMillis)) * time.Millisecond,
+		BreakerThreshold: utils.StrToInt(os.Getenv("ML_BREAKER_THRESHOLD"), defaultBreakerThreshold),
+		BreakerCooldown:  time.Duration(utils.StrToInt(os.Getenv("ML_BREAKER_COOLDOWN_SECONDS"), defaultBreakerCooldownSeconds)) * time.Second,
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultBaseURL
+	}
+
+	for _, endpoint := range endpoints {
+		key := fmt.Sprintf("ML_%s_TIMEOUT_SECONDS", strings.ToUpper(endpoint))
+		if seconds := utils.StrToInt(os.Getenv(key), 0); seconds > 0 {
+			cfg.EndpointTimeouts[endpoint] = time.Duration(seconds) * time.Second
+		}
+	}
+
+	return cfg
+}
+
+// Client is a typed client for the machine learning service. It is safe for
+// concurrent use and should be shared rather than created per call.
+type Client struct {
+	cfg     Config
+	http    *resty.Client
+	breaker *breaker
+}
+
+var (
+	defaultClient *Client
+	defaultOnce   sync.Once
+)
+
+// Default returns the shared client configured from the environment.
+func Default() *Client {
+	defaultOnce.Do(func() {
+		defaultClient = New(ConfigFromEnv())
+	})
+
+	return defaultClient
+}
+
+// New creates a client for the given configuration.
+func New(cfg Config) *Client {
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+
+	httpClient := resty.New().
+		SetHeader("Content-Type", "application/json").
+		SetHeader("Accept", "application/json")
+	if cfg.APIKey != "" {
+		httpClient.SetHeader("X-API-KEY", cfg.APIKey)
+	}
+
+	return &Client{
+		cfg:     cfg,
+		http:    httpClient,
+		breaker: newBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+	}
+}
+
+func (c *Client) url(endpoint string) string {
+	return strings.TrimRight(c.cfg.BaseURL, "/") + "/" + strings.TrimLeft(endpoint, "/")
+}
+
+func (c *Client) timeout(endpoint string) time.Duration {
+	if timeout, ok := c.cfg.EndpointTimeouts[endpoint]; ok {
+		return timeout
+	}
+	return c.cfg.Timeout
+}
+
+// call performs a request and decodes a 2xx body into result, returning the
+// model version the service reported in ModelVersionHeader. GET requests
+// are retried with exponential backoff; other methods are attempted once
+// because the service may already have acted on them.
+func (c *Client) call(ctx context.Context, method, endpoint string, query map[string]string, body, result interface{}) (modelVersion string, err error) {
+	attempts := 1
+	if method == http.MethodGet {
+		attempts += c.cfg.MaxRetries
+	}
+
+	var lastErr *Error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if err := c.wait(ctx, attempt); err != nil {
+				return modelVersion, &Error{Endpoint: endpoint, Kind: KindTimeout, Err: err}
+			}
+		}
+
+		modelVersion, lastErr = c.do(ctx, method, endpoint, query, body, result)
+		if lastErr == nil {
+			return modelVersion, nil
+		}
+		if !lastErr.retryable() {
+			break
+		}
+	}
+
+	return modelVersion, lastErr
+}
+
+// wait sleeps before a retry: backoff doubles per attempt with up to 50% jitter.
+func (c *Client) wait(ctx context.Context, attempt int) error {
+	backoff := c.cfg.RetryBackoff << uint(attempt-1)
+	if backoff > 0 {
+		backoff += time.Duration(rand.Int63n(int64(backoff)/2 + 1))
+	}
+
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, endpoint string, query map[string]string, body, result interface{}) (modelVersion string, mlErr *Error) {
+	if !c.breaker.allow() {
+		return modelVersion, &Error{Endpoint: endpoint, Kind: KindUnavailable, Err: ErrCircuitOpen}
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, c.timeout(endpoint))
+	defer cancel()
+
+	req := c.http.R().SetContext(reqCtx)
+	if len(query) > 0 {
+		req.SetQueryParams(query)
+	}
+	if body != nil {
+		req.SetBody(body)
+	}
+
+	resp, err := req.Execute(method, c.url(endpoint))
+	if err != nil {
+		c.breaker.failure()
+		if errors.Is(reqCtx.Err(), context.DeadlineExceeded) {
+			return modelVersion, &Error{Endpoint: endpoint, Kind: KindTimeout, Err: err}
+		}
+		return modelVersion, &Error{Endpoint: endpoint, Kind: KindUnavailable, Err: err}
+	}
+
+	status := resp.StatusCode()
+	switch {
+	case status >= http.StatusInternalServerError:
+		c.breaker.failure()
+		return modelVersion, &Error{Endpoint: endpoint, Kind: KindUnavailable, StatusCode: status, Err: errors.New(responseMessage(resp))}
+	case status >= http.StatusBadRequest:
+		// the service answered, so it is healthy even though it refused the input
+		c.breaker.success()
+		return modelVersion, &Error{Endpoint: endpoint, Kind: KindRejected, StatusCode: status, Err: errors.New(responseMessage(resp))}
+	case !resp.IsSuccess():
+		c.breaker.success()
+		return modelVersion, &Error{Endpoint: endpoint, Kind: KindInvalidResponse, StatusCode: status, Err: fmt.Errorf("unexpected status %d", status)}
+	}
+
+	c.breaker.success()
+	modelVersion = strings.TrimSpace(resp.Header().Get(ModelVersionHeader))
+	if result == nil {
+		return modelVersion, nil
+	}
+	if err := json.Unmarshal(resp.Body(), result); err != nil {
+		return modelVersion, &Error{Endpoint: endpoint, Kind: KindInvalidResponse, StatusCode: status, Err: err}
+	}
+
+	return modelVersion, nil
+}
+
+// responseMessage extracts a short description from an error response.
+func responseMessage(resp *resty.Response) string {
+	var payload struct {
+		Message string `json:"message"`
+		Detail  string `json:"detail"`
+		Error   string `json:"error"`
+	}
+	if err := json.Unmarshal(resp.Body(), &payload); err == nil {
+		for _, message := range []string{payload.Message, payload.Detail, payload.Error} {
+			if message != "" {
+				return message
+			}
+		}
+	}
+
+	return http.StatusText(resp.StatusCode())
+}
@@ -0,0 +1,249 @@
+package mlclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestServer answers every request with the status returned by status,
+// called with the 1-based number of the request. Successful GETs answer with
+// a recommendation map and POSTs with personality percentages.
+func newTestServer(t *testing.T, status func(hit int32) int) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code := status(atomic.AddInt32(&hits, 1))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(ModelVersionHeader, "test-1")
+		w.WriteHeader(code)
+		if code == http.StatusOK && r.Method == http.MethodGet {
+			fmt.Fprint(w, `{"oval": ["1", "2"]}`)
+			return
+		}
+		if code == http.StatusOK {
+			fmt.Fprint(w, `{"percentage_of_agreeable": 50}`)
+			return
+		}
+		fmt.Fprintf(w, `{"message": "status %d"}`, code)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &hits
+}
+
+func newTestClient(baseURL string) *Client {
+	return New(Config{
+		BaseURL:      baseURL,
+		Timeout:      time.Second,
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+	})
+}
+
+func TestGetIsRetriedOnServerError(t *testing.T) {
+	server, hits := newTestServer(t, func(hit int32) int {
+		if hit < 3 {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+
+	if _, err := newTestClient(server.URL).ProductRecommendation(context.Background()); err != nil {
+		t.Fatalf("ProductRecommendation() error = %v, want success on the last retry", err)
+	}
+	if got := atomic.LoadInt32(hits); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+}
+
+func TestGetGivesUpAfterMaxRetries(t *testing.T) {
+	server, hits := newTestServer(t, func(int32) int { return http.StatusInternalServerError })
+
+	_, err := newTestClient(server.URL).ProductRecommendation(context.Background())
+
+	var mlErr *Error
+	if !errors.As(err, &mlErr) || mlErr.Kind != KindUnavailable || mlErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("error = %v, want an unavailable error with status 500", err)
+	}
+	if got := atomic.LoadInt32(hits); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+}
+
+func TestPostIsNotRetried(t *testing.T) {
+	server, hits := newTestServer(t, func(int32) int { return http.StatusInternalServerError })
+
+	_, err := newTestClient(server.URL).DetectPersonality(context.Background(), map[string]int{"EXT1": 3})
+
+	var mlErr *Error
+	if !errors.As(err, &mlErr) || mlErr.Kind != KindUnavailable {
+		t.Fatalf("error = %v, want an unavailable error", err)
+	}
+	if got := atomic.LoadInt32(hits); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestRejectedGetIsNotRetried(t *testing.T) {
+	server, hits := newTestServer(t, func(int32) int { return http.StatusBadRequest })
+
+	_, err := newTestClient(server.URL).ProductSearch(context.Background(), "glasses")
+
+	var mlErr *Error
+	if !errors.As(err, &mlErr) || mlErr.Kind != KindRejected || mlErr.Err.Error() != "status 400" {
+		t.Fatalf("error = %v, want a rejected error with the service message", err)
+	}
+	if got := atomic.LoadInt32(hits); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestModelVersionIsRead(t *testing.T) {
+	server, _ := newTestServer(t, func(int32) int { return http.StatusOK })
+
+	res, err := newTestClient(server.URL).DetectPersonality(context.Background(), map[string]int{"EXT1": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ModelVersion != "test-1" || res.Agreeable != 50 {
+		t.Errorf("result = %+v, want model version test-1 and agreeable 50", res)
+	}
+}
+
+func TestUndecodableBodyIsInvalidResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `not json`)
+	}))
+	defer server.Close()
+
+	_, err := newTestClient(server.URL).ProductRecommendation(context.Background())
+
+	var mlErr *Error
+	if !errors.As(err, &mlErr) || mlErr.Kind != KindInvalidResponse {
+		t.Fatalf("error = %v, want an invalid response error", err)
+	}
+}
+
+func TestEndpointTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL:          server.URL,
+		Timeout:          time.Second,
+		EndpointTimeouts: map[string]time.Duration{EndpointDetectPersonality: 20 * time.Millisecond},
+	})
+	_, err := client.DetectPersonality(context.Background(), map[string]int{"EXT1": 3})
+
+	var mlErr *Error
+	if !errors.As(err, &mlErr) || mlErr.Kind != KindTimeout {
+		t.Fatalf("error = %v, want a timeout error", err)
+	}
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	healthy := int32(0)
+	server, hits := newTestServer(t, func(int32) int {
+		if atomic.LoadInt32(&healthy) == 1 {
+			return http.StatusOK
+		}
+		return http.StatusInternalServerError
+	})
+
+	client := New(Config{
+		BaseURL:          server.URL,
+		Timeout:          time.Second,
+		BreakerThreshold: 2,
+		BreakerCooldown:  50 * time.Millisecond,
+	})
+	ctx := context.Background()
+	answers := map[string]int{"EXT1": 3}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.DetectPersonality(ctx, answers); err == nil {
+			t.Fatalf("call %d succeeded, want a server error", i+1)
+		}
+	}
+
+	_, err := client.DetectPersonality(ctx, answers)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("error = %v, want ErrCircuitOpen once the threshold is reached", err)
+	}
+	if got := atomic.LoadInt32(hits); got != 2 {
+		t.Errorf("requests = %d, want 2: the open circuit must not reach the service", got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	atomic.StoreInt32(&healthy, 1)
+
+	if _, err := client.DetectPersonality(ctx, answers); err != nil {
+		t.Fatalf("trial call error = %v, want success after the cooldown", err)
+	}
+	if _, err := client.DetectPersonality(ctx, answers); err != nil {
+		t.Fatalf("error = %v, want the circuit closed after a successful trial", err)
+	}
+	if got := atomic.LoadInt32(hits); got != 4 {
+		t.Errorf("requests = %d, want 4", got)
+	}
+}
+
+func TestBreakerLetsOneTrialThrough(t *testing.T) {
+	b := newBreaker(1, 10*time.Millisecond)
+	b.failure()
+
+	if b.allow() {
+		t.Fatal("allow() = true while the circuit is open")
+	}
+
+	time.Sleep(15 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("allow() = false after the cooldown, want a trial call")
+	}
+	if b.allow() {
+		t.Fatal("allow() = true during the trial, want a single trial call")
+	}
+
+	b.failure()
+	if b.allow() {
+		t.Fatal("allow() = true after a failed trial, want the circuit open again")
+	}
+
+	time.Sleep(15 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("allow() = false after the second cooldown")
+	}
+	b.success()
+	if !b.allow() || !b.allow() {
+		t.Fatal("allow() = false after a successful trial, want the circuit closed")
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{&Error{Kind: KindUnavailable}, http.StatusServiceUnavailable},
+		{&Error{Kind: KindTimeout}, http.StatusGatewayTimeout},
+		{&Error{Kind: KindRejected}, http.StatusUnprocessableEntity},
+		{&Error{Kind: KindInvalidResponse}, http.StatusBadGateway},
+		{&Error{Kind: "unknown"}, http.StatusInternalServerError},
+		{fmt.Errorf("scoring: %w", &Error{Kind: KindTimeout}), http.StatusGatewayTimeout},
+		{errors.New("not an ml error"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		if got := StatusCode(tt.err); got != tt.want {
+			t.Errorf("StatusCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package mlclient
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/yusufwib/arvigo-backend/datastruct"
+)
+
+const (
+	EndpointFaceShape             = "face_shape"
+	EndpointIsHuman               = "is_human"
+	EndpointDetectPersonality     = "detect_personality"
+	EndpointProductRecommendation = "product_recommendation"
+	EndpointProductSearch         = "product_search"
+)
+
+var endpoints = []string{
+	EndpointFaceShape,
+	EndpointIsHuman,
+	EndpointDetectPersonality,
+	EndpointProductRecommendation,
+	EndpointProductSearch,
+}
+
+// FaceShape classifies the face in a base64 encoded image. The model version
+// comes from the body or, failing that, ModelVersionHeader.
+func (c *Client) FaceShape(ctx context.Context, encodedImage string) (res datastruct.FaceTestRes, err error) {
+	version, err := c.call(ctx, http.MethodPost, EndpointFaceShape, nil, datastruct.FaceShapeMachineLearningPayload{
+		Image: encodedImage,
+	}, &res)
+	if res.ModelVersion == "" {
+		res.ModelVersion = version
+	}
+	return
+}
+
+// IsHuman reports whether a base64 encoded image contains a human face.
+func (c *Client) IsHuman(ctx context.Context, encodedImage string) (res datastruct.IsHumanRes, err error) {
+	_, err = c.call(ctx, http.MethodPost, EndpointIsHuman, nil, datastruct.FaceShapeMachineLearningPayload{
+		Image: encodedImage,
+	}, &res)
+	return
+}
+
+// DetectPersonality scores questionnaire answers, keyed by question type, on
+// the Big Five traits, recording the model version like FaceShape.
+func (c *Client) DetectPersonality(ctx context.Context, answers map[string]int) (res datastruct.PersonalityPercentages, err error) {
+	version, err := c.call(ctx, http.MethodPost, EndpointDetectPersonality, nil, answers, &res)
+	if res.ModelVersion == "" {
+		res.ModelVersion = version
+	}
+	return
+}
+
+// ProductRecommendation returns recommended product ids keyed by product id.
+func (c *Client) ProductRecommendation(ctx context.Context) (res map[string][]string, err error) {
+	_, err = c.call(ctx, http.MethodGet, EndpointProductRecommendation, nil, nil, &res)
+	return
+}
+
+// ProductSearch returns the products matching a free text query.
+func (c *Client) ProductSearch(ctx context.Context, query string) (res []datastruct.ProductFromML, err error) {
+	_, err = c.call(ctx, http.MethodGet, EndpointProductSearch, map[string]string{"query": query}, nil, &res)
+	return
+}
@@ -0,0 +1,81 @@
+package mlclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrorKind classifies why a machine learning call failed.
+type ErrorKind string
+
+const (
+	// KindUnavailable covers network failures, 5xx answers and an open circuit.
+	KindUnavailable ErrorKind = "unavailable"
+	// KindTimeout is a call that exceeded its endpoint timeout.
+	KindTimeout ErrorKind = "timeout"
+	// KindRejected is a 4xx answer: the service refused the input.
+	KindRejected ErrorKind = "rejected"
+	// KindInvalidResponse is a 2xx answer whose body could not be decoded.
+	KindInvalidResponse ErrorKind = "invalid_response"
+)
+
+// ErrCircuitOpen is wrapped when calls are skipped after repeated failures.
+var ErrCircuitOpen = errors.New("machine learning service is temporarily unavailable")
+
+// Error is returned by every client call that does not succeed.
+type Error struct {
+	Endpoint   string
+	Kind       ErrorKind
+	StatusCode int
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("ml %s: %s (status %d): %v", e.Endpoint, e.Kind, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("ml %s: %s: %v", e.Endpoint, e.Kind, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// retryable reports whether the call may succeed when simply repeated.
+func (e *Error) retryable() bool {
+	return (e.Kind == KindUnavailable || e.Kind == KindTimeout) && !errors.Is(e.Err, ErrCircuitOpen)
+}
+
+// StatusCode maps an error from the client to the HTTP status handlers should
+// answer with.
+func StatusCode(err error) int {
+	var mlErr *Error
+	if !errors.As(err, &mlErr) {
+		return http.StatusInternalServerError
+	}
+
+	switch mlErr.Kind {
+	case KindUnavailable:
+		return http.StatusServiceUnavailable
+	case KindTimeout:
+		return http.StatusGatewayTimeout
+	case KindRejected:
+		return http.StatusUnprocessableEntity
+	case KindInvalidResponse:
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Retryable reports whether err is a transient failure worth retrying later,
+// e.g. from a background job. An open circuit counts as transient here.
+func Retryable(err error) bool {
+	var mlErr *Error
+	if !errors.As(err, &mlErr) {
+		return false
+	}
+
+	return mlErr.Kind == KindUnavailable || mlErr.Kind == KindTimeout
+}
@@ -0,0 +1,117 @@
+// Package personality scores Big Five questionnaires, such as the 50-item IPIP
+// Big-Five Factor Markers (EXT1..OPN10), without the machine learning service.
+package personality
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/yusufwib/arvigo-backend/datastruct"
+)
+
+// ModelVersion identifies results produced by Score.
+const ModelVersion = "ipip-keyed-1"
+
+// Answers are given on a 1-5 scale.
+const (
+	MinAnswer = 1
+	MaxAnswer = 5
+)
+
+// Trait codes, used as the prefix of the IPIP question types. EST items are
+// keyed towards neuroticism, matching the percentage_of_neurotic field of the
+// ML service.
+const (
+	TraitExtraversion  = "EXT"
+	TraitNeuroticism   = "EST"
+	TraitAgreeableness = "AGR"
+	TraitConscientious = "CSN"
+	TraitOpenness      = "OPN"
+)
+
+var Traits = []string{TraitExtraversion, TraitNeuroticism, TraitAgreeableness, TraitConscientious, TraitOpenness}
+
+// IPIPItemsPerTrait is the number of items per trait in the 50-item IPIP set.
+const IPIPItemsPerTrait = 10
+
+var (
+	// ErrInvalidAnswer is returned when an answer is outside the 1-5 scale.
+	ErrInvalidAnswer = errors.New("answers must be between 1 and 5")
+	// ErrMissingTrait is returned when a trait has no answered items.
+	ErrMissingTrait = errors.New("every trait needs at least one item")
+)
+
+// Item is one answered question with its scoring key.
+type Item struct {
+	Type         string
+	Trait        string
+	ReverseKeyed bool
+	Answer       int
+}
+
+// Score applies the standard keyed scoring: reverse-keyed answers are flipped
+// (6 - answer), each trait's answers are summed and the possible range of the
+// sum is scaled to a 0-100 percentage.
+func Score(items []Item) (res datastruct.PersonalityPercentages, err error) {
+	totals := map[string]int{}
+	counts := map[string]int{}
+	for _, item := range items {
+		answer := item.Answer
+		if answer < MinAnswer || answer > MaxAnswer {
+			return res, fmt.Errorf("%w: %s is %d", ErrInvalidAnswer, item.Type, answer)
+		}
+		if item.ReverseKeyed {
+			answer = MinAnswer + MaxAnswer - answer
+		}
+		totals[item.Trait] += answer
+		counts[item.Trait]++
+	}
+
+	scores := map[string]float64{}
+	for _, trait := range Traits {
+		if counts[trait] == 0 {
+			return res, fmt.Errorf("%w: %s has none", ErrMissingTrait, trait)
+		}
+
+		minTotal, maxTotal := MinAnswer*counts[trait], MaxAnswer*counts[trait]
+		percentage := float64(totals[trait]-minTotal) / float64(maxTotal-minTotal) * 100
+		scores[trait] = math.Round(percentage*100) / 100
+	}
+
+	res = datastruct.PersonalityPercentages{
+		Agreeable:     scores[TraitAgreeableness],
+		Conscientious: scores[TraitConscientious],
+		Extraversion:  scores[TraitExtraversion],
+		Neurotic:      scores[TraitNeuroticism],
+		Openness:      scores[TraitOpenness],
+		ModelVersion:  ModelVersion,
+	}
+	return
+}
+
+// IsIPIP50 reports whether the items are exactly the 50 IPIP question types,
+// EXT1..EXT10 through OPN1..OPN10, which is the only input the ML service
+// was trained on.
+func IsIPIP50(items []Item) bool {
+	if len(items) != len(Traits)*IPIPItemsPerTrait {
+		return false
+	}
+
+	expected := make(map[string]bool, len(items))
+	for _, trait := range Traits {
+		for i := 1; i <= IPIPItemsPerTrait; i++ {
+			expected[fmt.Sprintf("%s%d", trait, i)] = true
+		}
+	}
+
+	for _, item := range items {
+		if !expected[strings.ToUpper(item.Type)] {
+			return false
+		}
+		delete(expected, strings.ToUpper(item.Type))
+	}
+
+	return len(expected) == 0
+}
@@ -0,0 +1,155 @@
+package personality
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/yusufwib/arvigo-backend/datastruct"
+)
+
+// reverseKeyed are the reverse-keyed items of the IPIP Big-Five Factor Markers.
+var reverseKeyed = map[string]bool{
+	"EXT2": true, "EXT4": true, "EXT6": true, "EXT8": true, "EXT10": true,
+	"EST2": true, "EST4": true,
+	"AGR1": true, "AGR3": true, "AGR5": true, "AGR7": true,
+	"CSN2": true, "CSN4": true, "CSN6": true, "CSN8": true,
+	"OPN2": true, "OPN4": true, "OPN6": true,
+}
+
+// ipip50 returns the 50 IPIP items, each answered by answer.
+func ipip50(answer func(itemType string) int) []Item {
+	items := make([]Item, 0, len(Traits)*IPIPItemsPerTrait)
+	for _, trait := range Traits {
+		for i := 1; i <= IPIPItemsPerTrait; i++ {
+			itemType := fmt.Sprintf("%s%d", trait, i)
+			items = append(items, Item{
+				Type:         itemType,
+				Trait:        trait,
+				ReverseKeyed: reverseKeyed[itemType],
+				Answer:       answer(itemType),
+			})
+		}
+	}
+
+	return items
+}
+
+func percentages(res datastruct.PersonalityPercentages) map[string]float64 {
+	return map[string]float64{
+		TraitExtraversion:  res.Extraversion,
+		TraitNeuroticism:   res.Neurotic,
+		TraitAgreeableness: res.Agreeable,
+		TraitConscientious: res.Conscientious,
+		TraitOpenness:      res.Openness,
+	}
+}
+
+func TestScoreNeutralAnswers(t *testing.T) {
+	res, err := Score(ipip50(func(string) int { return 3 }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for trait, got := range percentages(res) {
+		if got != 50 {
+			t.Errorf("%s = %v, want 50", trait, got)
+		}
+	}
+	if res.ModelVersion != ModelVersion {
+		t.Errorf("ModelVersion = %q, want %q", res.ModelVersion, ModelVersion)
+	}
+}
+
+func TestScoreFlipsReverseKeyedItems(t *testing.T) {
+	// agreeing with every positively keyed item and disagreeing with every
+	// reverse-keyed one is the highest possible score for each trait
+	res, err := Score(ipip50(func(itemType string) int {
+		if reverseKeyed[itemType] {
+			return MinAnswer
+		}
+		return MaxAnswer
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for trait, got := range percentages(res) {
+		if got != 100 {
+			t.Errorf("%s = %v, want 100", trait, got)
+		}
+	}
+
+	// answering 5 everywhere only maxes out the share of positively keyed items
+	res, err = Score(ipip50(func(string) int { return MaxAnswer }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{
+		TraitExtraversion:  50,
+		TraitNeuroticism:   80,
+		TraitAgreeableness: 60,
+		TraitConscientious: 60,
+		TraitOpenness:      70,
+	}
+	for trait, got := range percentages(res) {
+		if got != want[trait] {
+			t.Errorf("%s = %v, want %v", trait, got, want[trait])
+		}
+	}
+}
+
+func TestScoreErrors(t *testing.T) {
+	for _, answer := range []int{MinAnswer - 1, MaxAnswer + 1} {
+		items := ipip50(func(string) int { return 3 })
+		items[7].Answer = answer
+
+		if _, err := Score(items); !errors.Is(err, ErrInvalidAnswer) {
+			t.Errorf("answer %d: error = %v, want ErrInvalidAnswer", answer, err)
+		}
+	}
+
+	var withoutOpenness []Item
+	for _, item := range ipip50(func(string) int { return 3 }) {
+		if item.Trait != TraitOpenness {
+			withoutOpenness = append(withoutOpenness, item)
+		}
+	}
+	if _, err := Score(withoutOpenness); !errors.Is(err, ErrMissingTrait) {
+		t.Errorf("error = %v, want ErrMissingTrait", err)
+	}
+}
+
+func TestIsIPIP50(t *testing.T) {
+	full := func() []Item { return ipip50(func(string) int { return 3 }) }
+
+	if !IsIPIP50(full()) {
+		t.Error("IsIPIP50(full set) = false, want true")
+	}
+
+	lowercase := full()
+	lowercase[0].Type = "ext1"
+	if !IsIPIP50(lowercase) {
+		t.Error("IsIPIP50 with a lowercase type = false, want true")
+	}
+
+	if IsIPIP50(full()[1:]) {
+		t.Error("IsIPIP50(partial set) = true, want false")
+	}
+
+	extra := append(full(), Item{Type: "EXT11", Trait: TraitExtraversion, Answer: 3})
+	if IsIPIP50(extra) {
+		t.Error("IsIPIP50(set with an extra item) = true, want false")
+	}
+
+	duplicate := full()
+	duplicate[1].Type = duplicate[0].Type
+	if IsIPIP50(duplicate) {
+		t.Error("IsIPIP50(set with a duplicate item) = true, want false")
+	}
+
+	renamed := full()
+	renamed[49].Type = "OPN51"
+	if IsIPIP50(renamed) {
+		t.Error("IsIPIP50(set with an unknown item) = true, want false")
+	}
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/option"
+)
+
+type GCSStorage struct {
+	client *storage.Client
+	bucket string
+}
+
+func NewGCSStorage(ctx context.Context) (*GCSStorage, error) {
+	credFile := os.Getenv("STORAGE_GCS_CREDENTIALS_FILE")
+	if credFile == "" {
+		credFile = "./gcp-cred.json"
+	}
+
+	keyJSON, err := ioutil.ReadFile(credFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account key JSON file: %v", err)
+	}
+
+	creds, err := google.CredentialsFromJSON(ctx, keyJSON, storage.ScopeReadWrite)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %v", err)
+	}
+
+	client, err := storage.NewClient(ctx, option.WithCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %v", err)
+	}
+
+	return &GCSStorage{
+		client: client,
+		bucket: bucketName(),
+	}, nil
+}
+
+func (s *GCSStorage) Put(ctx context.Context, objectName string, data io.Reader, contentType string) (publicURL string, err error) {
+	objectName = trimObjectName(objectName)
+
+	writer := s.client.Bucket(s.bucket).Object(objectName).NewWriter(ctx)
+	if contentType != "" {
+		writer.ContentType = contentType
+	}
+
+	if _, err = io.Copy(writer, data); err != nil {
+		writer.Close()
+		return publicURL, fmt.Errorf("failed to upload object to GCS: %v", err)
+	}
+
+	if err = writer.Close(); err != nil {
+		return publicURL, fmt.Errorf("failed to upload object to GCS: %v", err)
+	}
+
+	return s.publicURL(objectName), nil
+}
+
+func (s *GCSStorage) Get(ctx context.Context, objectName string) ([]byte, error) {
+	reader, err := s.client.Bucket(s.bucket).Object(trimObjectName(objectName)).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GCS object: %v", err)
+	}
+	defer reader.Close()
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GCS object: %v", err)
+	}
+
+	return data, nil
+}
+
+func (s *GCSStorage) Delete(ctx context.Context, objectName string) error {
+	err := s.client.Bucket(s.bucket).Object(trimObjectName(objectName)).Delete(ctx)
+	if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("failed to delete object from GCS: %v", err)
+	}
+
+	return nil
+}
+
+func (s *GCSStorage) SignedURL(ctx context.Context, objectName string, expiry time.Duration) (string, error) {
+	signedURL, err := s.client.Bucket(s.bucket).SignedURL(trimObjectName(objectName), &storage.SignedURLOptions{
+		Scheme:  storage.SigningSchemeV4,
+		Method:  http.MethodGet,
+		Expires: time.Now().Add(expiry),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GCS URL: %v", err)
+	}
+
+	return signedURL, nil
+}
+
+func (s *GCSStorage) Exists(ctx context.Context, objectName string) (bool, error) {
+	_, err := s.client.Bucket(s.bucket).Object(trimObjectName(objectName)).Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check GCS object: %v", err)
+	}
+
+	return true, nil
+}
+
+func (s *GCSStorage) ObjectName(publicURL string) (string, bool) {
+	prefix := s.publicURL("")
+	if !strings.HasPrefix(publicURL, prefix) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(publicURL, prefix), true
+}
+
+func (s *GCSStorage) Close() error {
+	return s.client.Close()
+}
+
+func (s *GCSStorage) publicURL(objectName string) string {
+	return fmt.Sprintf("https://storage.googleapis.com/%s/%s", s.bucket, objectName)
+}
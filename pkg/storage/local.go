@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalStorage keeps objects on the local filesystem so uploads work without
+// cloud credentials. The files are served by the API under LocalURLPrefix.
+type LocalStorage struct {
+	dir     string
+	baseURL string
+}
+
+const LocalURLPrefix = "/uploads"
+
+func NewLocalStorage() (*LocalStorage, error) {
+	dir := LocalDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create local storage dir: %v", err)
+	}
+
+	baseURL := strings.TrimRight(os.Getenv("STORAGE_LOCAL_BASE_URL"), "/")
+	if baseURL == "" {
+		port := os.Getenv("PORT")
+		if port == "" {
+			port = "8080"
+		}
+		baseURL = "http://localhost:" + port + LocalURLPrefix
+	}
+
+	return &LocalStorage{
+		dir:     dir,
+		baseURL: baseURL,
+	}, nil
+}
+
+// LocalDir returns the directory used by the local driver.
+func LocalDir() string {
+	dir := os.Getenv("STORAGE_LOCAL_DIR")
+	if dir == "" {
+		dir = "./public/uploads"
+	}
+
+	return dir
+}
+
+func (s *LocalStorage) Put(ctx context.Context, objectName string, data io.Reader, contentType string) (publicURL string, err error) {
+	path, err := s.path(objectName)
+	if err != nil {
+		return publicURL, err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return publicURL, fmt.Errorf("failed to create object dir: %v", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return publicURL, fmt.Errorf("failed to create object file: %v", err)
+	}
+	defer file.Close()
+
+	if _, err = io.Copy(file, data); err != nil {
+		os.Remove(path)
+		return publicURL, fmt.Errorf("failed to write object file: %v", err)
+	}
+
+	return s.baseURL + "/" + trimObjectName(objectName), nil
+}
+
+func (s *LocalStorage) Get(ctx context.Context, objectName string) ([]byte, error) {
+	path, err := s.path(objectName)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(path)
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, objectName string) error {
+	path, err := s.path(objectName)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete object file: %v", err)
+	}
+
+	return nil
+}
+
+// SignedURL returns the public URL: local files are served without access control.
+func (s *LocalStorage) SignedURL(ctx context.Context, objectName string, expiry time.Duration) (string, error) {
+	return s.baseURL + "/" + trimObjectName(objectName), nil
+}
+
+func (s *LocalStorage) Exists(ctx context.Context, objectName string) (bool, error) {
+	path, err := s.path(objectName)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *LocalStorage) ObjectName(publicURL string) (string, bool) {
+	prefix := s.baseURL + "/"
+	if !strings.HasPrefix(publicURL, prefix) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(publicURL, prefix), true
+}
+
+func (s *LocalStorage) Close() error {
+	return nil
+}
+
+// path resolves the object inside the storage dir and refuses names escaping it.
+func (s *LocalStorage) path(objectName string) (string, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(trimObjectName(objectName)))
+	rel, err := filepath.Rel(s.dir, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("invalid object name: %s", objectName)
+	}
+
+	return path, nil
+}
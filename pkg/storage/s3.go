@@ -0,0 +1,330 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3Algorithm       = "AWS4-HMAC-SHA256"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3TimeFormat      = "20060102T150405Z"
+	s3DateFormat      = "20060102"
+)
+
+// S3Storage talks to any S3-compatible API (AWS S3, MinIO, R2...) using
+// path-style requests signed with AWS Signature V4.
+type S3Storage struct {
+	client    *http.Client
+	endpoint  *url.URL
+	region    string
+	accessKey string
+	secretKey string
+	bucket    string
+	publicURL string
+}
+
+func NewS3Storage() (*S3Storage, error) {
+	rawEndpoint := os.Getenv("STORAGE_S3_ENDPOINT")
+	if rawEndpoint == "" {
+		return nil, fmt.Errorf("STORAGE_S3_ENDPOINT is required for the s3 storage driver")
+	}
+
+	endpoint, err := url.Parse(strings.TrimRight(rawEndpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid STORAGE_S3_ENDPOINT: %s", rawEndpoint)
+	}
+
+	region := os.Getenv("STORAGE_S3_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	bucket := bucketName()
+	publicURL := strings.TrimRight(os.Getenv("STORAGE_S3_PUBLIC_URL"), "/")
+	if publicURL == "" {
+		publicURL = endpoint.String() + "/" + bucket
+	}
+
+	return &S3Storage{
+		client:    &http.Client{Timeout: 60 * time.Second},
+		endpoint:  endpoint,
+		region:    region,
+		accessKey: os.Getenv("STORAGE_S3_ACCESS_KEY"),
+		secretKey: os.Getenv("STORAGE_S3_SECRET_KEY"),
+		bucket:    bucket,
+		publicURL: publicURL,
+	}, nil
+}
+
+func (s *S3Storage) Put(ctx context.Context, objectName string, data io.Reader, contentType string) (publicURL string, err error) {
+	objectName = trimObjectName(objectName)
+
+	body, err := ioutil.ReadAll(data)
+	if err != nil {
+		return publicURL, fmt.Errorf("failed to read object data: %v", err)
+	}
+
+	req, err := s.newRequest(ctx, http.MethodPut, objectName, body)
+	if err != nil {
+		return publicURL, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if _, err = s.do(req, body); err != nil {
+		return publicURL, fmt.Errorf("failed to upload object to S3: %v", err)
+	}
+
+	return s.publicURL + "/" + objectName, nil
+}
+
+func (s *S3Storage) Get(ctx context.Context, objectName string) ([]byte, error) {
+	req, err := s.newRequest(ctx, http.MethodGet, trimObjectName(objectName), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.send(req, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read S3 object: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("failed to read S3 object: request failed with status code %d", resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read S3 object: %v", err)
+	}
+
+	return data, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, objectName string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, trimObjectName(objectName), nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err = s.do(req, nil); err != nil {
+		return fmt.Errorf("failed to delete object from S3: %v", err)
+	}
+
+	return nil
+}
+
+func (s *S3Storage) SignedURL(ctx context.Context, objectName string, expiry time.Duration) (string, error) {
+	return s.presign(s.objectURL(trimObjectName(objectName)), time.Now().UTC(), expiry), nil
+}
+
+func (s *S3Storage) Exists(ctx context.Context, objectName string) (bool, error) {
+	req, err := s.newRequest(ctx, http.MethodHead, trimObjectName(objectName), nil)
+	if err != nil {
+		return false, err
+	}
+
+	statusCode, err := s.do(req, nil)
+	if statusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check S3 object: %v", err)
+	}
+
+	return true, nil
+}
+
+func (s *S3Storage) ObjectName(publicURL string) (string, bool) {
+	prefix := s.publicURL + "/"
+	if !strings.HasPrefix(publicURL, prefix) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(publicURL, prefix), true
+}
+
+func (s *S3Storage) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+func (s *S3Storage) objectURL(objectName string) *url.URL {
+	objectURL := *s.endpoint
+	objectURL.Path = strings.TrimRight(s.endpoint.Path, "/") + "/" + s.bucket + "/" + objectName
+	objectURL.RawPath = strings.TrimRight(s.endpoint.EscapedPath(), "/") + "/" + s3Escape(s.bucket, false) + "/" + s3Escape(objectName, true)
+	return &objectURL
+}
+
+func (s *S3Storage) newRequest(ctx context.Context, method, objectName string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(objectName).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 request: %v", err)
+	}
+	req.ContentLength = int64(len(body))
+
+	return req, nil
+}
+
+// do executes the signed request and turns non-2xx answers into errors.
+func (s *S3Storage) do(req *http.Request, body []byte) (statusCode int, err error) {
+	resp, err := s.send(req, body)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode, fmt.Errorf("request failed with status code %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	return resp.StatusCode, nil
+}
+
+// send signs the request with the Authorization header and executes it.
+func (s *S3Storage) send(req *http.Request, body []byte) (*http.Response, error) {
+	s.sign(req, sha256Hex(body), time.Now().UTC())
+	return s.client.Do(req)
+}
+
+// sign adds the X-Amz-* headers and the Signature V4 Authorization header
+// for a request made at t.
+func (s *S3Storage) sign(req *http.Request, payloadHash string, t time.Time) {
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", t.Format(s3TimeFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if req.Header.Get("Content-Type") != "" {
+		signedHeaders = append(signedHeaders, "content-type")
+	}
+	sort.Strings(signedHeaders)
+
+	var canonicalHeaders strings.Builder
+	for _, header := range signedHeaders {
+		value := req.Header.Get(header)
+		if header == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(header + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, s.accessKey, s.scope(t), strings.Join(signedHeaders, ";"), s.signature(t, canonicalRequest)))
+}
+
+// presign returns objectURL with a query string signature valid for expiry from t.
+func (s *S3Storage) presign(objectURL *url.URL, t time.Time, expiry time.Duration) string {
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", s3Algorithm)
+	query.Set("X-Amz-Credential", s.accessKey+"/"+s.scope(t))
+	query.Set("X-Amz-Date", t.Format(s3TimeFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expiry.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		objectURL.EscapedPath(),
+		canonicalQuery(query),
+		"host:" + objectURL.Host + "\n",
+		"host",
+		s3UnsignedPayload,
+	}, "\n")
+
+	query.Set("X-Amz-Signature", s.signature(t, canonicalRequest))
+	signedURL := *objectURL
+	signedURL.RawQuery = canonicalQuery(query)
+
+	return signedURL.String()
+}
+
+func (s *S3Storage) scope(t time.Time) string {
+	return t.Format(s3DateFormat) + "/" + s.region + "/s3/aws4_request"
+}
+
+func (s *S3Storage) signature(t time.Time, canonicalRequest string) string {
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		t.Format(s3TimeFormat),
+		s.scope(t),
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), t.Format(s3DateFormat))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			pairs = append(pairs, s3Escape(k, false)+"="+s3Escape(v, false))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape URI-encodes everything except unreserved characters and, for
+// object paths, '/'.
+func s3Escape(value string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && keepSlash) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
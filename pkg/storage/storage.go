@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DriverGCS   = "gcs"
+	DriverS3    = "s3"
+	DriverLocal = "local"
+)
+
+// Storage is implemented by every object storage backend used for uploads.
+type Storage interface {
+	// Put writes the object and returns its public URL.
+	Put(ctx context.Context, objectName string, data io.Reader, contentType string) (publicURL string, err error)
+	// Get reads the whole object.
+	Get(ctx context.Context, objectName string) ([]byte, error)
+	Delete(ctx context.Context, objectName string) error
+	SignedURL(ctx context.Context, objectName string, expiry time.Duration) (string, error)
+	Exists(ctx context.Context, objectName string) (bool, error)
+	// ObjectName maps a public URL produced by Put back to its object name.
+	ObjectName(publicURL string) (objectName string, ok bool)
+	Close() error
+}
+
+var (
+	defaultStorage Storage
+	defaultMu      sync.Mutex
+)
+
+// Default returns the shared storage backend selected by STORAGE_DRIVER.
+// The backend and its client are created once and reused by every upload;
+// a failed initialization is retried on the next call.
+func Default() (Storage, error) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	if defaultStorage != nil {
+		return defaultStorage, nil
+	}
+
+	store, err := New(os.Getenv("STORAGE_DRIVER"))
+	if err != nil {
+		return nil, err
+	}
+
+	defaultStorage = store
+	return defaultStorage, nil
+}
+
+// New creates a storage backend for the given driver, defaulting to GCS.
+func New(driver string) (Storage, error) {
+	var (
+		store Storage
+		err   error
+	)
+
+	// assign through concrete variables so a failed constructor yields a nil interface
+	switch strings.ToLower(strings.TrimSpace(driver)) {
+	case "", DriverGCS:
+		var gcs *GCSStorage
+		if gcs, err = NewGCSStorage(context.Background()); err == nil {
+			store = gcs
+		}
+	case DriverS3:
+		var s3 *S3Storage
+		if s3, err = NewS3Storage(); err == nil {
+			store = s3
+		}
+	case DriverLocal:
+		var local *LocalStorage
+		if local, err = NewLocalStorage(); err == nil {
+			store = local
+		}
+	default:
+		err = fmt.Errorf("unsupported storage driver: %s", driver)
+	}
+
+	return store, err
+}
+
+// Close releases the shared backend, if it was ever created.
+func Close() error {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	if defaultStorage == nil {
+		return nil
+	}
+
+	err := defaultStorage.Close()
+	defaultStorage = nil
+	return err
+}
+
+func bucketName() string {
+	bucket := os.Getenv("STORAGE_BUCKET_NAME")
+	if bucket == "" {
+		bucket = "arvigo-bucket"
+	}
+
+	return bucket
+}
+
+func trimObjectName(objectName string) string {
+	return strings.TrimLeft(objectName, "/")
+}
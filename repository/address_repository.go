@@ -2,9 +2,11 @@ package repository
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 
 	"github.com/yusufwib/arvigo-backend/datastruct"
+	"gorm.io/gorm"
 )
 
 func GetAddressByID(addressID uint64) (res string, location string, statusCode int, err error) {
@@ -44,7 +46,56 @@ func GetAddressByID(addressID uint64) (res string, location string, statusCode i
 		return res, location, http.StatusInternalServerError, err
 	}
 
-	res = fmt.Sprintf("%s, %s, %s, %s, %s, %d", address.Street, subDistrict.Name, district.Name, city.Name, province.Name, postalCode.PostalCodeNumber)
-	location = fmt.Sprintf("%s, %s", city.Name, province.Name)
+	res, location = formatAddress(address.Street, subDistrict.Name, district.Name, city.Name, province.Name, postalCode.PostalCodeNumber)
+	return
+}
+
+// addressText is an address formatted like GetAddressByID does.
+type addressText struct {
+	Address  string
+	Location string
+}
+
+// getAddressesByIDs formats the given addresses with a single query, keyed by
+// address id. Addresses whose region cannot be resolved are left out.
+func getAddressesByIDs(db *gorm.DB, addressIDs []uint64) (res map[uint64]addressText) {
+	res = make(map[uint64]addressText)
+	if len(addressIDs) == 0 {
+		return
+	}
+
+	var rows []struct {
+		ID              uint64 `gorm:"column:id"`
+		Street          string `gorm:"column:street"`
+		SubdistrictName string `gorm:"column:subdis_name"`
+		DistrictName    string `gorm:"column:dis_name"`
+		CityName        string `gorm:"column:city_name"`
+		ProvinceName    string `gorm:"column:prov_name"`
+		PostalCode      uint64 `gorm:"column:postal_code"`
+	}
+	if err := db.Table("addresses a").
+		Select("a.id, a.street, sd.subdis_name, d.dis_name, c.city_name, p.prov_name, pc.postal_code").
+		Joins("JOIN provinces p ON p.prov_id = a.province_id").
+		Joins("JOIN cities c ON c.city_id = a.city_id").
+		Joins("JOIN districts d ON d.dis_id = a.district_id").
+		Joins("JOIN subdistricts sd ON sd.subdis_id = a.subdistrict_id").
+		Joins("JOIN postal_codes pc ON pc.postal_id = a.postal_code_id").
+		Where("a.id IN (?)", addressIDs).
+		Scan(&rows).Error; err != nil {
+		log.Println("Failed to load addresses:", err)
+		return
+	}
+
+	for _, v := range rows {
+		address, location := formatAddress(v.Street, v.SubdistrictName, v.DistrictName, v.CityName, v.ProvinceName, v.PostalCode)
+		res[v.ID] = addressText{Address: address, Location: location}
+	}
+
+	return
+}
+
+func formatAddress(street, subDistrict, district, city, province string, postalCode uint64) (address, location string) {
+	address = fmt.Sprintf("%s, %s, %s, %s, %s, %d", street, subDistrict, district, city, province, postalCode)
+	location = fmt.Sprintf("%s, %s", city, province)
 	return
 }
@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yusufwib/arvigo-backend/constant"
+	"github.com/yusufwib/arvigo-backend/datastruct"
+	"github.com/yusufwib/arvigo-backend/pkg/imaging"
+	"github.com/yusufwib/arvigo-backend/pkg/storage"
+	"github.com/yusufwib/arvigo-backend/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAssetGraceHours = 72
+)
+
+// RegisterAsset records an uploaded object so the sweeper can reclaim it once
+// nothing references it anymore.
+func RegisterAsset(kind string, ownerID uint64, objectName, url string, size int64) (err error) {
+	db := Database()
+	currentTime := time.Now()
+
+	payload := datastruct.Asset{
+		ObjectName: objectName,
+		URL:        url,
+		Kind:       kind,
+		OwnerID:    ownerID,
+		Size:       size,
+		CreatedAt:  currentTime,
+		UpdatedAt:  currentTime,
+	}
+
+	return db.Create(&payload).Error
+}
+
+// SweepOrphanedAssets deletes stored objects that have not been referenced by
+// products, brands, avatars or face scans for longer than the grace period.
+func SweepOrphanedAssets(dryRun bool) (res datastruct.AssetSweepReport, statusCode int, err error) {
+	statusCode = http.StatusOK
+
+	var (
+		db          = Database()
+		currentTime = time.Now()
+		graceHours  = utils.StrToInt(os.Getenv("ASSET_GC_GRACE_HOURS"), defaultAssetGraceHours)
+		cutoff      = currentTime.Add(-time.Duration(graceHours) * time.Hour)
+		assets      []datastruct.Asset
+	)
+
+	res.DryRun = dryRun
+	res.GracePeriodHours = graceHours
+
+	store, err := storage.Default()
+	if err != nil {
+		return res, http.StatusInternalServerError, fmt.Errorf("storage is not available: %v", err)
+	}
+
+	referenced, err := getReferencedAssetURLs(db)
+	if err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	if err = db.Where("deleted_at IS NULL").Find(&assets).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	// register objects uploaded before the registry existed so replacing them later is tracked
+	known := make(map[string]bool, len(assets))
+	for _, v := range assets {
+		known[v.URL] = true
+	}
+	for url, kind := range referenced {
+		if known[url] {
+			continue
+		}
+
+		objectName, ok := store.ObjectName(url)
+		if !ok {
+			continue
+		}
+
+		if !dryRun {
+			asset := datastruct.Asset{
+				ObjectName:   objectName,
+				URL:          url,
+				Kind:         kind,
+				ReferencedAt: &currentTime,
+				CreatedAt:    currentTime,
+				UpdatedAt:    currentTime,
+			}
+			if err := db.Create(&asset).Error; err != nil {
+				res.Errors = append(res.Errors, fmt.Sprintf("register %s: %v", objectName, err))
+				continue
+			}
+		}
+		res.Registered++
+	}
+
+	var referencedIDs []uint64
+	for _, v := range assets {
+		res.Scanned++
+
+		if _, ok := referenced[v.URL]; ok {
+			res.Referenced++
+			referencedIDs = append(referencedIDs, v.ID)
+			continue
+		}
+
+		lastSeen := v.CreatedAt
+		if v.ReferencedAt != nil {
+			lastSeen = *v.ReferencedAt
+		}
+		if lastSeen.After(cutoff) {
+			continue
+		}
+
+		if !dryRun {
+			if err := store.Delete(context.Background(), v.ObjectName); err != nil {
+				res.Errors = append(res.Errors, fmt.Sprintf("delete %s: %v", v.ObjectName, err))
+				continue
+			}
+
+			if err := db.Model(&datastruct.Asset{}).
+				Where("id = ?", v.ID).
+				Updates(map[string]interface{}{
+					"deleted_at": currentTime,
+					"updated_at": currentTime,
+				}).Error; err != nil {
+				res.Errors = append(res.Errors, fmt.Sprintf("mark %s deleted: %v", v.ObjectName, err))
+			}
+		}
+
+		res.Deleted++
+		res.ReclaimedBytes += v.Size
+		res.DeletedObjects = append(res.DeletedObjects, v.ObjectName)
+	}
+
+	if len(referencedIDs) > 0 && !dryRun {
+		if err = db.Model(&datastruct.Asset{}).
+			Where("id IN (?)", referencedIDs).
+			Updates(map[string]interface{}{
+				"referenced_at": currentTime,
+				"updated_at":    currentTime,
+			}).Error; err != nil {
+			return res, http.StatusInternalServerError, err
+		}
+	}
+
+	log.Printf("Asset sweep: scanned %d, deleted %d, reclaimed %d bytes (dry run: %t)",
+		res.Scanned, res.Deleted, res.ReclaimedBytes, dryRun)
+	return
+}
+
+// getReferencedAssetURLs maps every image URL still in use to its asset kind.
+func getReferencedAssetURLs(db *gorm.DB) (referenced map[string]string, err error) {
+	referenced = make(map[string]string)
+
+	var productImages []string
+	if err = db.Table("products").
+		Where("images IS NOT NULL AND images != ''").
+		Pluck("images", &productImages).Error; err != nil {
+		return
+	}
+	for _, images := range productImages {
+		for _, url := range utils.ConvertStringToSlice(images, ",") {
+			addReferencedImage(referenced, strings.TrimSpace(url), constant.AssetKindProduct)
+		}
+	}
+
+	var brandImages []string
+	if err = db.Table("brands").
+		Where("image IS NOT NULL AND image != ''").
+		Pluck("image", &brandImages).Error; err != nil {
+		return
+	}
+	for _, url := range brandImages {
+		addReferencedImage(referenced, url, constant.AssetKindBrand)
+	}
+
+	var marketplaceLogos []string
+	if err = db.Table("marketplaces").
+		Where("image IS NOT NULL AND image != ''").
+		Pluck("image", &marketplaceLogos).Error; err != nil {
+		return
+	}
+	for _, url := range marketplaceLogos {
+		addReferencedImage(referenced, url, constant.AssetKindMarketplace)
+	}
+
+	var avatars []string
+	if err = db.Table("users").
+		Where("avatar IS NOT NULL AND avatar != ''").
+		Pluck("avatar", &avatars).Error; err != nil {
+		return
+	}
+	for _, url := range avatars {
+		addReferencedImage(referenced, url, constant.AssetKindAvatar)
+	}
+
+	// face scan photos are kept for the scans in the history and for jobs still
+	// to run; rejected scans and failed jobs are reclaimed after the grace period
+	var faceScans []string
+	if err = db.Table("face_scans").
+		Where("image_url IS NOT NULL AND image_url != ''").
+		Pluck("image_url", &faceScans).Error; err != nil {
+		return
+	}
+
+	var jobImages []string
+	if err = db.Table("face_shape_jobs").
+		Where("status IN (?) AND image_url IS NOT NULL AND image_url != ''",
+			[]string{constant.FaceShapeJobStatusPending, constant.FaceShapeJobStatusProcessing}).
+		Pluck("image_url", &jobImages).Error; err != nil {
+		return
+	}
+
+	for _, url := range append(faceScans, jobImages...) {
+		referenced[url] = constant.AssetKindFaceScan
+	}
+
+	return
+}
+
+// addReferencedImage marks the stored URL and its derived renditions as referenced.
+func addReferencedImage(referenced map[string]string, url, kind string) {
+	if url == "" {
+		return
+	}
+
+	referenced[url] = kind
+	referenced[imaging.RenditionURL(url, imaging.RenditionThumbnail)] = kind
+	referenced[imaging.RenditionURL(url, imaging.RenditionList)] = kind
+
+	for _, rendition := range []string{imaging.RenditionThumbnail, imaging.RenditionList, imaging.RenditionDetail} {
+		if webpURL := imaging.RenditionWebPURL(url, rendition); webpURL != "" {
+			referenced[webpURL] = kind
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/yusufwib/arvigo-backend/constant"
 	"github.com/yusufwib/arvigo-backend/datastruct"
 )
 
@@ -38,9 +39,9 @@ func CreateBrand(data datastruct.BrandInput) (statusCode int, err error) {
 		currentTime = time.Now()
 	)
 
-	url, err := UploadImageToGCS(data.Image)
+	url, err := UploadImage(data.Image, constant.AssetKindBrand)
 	if err != nil {
-		return
+		return ImageStatusCode(err), err
 	}
 
 	brandPayload := datastruct.Brand{
@@ -66,9 +67,9 @@ func UpdateBrand(brandID uint64, data datastruct.BrandInput) (statusCode int, er
 		currentTime = time.Now()
 	)
 
-	url, err := UploadImageToGCS(data.Image)
+	url, err := UploadImage(data.Image, constant.AssetKindBrand)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return ImageStatusCode(err), err
 	}
 
 	brandPayload := datastruct.Brand{
@@ -105,6 +106,7 @@ func GetListProductByBrand(brandID uint64) (res []datastruct.HomeProduct, status
 
 	for i, v := range res {
 		res[i].Image = strings.Split(v.Image, ",")[0]
+		res[i].ImageRenditions = GetImageRenditions(res[i].Image)
 	}
 
 	return
@@ -1,28 +1,291 @@
 package repository
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"regexp"
 	"strings"
+	"time"
 
+	"github.com/yusufwib/arvigo-backend/constant"
 	"github.com/yusufwib/arvigo-backend/datastruct"
+	"gorm.io/gorm"
 )
 
+var attributeCodePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
 func GetCategories() (res []datastruct.CategoryResponse, statusCode int, err error) {
 	db := Database()
 	statusCode = http.StatusOK
 
-	if err = db.Table("categories").Select("id, name").Find(&res).Error; err != nil {
+	if err = db.Table("categories").Select("id, name, parent_id").Find(&res).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	return
+}
+
+// GetCategoryTree returns the categories nested under their parents, each with
+// the attributes defined directly on it.
+func GetCategoryTree() (res []datastruct.CategoryTree, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	var categories []datastruct.Category
+	if err = db.Order("parent_id, name").Find(&categories).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	var attributes []datastruct.CategoryAttribute
+	if err = db.Order("category_id, id").Find(&attributes).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	attributeMap := make(map[uint64][]datastruct.CategoryAttribute)
+	for _, v := range attributes {
+		attributeMap[v.CategoryID] = append(attributeMap[v.CategoryID], withOptionList(v))
+	}
+
+	children := make(map[uint64][]datastruct.Category)
+	for _, v := range categories {
+		children[v.ParentID] = append(children[v.ParentID], v)
+	}
+
+	var build func(parentID uint64, depth int) []datastruct.CategoryTree
+	build = func(parentID uint64, depth int) []datastruct.CategoryTree {
+		nodes := []datastruct.CategoryTree{}
+		// guard against cycles introduced outside the API
+		if depth > len(categories) {
+			return nodes
+		}
+
+		for _, v := range children[parentID] {
+			nodeAttributes := attributeMap[v.ID]
+			if nodeAttributes == nil {
+				nodeAttributes = []datastruct.CategoryAttribute{}
+			}
+
+			nodes = append(nodes, datastruct.CategoryTree{
+				ID:                   v.ID,
+				Name:                 v.Name,
+				ParentID:             v.ParentID,
+				RecommendationSource: v.RecommendationSource,
+				Attributes:           nodeAttributes,
+				Children:             build(v.ID, depth+1),
+			})
+		}
+
+		return nodes
+	}
+
+	return build(0, 0), http.StatusOK, nil
+}
+
+func CreateCategory(data datastruct.CategoryInput) (res datastruct.Category, statusCode int, err error) {
+	db := Database()
+	currentTime := time.Now()
+
+	if statusCode, err = validateCategoryInput(db, data, 0); err != nil {
+		return
+	}
+
+	res = datastruct.Category{
+		Name:                 strings.TrimSpace(data.Name),
+		ParentID:             data.ParentID,
+		RecommendationSource: data.RecommendationSource,
+		CreatedAt:            currentTime,
+		UpdatedAt:            currentTime,
+	}
+	if err = db.Create(&res).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	return res, http.StatusCreated, nil
+}
+
+func UpdateCategory(categoryID uint64, data datastruct.CategoryInput) (res datastruct.Category, statusCode int, err error) {
+	db := Database()
+
+	if err = db.Where("id = ?", categoryID).First(&res).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res, http.StatusNotFound, errors.New("category not found")
+		}
+		return res, http.StatusInternalServerError, err
+	}
+
+	if statusCode, err = validateCategoryInput(db, data, categoryID); err != nil {
+		return
+	}
+
+	res.Name = strings.TrimSpace(data.Name)
+	res.ParentID = data.ParentID
+	res.RecommendationSource = data.RecommendationSource
+	res.UpdatedAt = time.Now()
+	if err = db.Save(&res).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	return res, http.StatusOK, nil
+}
+
+func DeleteCategory(categoryID uint64) (statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	usages := []struct {
+		table   string
+		column  string
+		message string
+	}{
+		{"categories", "parent_id", "category still has subcategories"},
+		{"products", "category_id", "category is still used by products"},
+		{"brands", "category_id", "category is still used by brands"},
+		{"tags", "category_id", "category is still used by tags"},
+	}
+	for _, v := range usages {
+		var count int64
+		if err = db.Table(v.table).Where(v.column+" = ?", categoryID).Count(&count).Error; err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if count > 0 {
+			return http.StatusConflict, errors.New(v.message)
+		}
+	}
+
+	// Begin a transaction
+	tx := db.Begin()
+
+	// Defer the rollback function in case of error
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	result := tx.Where("id = ?", categoryID).Delete(&datastruct.Category{})
+	if result.Error != nil {
+		tx.Rollback()
+		return http.StatusInternalServerError, result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return http.StatusNotFound, errors.New("category not found")
+	}
+
+	if err = tx.Where("category_id = ?", categoryID).Delete(&datastruct.CategoryAttribute{}).Error; err != nil {
+		tx.Rollback()
+		return http.StatusInternalServerError, err
+	}
+
+	// Commit the transaction if all queries succeed
+	if err = tx.Commit().Error; err != nil {
+		tx.Rollback()
+		log.Println("Error committing transaction:", err)
+		return http.StatusInternalServerError, err
+	}
+
+	return
+}
+
+// GetCategoryAttributes returns the attribute schema of a category, including
+// the attributes inherited from its parents.
+func GetCategoryAttributes(categoryID uint64) (res []datastruct.CategoryAttribute, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	var category datastruct.Category
+	if err = db.Where("id = ?", categoryID).First(&category).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res, http.StatusNotFound, errors.New("category not found")
+		}
+		return res, http.StatusInternalServerError, err
+	}
+
+	if res, err = getEffectiveAttributes(db, categoryID); err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	return
+}
+
+func CreateCategoryAttribute(categoryID uint64, data datastruct.CategoryAttributeInput) (res datastruct.CategoryAttribute, statusCode int, err error) {
+	db := Database()
+	currentTime := time.Now()
+
+	var category datastruct.Category
+	if err = db.Where("id = ?", categoryID).First(&category).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res, http.StatusNotFound, errors.New("category not found")
+		}
+		return res, http.StatusInternalServerError, err
+	}
+
+	if statusCode, err = validateCategoryAttributeInput(db, categoryID, data, 0); err != nil {
+		return
+	}
+
+	res = datastruct.CategoryAttribute{
+		CategoryID: categoryID,
+		CreatedAt:  currentTime,
+	}
+	applyCategoryAttributeInput(&res, data, currentTime)
+	if err = db.Create(&res).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	return withOptionList(res), http.StatusCreated, nil
+}
+
+func UpdateCategoryAttribute(categoryID, attributeID uint64, data datastruct.CategoryAttributeInput) (res datastruct.CategoryAttribute, statusCode int, err error) {
+	db := Database()
+
+	if err = db.Where("id = ? AND category_id = ?", attributeID, categoryID).First(&res).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res, http.StatusNotFound, errors.New("attribute not found")
+		}
 		return res, http.StatusInternalServerError, err
 	}
 
+	if statusCode, err = validateCategoryAttributeInput(db, categoryID, data, attributeID); err != nil {
+		return
+	}
+
+	applyCategoryAttributeInput(&res, data, time.Now())
+	if err = db.Save(&res).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	return withOptionList(res), http.StatusOK, nil
+}
+
+func DeleteCategoryAttribute(categoryID, attributeID uint64) (statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	result := db.Where("id = ? AND category_id = ?", attributeID, categoryID).Delete(&datastruct.CategoryAttribute{})
+	if result.Error != nil {
+		return http.StatusInternalServerError, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return http.StatusNotFound, errors.New("attribute not found")
+	}
+
 	return
 }
 
-func GetListProductByCategory(categoryID uint64) (res []datastruct.HomeProduct, statusCode int, err error) {
+func GetListProductByCategory(categoryID uint64, attributeFilters map[string]string) (res []datastruct.HomeProduct, statusCode int, err error) {
 	db := Database()
 	statusCode = http.StatusOK
 
-	if err := db.Table("products p").
+	categoryIDs, err := getCategoryWithDescendantIDs(db, []uint64{categoryID})
+	if err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	query := db.Table("products p").
 		Select([]string{
 			"p.id",
 			"p.name",
@@ -30,15 +293,312 @@ func GetListProductByCategory(categoryID uint64) (res []datastruct.HomeProduct,
 			"b.name as brand",
 		}).
 		Joins("LEFT JOIN brands b on b.id = p.brand_id").
-		Where("p.merchant_id = 0 AND p.category_id = ?", categoryID).
-		Find(&res).
-		Error; err != nil {
+		Where("p.merchant_id = 0 AND p.category_id IN (?)", categoryIDs)
+
+	for code, value := range attributeFilters {
+		if !attributeCodePattern.MatchString(code) {
+			return res, http.StatusBadRequest, fmt.Errorf("invalid attribute filter: %s", code)
+		}
+		query = query.Where("JSON_UNQUOTE(JSON_EXTRACT(p.attributes, ?)) = ?", "$."+code, value)
+	}
+
+	if err := query.Find(&res).Error; err != nil {
 		return res, http.StatusInternalServerError, err
 	}
 
 	for i, v := range res {
 		res[i].Image = strings.Split(v.Image, ",")[0]
+		res[i].ImageRenditions = GetImageRenditions(res[i].Image)
+	}
+
+	return
+}
+
+// getCategoryIDsByRecommendationSource returns the categories configured with
+// the source together with all of their subcategories.
+func getCategoryIDsByRecommendationSource(db *gorm.DB, source string) (categoryIDs []uint64, err error) {
+	var rootIDs []uint64
+	if err = db.Model(&datastruct.Category{}).
+		Where("recommendation_source = ?", source).
+		Pluck("id", &rootIDs).Error; err != nil {
+		return
+	}
+
+	return getCategoryWithDescendantIDs(db, rootIDs)
+}
+
+func getCategoryWithDescendantIDs(db *gorm.DB, rootIDs []uint64) (categoryIDs []uint64, err error) {
+	var categories []datastruct.Category
+	if err = db.Select("id, parent_id").Find(&categories).Error; err != nil {
+		return
+	}
+
+	children := make(map[uint64][]uint64)
+	for _, v := range categories {
+		children[v.ParentID] = append(children[v.ParentID], v.ID)
+	}
+
+	seen := make(map[uint64]bool)
+	queue := append([]uint64{}, rootIDs...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		categoryIDs = append(categoryIDs, id)
+		queue = append(queue, children[id]...)
+	}
+
+	return
+}
+
+// getCategoryAncestorIDs returns the category followed by its parents up to the root.
+func getCategoryAncestorIDs(db *gorm.DB, categoryID uint64) (categoryIDs []uint64, err error) {
+	seen := make(map[uint64]bool)
+	for categoryID != 0 && !seen[categoryID] {
+		seen[categoryID] = true
+		categoryIDs = append(categoryIDs, categoryID)
+
+		var category datastruct.Category
+		if err = db.Select("id, parent_id").Where("id = ?", categoryID).First(&category).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return categoryIDs, nil
+			}
+			return
+		}
+		categoryID = category.ParentID
+	}
+
+	return
+}
+
+func getEffectiveAttributes(db *gorm.DB, categoryID uint64) (res []datastruct.CategoryAttribute, err error) {
+	categoryIDs, err := getCategoryAncestorIDs(db, categoryID)
+	if err != nil {
+		return
+	}
+
+	res = []datastruct.CategoryAttribute{}
+	if len(categoryIDs) == 0 {
+		return
+	}
+
+	var attributes []datastruct.CategoryAttribute
+	if err = db.Where("category_id IN (?)", categoryIDs).Order("id").Find(&attributes).Error; err != nil {
+		return
+	}
+
+	// attributes defined closer to the category override inherited ones with the same code
+	depth := make(map[uint64]int, len(categoryIDs))
+	for i, id := range categoryIDs {
+		depth[id] = i
+	}
+	byCode := make(map[string]datastruct.CategoryAttribute)
+	var codes []string
+	for _, v := range attributes {
+		current, ok := byCode[v.Code]
+		if !ok {
+			codes = append(codes, v.Code)
+		}
+		if !ok || depth[v.CategoryID] < depth[current.CategoryID] {
+			byCode[v.Code] = v
+		}
+	}
+	for _, code := range codes {
+		res = append(res, withOptionList(byCode[code]))
+	}
+
+	return
+}
+
+// ValidateProductAttributes checks raw product attributes against the category
+// schema and returns them normalized, or nil when there are none.
+func ValidateProductAttributes(db *gorm.DB, categoryID uint64, raw string) (normalized *string, statusCode int, err error) {
+	statusCode = http.StatusOK
+
+	schema, err := getEffectiveAttributes(db, categoryID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	values := make(map[string]interface{})
+	if strings.TrimSpace(raw) != "" {
+		if err = json.Unmarshal([]byte(raw), &values); err != nil {
+			return nil, http.StatusBadRequest, errors.New("attributes must be a JSON object")
+		}
+	}
+
+	known := make(map[string]datastruct.CategoryAttribute, len(schema))
+	for _, v := range schema {
+		known[v.Code] = v
+	}
+
+	for code := range values {
+		if _, ok := known[code]; !ok {
+			return nil, http.StatusBadRequest, fmt.Errorf("attribute %s is not defined for this category", code)
+		}
+	}
+
+	for _, attribute := range schema {
+		value, ok := values[attribute.Code]
+		if !ok || value == nil {
+			if attribute.IsRequired {
+				return nil, http.StatusBadRequest, fmt.Errorf("attribute %s is required", attribute.Code)
+			}
+			delete(values, attribute.Code)
+			continue
+		}
+
+		if err = validateAttributeValue(attribute, value); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+	}
+
+	if len(values) == 0 {
+		return nil, http.StatusOK, nil
+	}
+
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	result := string(encoded)
+
+	return &result, http.StatusOK, nil
+}
+
+// parseProductAttributes decodes stored product attributes for responses.
+func parseProductAttributes(raw *string) map[string]interface{} {
+	attributes := make(map[string]interface{})
+	if raw != nil && *raw != "" {
+		if err := json.Unmarshal([]byte(*raw), &attributes); err != nil {
+			log.Println("Failed to parse product attributes:", err)
+		}
+	}
+
+	return attributes
+}
+
+func validateAttributeValue(attribute datastruct.CategoryAttribute, value interface{}) error {
+	switch attribute.Type {
+	case constant.AttributeTypeNumber:
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("attribute %s must be a number", attribute.Code)
+		}
+	case constant.AttributeTypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("attribute %s must be a boolean", attribute.Code)
+		}
+	case constant.AttributeTypeSelect:
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("attribute %s must be one of %s", attribute.Code, strings.Join(attribute.OptionList, ", "))
+		}
+		for _, option := range attribute.OptionList {
+			if option == text {
+				return nil
+			}
+		}
+		return fmt.Errorf("attribute %s must be one of %s", attribute.Code, strings.Join(attribute.OptionList, ", "))
+	default:
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("attribute %s must be a text", attribute.Code)
+		}
+	}
+
+	return nil
+}
+
+func validateCategoryInput(db *gorm.DB, data datastruct.CategoryInput, categoryID uint64) (statusCode int, err error) {
+	statusCode = http.StatusOK
+
+	if strings.TrimSpace(data.Name) == "" {
+		return http.StatusBadRequest, errors.New("name cannot be empty")
+	}
+
+	switch data.RecommendationSource {
+	case "", constant.RecommendationFaceShape, constant.RecommendationPersonality:
+	default:
+		return http.StatusBadRequest, errors.New("recommendation_source must be empty, face_shape or personality")
+	}
+
+	if data.ParentID == 0 {
+		return
+	}
+
+	if data.ParentID == categoryID {
+		return http.StatusBadRequest, errors.New("category cannot be its own parent")
+	}
+
+	ancestors, err := getCategoryAncestorIDs(db, data.ParentID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if len(ancestors) == 0 {
+		return http.StatusBadRequest, errors.New("parent category not found")
+	}
+	for _, id := range ancestors {
+		if categoryID != 0 && id == categoryID {
+			return http.StatusBadRequest, errors.New("category cannot be moved under its own subcategory")
+		}
+	}
+
+	return
+}
+
+func validateCategoryAttributeInput(db *gorm.DB, categoryID uint64, data datastruct.CategoryAttributeInput, attributeID uint64) (statusCode int, err error) {
+	statusCode = http.StatusOK
+
+	if !attributeCodePattern.MatchString(data.Code) {
+		return http.StatusBadRequest, errors.New("code must be lowercase letters, digits or underscores")
+	}
+
+	switch data.Type {
+	case constant.AttributeTypeText, constant.AttributeTypeNumber, constant.AttributeTypeBoolean:
+	case constant.AttributeTypeSelect:
+		if len(data.Options) == 0 {
+			return http.StatusBadRequest, errors.New("select attributes need options")
+		}
+	default:
+		return http.StatusBadRequest, errors.New("type must be text, number, boolean or select")
+	}
+
+	var duplicate int64
+	if err = db.Model(&datastruct.CategoryAttribute{}).
+		Where("category_id = ? AND code = ? AND id != ?", categoryID, data.Code, attributeID).
+		Count(&duplicate).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if duplicate > 0 {
+		return http.StatusConflict, errors.New("attribute code already exists in this category")
 	}
 
 	return
 }
+
+func applyCategoryAttributeInput(attribute *datastruct.CategoryAttribute, data datastruct.CategoryAttributeInput, currentTime time.Time) {
+	attribute.Code = data.Code
+	attribute.Name = strings.TrimSpace(data.Name)
+	attribute.Type = data.Type
+	attribute.Unit = data.Unit
+	attribute.IsRequired = data.IsRequired
+	attribute.Options = ""
+	if data.Type == constant.AttributeTypeSelect {
+		encoded, _ := json.Marshal(data.Options)
+		attribute.Options = string(encoded)
+	}
+	attribute.UpdatedAt = currentTime
+}
+
+func withOptionList(attribute datastruct.CategoryAttribute) datastruct.CategoryAttribute {
+	attribute.OptionList = []string{}
+	if attribute.Options != "" {
+		if err := json.Unmarshal([]byte(attribute.Options), &attribute.OptionList); err != nil {
+			attribute.OptionList = []string{}
+		}
+	}
+
+	return attribute
+}
@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/yusufwib/arvigo-backend/constant"
+	"github.com/yusufwib/arvigo-backend/datastruct"
+	"github.com/yusufwib/arvigo-backend/utils"
+)
+
+// CompareProducts puts initial and merchant products side by side. Merchant
+// products are priced on their own and share the price range of the initial
+// product they are linked to; differing attributes are listed by json name.
+func CompareProducts(productIDs []uint64) (res datastruct.ProductComparisonResponse, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	productIDs = utils.RemoveDuplicatesUint64(productIDs)
+	if len(productIDs) < 2 {
+		return res, http.StatusBadRequest, errors.New("at least 2 different products are required")
+	}
+
+	var products []datastruct.ProductComparison
+	if err = db.Table("products p").
+		Select([]string{
+			"p.id",
+			"p.name",
+			"p.images",
+			"p.merchant_id",
+			"p.price",
+			"p.attributes",
+			"c.name AS category_name",
+			"b.name AS brand_name",
+		}).
+		Joins("LEFT JOIN categories c ON c.id = p.category_id").
+		Joins("LEFT JOIN brands b ON b.id = p.brand_id").
+		Where("p.id IN (?) AND (p.merchant_id = 0 OR p.status IN (?))", productIDs, []string{constant.StatusApproved, constant.StatusSubscribed}).
+		Find(&products).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	productByID := make(map[uint64]datastruct.ProductComparison, len(products))
+	for _, v := range products {
+		productByID[v.ID] = v
+	}
+	for _, id := range productIDs {
+		if _, ok := productByID[id]; !ok {
+			return res, http.StatusNotFound, fmt.Errorf("product %d not found", id)
+		}
+	}
+
+	var merchantProductIDs []uint64
+	for _, v := range products {
+		if v.MerchantID != 0 {
+			merchantProductIDs = append(merchantProductIDs, v.ID)
+		}
+	}
+
+	var linkedProducts []datastruct.DetailLinkedProduct
+	if len(merchantProductIDs) > 0 {
+		if err = db.Where("merchant_product_id IN (?)", merchantProductIDs).Find(&linkedProducts).Error; err != nil {
+			return res, http.StatusInternalServerError, err
+		}
+	}
+	initialProductByMerchant := make(map[uint64]uint64, len(linkedProducts))
+	for _, v := range linkedProducts {
+		initialProductByMerchant[v.MerchantProductID] = v.InitialProductID
+	}
+
+	initialProductIDs := make([]uint64, 0, len(productIDs))
+	for _, v := range products {
+		if v.MerchantID == 0 {
+			initialProductIDs = append(initialProductIDs, v.ID)
+		} else if initialProductID, ok := initialProductByMerchant[v.ID]; ok {
+			initialProductIDs = append(initialProductIDs, initialProductID)
+		}
+	}
+
+	var priceRanges []datastruct.ComparisonPriceRange
+	if len(initialProductIDs) > 0 {
+		if err = db.Table("detail_linked_products dlp").
+			Select([]string{
+				"dlp.initial_product_id",
+				"MIN(p.price) AS lowest_price",
+				"MAX(p.price) AS highest_price",
+				"COUNT(DISTINCT p.merchant_id) AS merchant_count",
+			}).
+			Joins("JOIN products p ON p.id = dlp.merchant_product_id").
+			Where("dlp.initial_product_id IN (?) AND p.status IN (?)", utils.RemoveDuplicatesUint64(initialProductIDs), []string{constant.StatusApproved, constant.StatusSubscribed}).
+			Group("dlp.initial_product_id").
+			Find(&priceRanges).Error; err != nil {
+			return res, http.StatusInternalServerError, err
+		}
+	}
+	priceRangeByInitial := make(map[uint64]datastruct.ComparisonPriceRange, len(priceRanges))
+	for _, v := range priceRanges {
+		priceRangeByInitial[v.InitialProductID] = v
+	}
+
+	var productTags []datastruct.DetailProductTag
+	if err = db.Where("product_id IN (?)", productIDs).Find(&productTags).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+	tagIDsByProduct := make(map[uint64][]uint64)
+	var allTagIDs []uint64
+	for _, v := range productTags {
+		tagIDsByProduct[v.ProductID] = append(tagIDsByProduct[v.ProductID], v.TagID)
+		allTagIDs = append(allTagIDs, v.TagID)
+	}
+
+	var tags []datastruct.Tag
+	if len(allTagIDs) > 0 {
+		if err = db.Where("id IN (?)", utils.RemoveDuplicatesUint64(allTagIDs)).Find(&tags).Error; err != nil {
+			return res, http.StatusInternalServerError, err
+		}
+	}
+	tagNames := make(map[uint64]string, len(tags))
+	for _, v := range tags {
+		tagNames[v.ID] = v.Name
+	}
+
+	var variants []datastruct.InitialProductVariant
+	if err = db.Table("detail_product_variants").
+		Select([]string{
+			"name",
+			"link_ar",
+			"is_primary_variant",
+			"product_id",
+		}).
+		Where("product_id IN (?)", productIDs).
+		Find(&variants).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+	variantsByProduct := make(map[uint64][]datastruct.InitialProductVariant)
+	for _, v := range variants {
+		variantsByProduct[v.ProductID] = append(variantsByProduct[v.ProductID], v)
+	}
+
+	for _, id := range productIDs {
+		product := productByID[id]
+		product.Image = strings.Split(product.Image, ",")[0]
+		product.Attributes = parseProductAttributes(product.RawAttributes)
+		product.Variants = variantsByProduct[id]
+		product.SuitableFaceShapes = GetSuitableFaceShapes(tagIDsByProduct[id])
+
+		product.Tags = []string{}
+		for _, tagID := range tagIDsByProduct[id] {
+			if name, ok := tagNames[tagID]; ok {
+				product.Tags = append(product.Tags, name)
+			}
+		}
+		sort.Strings(product.Tags)
+
+		product.Type = "initial"
+		product.InitialProductID = id
+		if product.MerchantID != 0 {
+			product.Type = "merchant"
+			product.InitialProductID = initialProductByMerchant[id]
+			price := product.MerchantPrice
+			product.Price = &price
+		}
+		if priceRange, ok := priceRangeByInitial[product.InitialProductID]; ok {
+			product.PriceRange = &priceRange
+		}
+
+		res.Products = append(res.Products, product)
+	}
+
+	res.DifferingAttributes = getDifferingAttributes(res.Products)
+	return
+}
+
+// getDifferingAttributes lists the compared fields whose values are not the
+// same for every product; category attributes are reported as attributes.<code>.
+func getDifferingAttributes(products []datastruct.ProductComparison) []string {
+	fields := map[string]func(p datastruct.ProductComparison) interface{}{
+		"brand_name":           func(p datastruct.ProductComparison) interface{} { return p.BrandName },
+		"category_name":        func(p datastruct.ProductComparison) interface{} { return p.CategoryName },
+		"tags":                 func(p datastruct.ProductComparison) interface{} { return p.Tags },
+		"suitable_face_shapes": func(p datastruct.ProductComparison) interface{} { return p.SuitableFaceShapes },
+		"variants": func(p datastruct.ProductComparison) interface{} {
+			var names []string
+			for _, v := range p.Variants {
+				names = append(names, strings.ToLower(v.Name))
+			}
+			sort.Strings(names)
+			return names
+		},
+		"price": func(p datastruct.ProductComparison) interface{} {
+			if p.Price != nil {
+				return *p.Price
+			}
+			if p.PriceRange != nil {
+				return [2]float64{p.PriceRange.LowestPrice, p.PriceRange.HighestPrice}
+			}
+			return nil
+		},
+	}
+
+	attributeCodes := make(map[string]bool)
+	for _, p := range products {
+		for code := range p.Attributes {
+			attributeCodes[code] = true
+		}
+	}
+	for code := range attributeCodes {
+		code := code
+		fields["attributes."+code] = func(p datastruct.ProductComparison) interface{} { return p.Attributes[code] }
+	}
+
+	differing := []string{}
+	for name, value := range fields {
+		first := value(products[0])
+		for _, p := range products[1:] {
+			if !reflect.DeepEqual(first, value(p)) {
+				differing = append(differing, name)
+				break
+			}
+		}
+	}
+	sort.Strings(differing)
+
+	return differing
+}
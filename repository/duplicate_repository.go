@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yusufwib/arvigo-backend/datastruct"
+	"github.com/yusufwib/arvigo-backend/pkg/imaging"
+	"github.com/yusufwib/arvigo-backend/pkg/storage"
+	"github.com/yusufwib/arvigo-backend/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultDuplicateProductThreshold = 0.8
+	maxDuplicateProducts             = 5
+
+	// near-identical pictures are a duplicate whatever the name says
+	strongImageSimilarity = 0.95
+
+	defaultImageHashBackfillLimit = 100
+	maxImageHashBackfillLimit     = 500
+)
+
+// hashProductImages returns the perceptual hash of each upload keyed by its
+// position; uploads that cannot be decoded are left out and get rejected by
+// the upload itself.
+func hashProductImages(files []*multipart.FileHeader) map[int]uint64 {
+	hashes := make(map[int]uint64, len(files))
+	for i, file := range files {
+		src, err := file.Open()
+		if err != nil {
+			continue
+		}
+		hash, err := imaging.DifferenceHash(src)
+		src.Close()
+		if err != nil {
+			log.Println("Failed to hash product image:", err)
+			continue
+		}
+		hashes[i] = hash
+	}
+
+	return hashes
+}
+
+// findDuplicateProducts looks for products of the same merchant (0 for the
+// initial catalog), brand and category whose name or images resemble the new
+// product. Confidence weighs name similarity 0.6 and image similarity 0.4,
+// except that near-identical images count on their own.
+func findDuplicateProducts(db *gorm.DB, merchantID, brandID, categoryID uint64, name string, hashes map[int]uint64) (res []datastruct.DuplicateProduct, err error) {
+	var candidates []datastruct.DuplicateProduct
+	if err = db.Table("products").
+		Select("id, name, images").
+		Where("merchant_id = ? AND brand_id = ? AND category_id = ?", merchantID, brandID, categoryID).
+		Find(&candidates).Error; err != nil {
+		return
+	}
+	if len(candidates) == 0 {
+		return
+	}
+
+	candidateHashes := make(map[uint64][]uint64)
+	if len(hashes) > 0 {
+		candidateIDs := make([]uint64, 0, len(candidates))
+		for _, v := range candidates {
+			candidateIDs = append(candidateIDs, v.ID)
+		}
+
+		var imageHashes []datastruct.ProductImageHash
+		if err = db.Where("product_id IN (?)", candidateIDs).Find(&imageHashes).Error; err != nil {
+			return
+		}
+		for _, v := range imageHashes {
+			candidateHashes[v.ProductID] = append(candidateHashes[v.ProductID], v.Hash)
+		}
+	}
+
+	threshold := defaultDuplicateProductThreshold
+	if value, parseErr := strconv.ParseFloat(os.Getenv("DUPLICATE_PRODUCT_THRESHOLD"), 64); parseErr == nil && value > 0 {
+		threshold = value
+	}
+
+	for _, candidate := range candidates {
+		candidate.NameSimilarity = utils.NameSimilarity(name, candidate.Name)
+
+		for _, hash := range hashes {
+			for _, candidateHash := range candidateHashes[candidate.ID] {
+				if similarity := imaging.HashSimilarity(hash, candidateHash); similarity > candidate.ImageSimilarity {
+					candidate.ImageSimilarity = similarity
+				}
+			}
+		}
+
+		candidate.Confidence = candidate.NameSimilarity
+		if len(candidateHashes[candidate.ID]) > 0 && len(hashes) > 0 {
+			candidate.Confidence = 0.6*candidate.NameSimilarity + 0.4*candidate.ImageSimilarity
+			if candidate.ImageSimilarity >= strongImageSimilarity && candidate.ImageSimilarity > candidate.Confidence {
+				candidate.Confidence = candidate.ImageSimilarity
+			}
+		}
+		if candidate.Confidence < threshold {
+			continue
+		}
+
+		candidate.Image = strings.Split(candidate.Image, ",")[0]
+		candidate.Confidence = utils.RoundFloat64(candidate.Confidence, 2)
+		candidate.NameSimilarity = utils.RoundFloat64(candidate.NameSimilarity, 2)
+		candidate.ImageSimilarity = utils.RoundFloat64(candidate.ImageSimilarity, 2)
+		res = append(res, candidate)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Confidence > res[j].Confidence
+	})
+	if len(res) > maxDuplicateProducts {
+		res = res[:maxDuplicateProducts]
+	}
+
+	return
+}
+
+// syncProductImageHashes drops the hashes of images the product no longer has
+// and stores the hashes of the newly uploaded ones, hashes being keyed by the
+// position of the upload in newURLs.
+func syncProductImageHashes(tx *gorm.DB, productID uint64, currentURLs, newURLs []string, hashes map[int]uint64, currentTime time.Time) (err error) {
+	query := tx.Where("product_id = ?", productID)
+	if len(currentURLs) > 0 {
+		query = query.Where("image_url NOT IN (?)", currentURLs)
+	}
+	if err = query.Delete(&datastruct.ProductImageHash{}).Error; err != nil {
+		return
+	}
+
+	var payload []datastruct.ProductImageHash
+	for i, url := range newURLs {
+		hash, ok := hashes[i]
+		if !ok {
+			continue
+		}
+		payload = append(payload, datastruct.ProductImageHash{
+			ProductID: productID,
+			ImageURL:  url,
+			Hash:      hash,
+			CreatedAt: currentTime,
+			UpdatedAt: currentTime,
+		})
+	}
+	if len(payload) == 0 {
+		return
+	}
+
+	return tx.Create(&payload).Error
+}
+
+// BackfillProductImageHashes hashes the images of products that have no image
+// hashes yet, such as those created before hashes were stored, so duplicate
+// detection can compare their pictures. Products are handled in id order after
+// afterID; images not on the configured storage are skipped.
+func BackfillProductImageHashes(afterID uint64, limit int) (res datastruct.ProductImageHashBackfillReport, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+	currentTime := time.Now()
+
+	if limit <= 0 || limit > maxImageHashBackfillLimit {
+		limit = defaultImageHashBackfillLimit
+	}
+
+	store, err := storage.Default()
+	if err != nil {
+		return res, http.StatusInternalServerError, fmt.Errorf("storage is not available: %v", err)
+	}
+
+	unhashed := func() *gorm.DB {
+		return db.Table("products p").
+			Where("p.images IS NOT NULL AND p.images <> ''").
+			Where("NOT EXISTS (SELECT 1 FROM product_image_hashes h WHERE h.product_id = p.id)")
+	}
+
+	var products []datastruct.Product
+	if err = unhashed().
+		Select("p.id, p.images").
+		Where("p.id > ?", afterID).
+		Order("p.id ASC").
+		Limit(limit).
+		Find(&products).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	res.LastProductID = afterID
+	for _, product := range products {
+		res.Products++
+		res.LastProductID = product.ID
+
+		var payload []datastruct.ProductImageHash
+		for _, url := range utils.ConvertStringToSlice(product.Images, ",") {
+			url = strings.TrimSpace(url)
+			objectName, ok := store.ObjectName(url)
+			if !ok {
+				res.Skipped++
+				continue
+			}
+
+			data, err := store.Get(context.Background(), objectName)
+			if err != nil {
+				log.Println("Failed to read product image:", err)
+				res.Failed++
+				continue
+			}
+			hash, err := imaging.DifferenceHash(bytes.NewReader(data))
+			if err != nil {
+				log.Println("Failed to hash product image:", err)
+				res.Failed++
+				continue
+			}
+
+			payload = append(payload, datastruct.ProductImageHash{
+				ProductID: product.ID,
+				ImageURL:  url,
+				Hash:      hash,
+				CreatedAt: currentTime,
+				UpdatedAt: currentTime,
+			})
+		}
+		if len(payload) == 0 {
+			continue
+		}
+
+		if err = db.Create(&payload).Error; err != nil {
+			return res, http.StatusInternalServerError, err
+		}
+		res.Hashed += len(payload)
+	}
+
+	if err = unhashed().Where("p.id > ?", res.LastProductID).Count(&res.Remaining).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	return
+}
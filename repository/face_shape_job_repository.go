@@ -0,0 +1,350 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/yusufwib/arvigo-backend/constant"
+	"github.com/yusufwib/arvigo-backend/datastruct"
+	"github.com/yusufwib/arvigo-backend/pkg/mlclient"
+	"github.com/yusufwib/arvigo-backend/pkg/storage"
+	"github.com/yusufwib/arvigo-backend/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultFaceShapeWorkers           = 4
+	defaultFaceShapeJobQueueSize      = 100
+	defaultFaceShapeJobMaxAttempts    = 3
+	defaultFaceShapeJobRetrySeconds   = 10
+	faceShapeJobSweepInterval         = 30 * time.Second
+	faceShapeJobStaleAfter            = 5 * time.Minute
+	faceShapeJobErrorMessageMaxLength = 255
+)
+
+// faceShapeJobQueue carries job ids to the workers. It stays nil until
+// StartFaceShapeWorkers runs; jobs that cannot be queued remain pending and
+// are picked up by the sweeper.
+var faceShapeJobQueue chan uint64
+
+// StartFaceShapeWorkers starts the worker pool that analyses queued face
+// scans. The returned function stops the pool and waits for running jobs.
+func StartFaceShapeWorkers() (stop func()) {
+	workers := utils.StrToInt(os.Getenv("FACE_SHAPE_WORKERS"), defaultFaceShapeWorkers)
+	if workers <= 0 {
+		workers = defaultFaceShapeWorkers
+	}
+	queueSize := utils.StrToInt(os.Getenv("FACE_SHAPE_JOB_QUEUE_SIZE"), defaultFaceShapeJobQueueSize)
+	if queueSize <= 0 {
+		queueSize = defaultFaceShapeJobQueueSize
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	faceShapeJobQueue = make(chan uint64, queueSize)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case jobID := <-faceShapeJobQueue:
+					processFaceShapeJob(jobID)
+				}
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(faceShapeJobSweepInterval)
+		defer ticker.Stop()
+
+		for {
+			sweepFaceShapeJobs()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// SubmitFaceShapeJob stores the uploaded face scan and queues it for analysis.
+func SubmitFaceShapeJob(form *multipart.Form, userID uint64) (res datastruct.FaceShapeJob, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusAccepted
+
+	_, objectName, publicURL, statusCode, err := storeFaceScanImage(form, userID)
+	if err != nil {
+		return
+	}
+
+	currentTime := time.Now()
+	res = datastruct.FaceShapeJob{
+		UserID:     userID,
+		Status:     constant.FaceShapeJobStatusPending,
+		ImageUrl:   publicURL,
+		ObjectName: objectName,
+		CreatedAt:  currentTime,
+		UpdatedAt:  currentTime,
+	}
+	if err = db.Create(&res).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	enqueueFaceShapeJob(res.ID)
+	return res, http.StatusAccepted, nil
+}
+
+func GetFaceShapeJob(userID, jobID uint64) (res datastruct.FaceShapeJob, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	if err = db.Where("id = ? AND user_id = ?", jobID, userID).First(&res).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res, http.StatusNotFound, errors.New("face shape job not found")
+		}
+		return res, http.StatusInternalServerError, err
+	}
+
+	return
+}
+
+func enqueueFaceShapeJob(jobID uint64) bool {
+	select {
+	case faceShapeJobQueue <- jobID:
+		return true
+	default:
+		return false
+	}
+}
+
+// sweepFaceShapeJobs requeues jobs left behind by a full queue, a retry
+// backoff or a worker that died mid-job.
+func sweepFaceShapeJobs() {
+	db := Database()
+	currentTime := time.Now()
+
+	if err := db.Model(&datastruct.FaceShapeJob{}).
+		Where("status = ? AND updated_at < ?", constant.FaceShapeJobStatusProcessing, currentTime.Add(-faceShapeJobStaleAfter)).
+		Updates(map[string]interface{}{
+			"status":     constant.FaceShapeJobStatusPending,
+			"updated_at": currentTime,
+		}).Error; err != nil {
+		log.Println("Failed to reset stale face shape jobs:", err)
+		return
+	}
+
+	var jobIDs []uint64
+	if err := db.Model(&datastruct.FaceShapeJob{}).
+		Where("status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)", constant.FaceShapeJobStatusPending, currentTime).
+		Order("id ASC").
+		Limit(cap(faceShapeJobQueue)).
+		Pluck("id", &jobIDs).Error; err != nil {
+		log.Println("Failed to load pending face shape jobs:", err)
+		return
+	}
+
+	for _, jobID := range jobIDs {
+		if !enqueueFaceShapeJob(jobID) {
+			return
+		}
+	}
+}
+
+// processFaceShapeJob claims a pending job and runs the analysis. Transient
+// failures send the job back to pending with a backoff until its attempts
+// run out.
+func processFaceShapeJob(jobID uint64) {
+	db := Database()
+	currentTime := time.Now()
+
+	// the conditional update makes sure only one worker claims the job
+	result := db.Model(&datastruct.FaceShapeJob{}).
+		Where("id = ? AND status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)", jobID, constant.FaceShapeJobStatusPending, currentTime).
+		Updates(map[string]interface{}{
+			"status":     constant.FaceShapeJobStatusProcessing,
+			"attempts":   gorm.Expr("attempts + 1"),
+			"started_at": currentTime,
+			"updated_at": currentTime,
+		})
+	if result.Error != nil {
+		log.Printf("Failed to claim face shape job %d: %v", jobID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	var job datastruct.FaceShapeJob
+	if err := db.First(&job, jobID).Error; err != nil {
+		log.Printf("Failed to load face shape job %d: %v", jobID, err)
+		return
+	}
+
+	scan, err := runFaceShapeJob(job)
+	if err == nil {
+		completeFaceShapeJob(job, scan)
+		return
+	}
+
+	maxAttempts := utils.StrToInt(os.Getenv("FACE_SHAPE_JOB_MAX_ATTEMPTS"), defaultFaceShapeJobMaxAttempts)
+	if isRetryableFaceShapeError(err) && job.Attempts < maxAttempts {
+		retryFaceShapeJob(job, err)
+		return
+	}
+
+	failFaceShapeJob(job, err)
+}
+
+func runFaceShapeJob(job datastruct.FaceShapeJob) (scan datastruct.FaceScan, err error) {
+	store, err := storage.Default()
+	if err != nil {
+		return scan, fmt.Errorf("storage is not available: %v", err)
+	}
+
+	imageData, err := store.Get(context.Background(), job.ObjectName)
+	if err != nil {
+		return scan, err
+	}
+
+	scan, _, err = analyzeFaceShape(job.UserID, job.ImageUrl, imageData)
+	return
+}
+
+// isRetryableFaceShapeError treats ML outages and storage read failures as
+// transient; a rejected or unrecognised face is final.
+func isRetryableFaceShapeError(err error) bool {
+	var rejection *faceScanRejection
+	if errors.As(err, &rejection) {
+		return false
+	}
+
+	var mlErr *mlclient.Error
+	if errors.As(err, &mlErr) {
+		return mlclient.Retryable(err)
+	}
+
+	return true
+}
+
+func retryFaceShapeJob(job datastruct.FaceShapeJob, cause error) {
+	db := Database()
+	currentTime := time.Now()
+
+	retrySeconds := utils.StrToInt(os.Getenv("FACE_SHAPE_JOB_RETRY_SECONDS"), defaultFaceShapeJobRetrySeconds)
+	nextAttemptAt := currentTime.Add(time.Duration(retrySeconds<<uint(job.Attempts-1)) * time.Second)
+
+	if err := db.Model(&datastruct.FaceShapeJob{}).
+		Where("id = ?", job.ID).
+		Updates(map[string]interface{}{
+			"status":          constant.FaceShapeJobStatusPending,
+			"error_message":   faceShapeJobErrorMessage(cause),
+			"next_attempt_at": nextAttemptAt,
+			"updated_at":      currentTime,
+		}).Error; err != nil {
+		log.Printf("Failed to reschedule face shape job %d: %v", job.ID, err)
+	}
+}
+
+func completeFaceShapeJob(job datastruct.FaceShapeJob, scan datastruct.FaceScan) {
+	db := Database()
+	currentTime := time.Now()
+
+	notification := datastruct.Notification{
+		UserID:      job.UserID,
+		Type:        constant.NotificationTypeFaceShapeResult,
+		Title:       "Face shape ready",
+		Body:        fmt.Sprintf("Your face shape is %s", scan.FaceShape),
+		ReferenceID: job.ID,
+		CreatedAt:   currentTime,
+		UpdatedAt:   currentTime,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&datastruct.FaceShapeJob{}).
+			Where("id = ?", job.ID).
+			Updates(map[string]interface{}{
+				"status":        constant.FaceShapeJobStatusCompleted,
+				"result":        scan.FaceShape,
+				"face_shape_id": scan.FaceShapeID,
+				"face_scan_id":  scan.ID,
+				"error_message": nil,
+				"completed_at":  currentTime,
+				"updated_at":    currentTime,
+			}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&notification).Error
+	})
+	if err != nil {
+		log.Printf("Failed to complete face shape job %d: %v", job.ID, err)
+	}
+}
+
+func failFaceShapeJob(job datastruct.FaceShapeJob, cause error) {
+	db := Database()
+	currentTime := time.Now()
+
+	body := "We could not analyse your face scan, please try again"
+	var rejection *faceScanRejection
+	if errors.As(cause, &rejection) {
+		body = "Please retake your face scan: " + rejection.message
+	}
+
+	notification := datastruct.Notification{
+		UserID:      job.UserID,
+		Type:        constant.NotificationTypeFaceShapeResult,
+		Title:       "Face shape scan failed",
+		Body:        body,
+		ReferenceID: job.ID,
+		CreatedAt:   currentTime,
+		UpdatedAt:   currentTime,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&datastruct.FaceShapeJob{}).
+			Where("id = ?", job.ID).
+			Updates(map[string]interface{}{
+				"status":        constant.FaceShapeJobStatusFailed,
+				"error_message": faceShapeJobErrorMessage(cause),
+				"completed_at":  currentTime,
+				"updated_at":    currentTime,
+			}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&notification).Error
+	})
+	if err != nil {
+		log.Printf("Failed to mark face shape job %d as failed: %v", job.ID, err)
+	}
+}
+
+func faceShapeJobErrorMessage(err error) string {
+	message := err.Error()
+	if len(message) > faceShapeJobErrorMessageMaxLength {
+		message = message[:faceShapeJobErrorMessageMaxLength]
+	}
+
+	return message
+}
@@ -1,7 +1,8 @@
 package repository
 
 import (
-	"encoding/json"
+	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
@@ -9,111 +10,348 @@ import (
 	"mime/multipart"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/yusufwib/arvigo-backend/constant"
 	"github.com/yusufwib/arvigo-backend/datastruct"
-	"github.com/yusufwib/arvigo-backend/pkg/storage"
+	"github.com/yusufwib/arvigo-backend/pkg/imaging"
+	"github.com/yusufwib/arvigo-backend/pkg/mlclient"
 	"github.com/yusufwib/arvigo-backend/utils"
+	"gorm.io/gorm"
 )
 
+const (
+	defaultFaceScanMinDimension  = 224
+	defaultFaceScanMinBrightness = 40
+	defaultFaceScanMaxBrightness = 220
+	defaultFaceScanMinSharpness  = 30
+
+	faceScanCheckIsHuman    = "is_human"
+	faceScanCheckSingleFace = "single_face"
+)
+
+// faceScanRejection is a face scan the user should retake; its message is
+// shown to them as is.
+type faceScanRejection struct {
+	message string
+}
+
+func (e *faceScanRejection) Error() string {
+	return e.message
+}
+
+var errNotHuman = &faceScanRejection{"no face detected, make sure your face is clearly visible"}
+
+var faceScanMimeTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+}
+
 func FaceShapeRecognition(form *multipart.Form, userID uint64) (res datastruct.FaceShapeResponse, statusCode int, err error) {
-	db := Database()
+	statusCode = http.StatusOK
+
+	imageData, _, publicURL, statusCode, err := storeFaceScanImage(form, userID)
+	if err != nil {
+		return
+	}
+	res.ImageUrl = publicURL
+
+	scan, statusCode, err := analyzeFaceShape(userID, publicURL, imageData)
+	if err != nil {
+		return
+	}
+
+	res.ScanID = scan.ID
+	res.Result = scan.FaceShape
+	return
+}
+
+// storeFaceScanImage reads the "image" form file and uploads it as a face scan asset.
+func storeFaceScanImage(form *multipart.Form, userID uint64) (imageData []byte, objectName, publicURL string, statusCode int, err error) {
+	statusCode = http.StatusOK
+
 	fileHeaders := form.File["image"]
 	if len(fileHeaders) == 0 {
-		return res, http.StatusBadRequest, errors.New("failed to get image from form data")
+		return imageData, objectName, publicURL, http.StatusBadRequest, errors.New("failed to get image from form data")
 	}
 
 	fileHeader := fileHeaders[0]
 	file, err := fileHeader.Open()
 	if err != nil {
-		return res, http.StatusInternalServerError, errors.New("failed to open uploaded image")
+		return imageData, objectName, publicURL, http.StatusInternalServerError, errors.New("failed to open uploaded image")
 	}
 	defer file.Close()
 
-	// Create a temporary file to save the uploaded image
-	tempDir := "./public/temp"
-	tempFile, err := ioutil.TempFile(tempDir, "uploaded_image_*.jpg")
-	if err != nil {
-		return res, http.StatusInternalServerError, errors.New("failed to create temporary file")
+	maxSize := int64(utils.StrToInt(os.Getenv("FACE_SCAN_MAX_SIZE_MB"), 0)) * 1024 * 1024
+	if maxSize <= 0 {
+		maxSize = imaging.MaxUploadSize()
 	}
-	defer func() {
-		tempFile.Close()
-		os.Remove(tempFile.Name())
-	}()
-
-	// Save the uploaded image to the temporary file
-	_, err = io.Copy(tempFile, file)
+	imageData, err = ioutil.ReadAll(io.LimitReader(file, maxSize+1))
 	if err != nil {
-		return res, http.StatusInternalServerError, errors.New("failed to save uploaded image")
+		return imageData, objectName, publicURL, http.StatusInternalServerError, errors.New("failed to save uploaded image")
+	}
+	if int64(len(imageData)) > maxSize {
+		return imageData, objectName, publicURL, http.StatusBadRequest, fmt.Errorf("photo is too large, use a photo under %d MB", maxSize/1024/1024)
 	}
 
-	// Get the path of the uploaded image
-	imagePath := tempFile.Name()
-	encodedImg, err := utils.ImageToBase64(imagePath)
-	if err != nil {
-		return res, http.StatusInternalServerError, fmt.Errorf("failed to converting img to base64")
+	if err = validateFaceScanImage(imageData); err != nil {
+		return imageData, objectName, publicURL, http.StatusBadRequest, err
 	}
 
-	objectName := fileHeader.Filename
-	publicURL, err := storage.UploadImageToGCS(objectName, imagePath, os.Getenv("STORAGE_BUCKET_IMAGE_FOLDER"))
+	objectName = fmt.Sprintf("%s/%s_%s", os.Getenv("STORAGE_BUCKET_IMAGE_FOLDER"), utils.GenerateRandomStringWithTimestamp(10), fileHeader.Filename)
+	publicURL, err = UploadObject(constant.AssetKindFaceScan, userID, objectName, imageData, http.DetectContentType(imageData))
 	if err != nil {
-		return res, http.StatusInternalServerError, fmt.Errorf("failed to upload image: %v", err)
+		return imageData, objectName, publicURL, http.StatusInternalServerError, err
 	}
-	res.ImageUrl = publicURL
 
+	return
+}
+
+// analyzeFaceShape asks the ML service for the face shape and records it as
+// the user's active scan.
+func analyzeFaceShape(userID uint64, imageURL string, imageData []byte) (res datastruct.FaceScan, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	// a cached result means this user's photo already passed the ML checks
 	var faceTestRes datastruct.FaceTestRes
-	// response, err := utils.FetchMachineLearningAPI("POST", "/is_human", datastruct.FaceShapeMachineLearningPayload{
-	// 	Image: encodedImg,
-	// })
-
-	// if err != nil {
-	// 	statusCode = http.StatusInternalServerError
-	// 	return
-	// }
-
-	// err = json.Unmarshal(response, &isHumanRes)
-	// if err != nil {
-	// 	statusCode = http.StatusInternalServerError
-	// 	return
-	// }
-
-	// if !isHumanRes.Result {
-	// 	statusCode = http.StatusBadRequest
-	// 	err = errors.New("is not human")
-	// 	return
-	// }
-	responseFaceShape, err := utils.FetchMachineLearningAPI("POST", "/face_shape", datastruct.FaceShapeMachineLearningPayload{
-		Image: encodedImg,
-	})
+	fingerprint := imageFingerprint(userID, imageData)
+	if !getCachedMLResult(mlclient.EndpointFaceShape, fingerprint, &faceTestRes) {
+		encodedImg := base64.StdEncoding.EncodeToString(imageData)
 
-	if err != nil {
-		statusCode = http.StatusInternalServerError
-		return
+		if err = checkFaceScanWithML(encodedImg); err != nil {
+			var rejection *faceScanRejection
+			if errors.As(err, &rejection) {
+				return res, http.StatusBadRequest, err
+			}
+			return res, mlclient.StatusCode(err), err
+		}
+
+		faceTestRes, err = mlclient.Default().FaceShape(context.Background(), encodedImg)
+		if err != nil {
+			statusCode = mlclient.StatusCode(err)
+			return
+		}
+		if faceTestRes.Shape != "" {
+			cacheMLResult(mlclient.EndpointFaceShape, fingerprint, faceTestRes.ModelVersion, faceTestRes)
+		}
 	}
 
-	err = json.Unmarshal(responseFaceShape, &faceTestRes)
+	if faceTestRes.Shape == "" {
+		return res, http.StatusBadRequest, errNotHuman
+	}
+	faceShapeID, err := GetFaceShapeIDByName(faceTestRes.Shape)
 	if err != nil {
-		statusCode = http.StatusInternalServerError
-		return
+		return res, http.StatusInternalServerError, err
+	}
+	if faceShapeID == 0 {
+		return res, http.StatusInternalServerError, fmt.Errorf("unknown face shape: %s", faceTestRes.Shape)
 	}
 
-	if faceTestRes.Shape == "" {
-		statusCode = http.StatusBadRequest
-		err = errors.New("is not human")
-		return
+	currentTime := time.Now()
+	res = datastruct.FaceScan{
+		UserID:      userID,
+		FaceShapeID: faceShapeID,
+		FaceShape:   faceTestRes.Shape,
+		Confidence:  faceTestRes.Confidence,
+		IsActive:    true,
+		CreatedAt:   currentTime,
+		UpdatedAt:   currentTime,
+	}
+	if imageURL != "" {
+		res.ImageUrl = &imageURL
+	}
+	if faceTestRes.ModelVersion != "" {
+		res.ModelVersion = &faceTestRes.ModelVersion
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&datastruct.FaceScan{}).
+			Where("user_id = ? AND is_active = 1", userID).
+			Updates(map[string]interface{}{
+				"is_active":  0,
+				"updated_at": currentTime,
+			}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(&res).Error; err != nil {
+			return err
+		}
+
+		return setUserFaceShape(tx, userID, faceShapeID, currentTime)
+	})
+	if err != nil {
+		return res, http.StatusInternalServerError, err
 	}
-	faceShapeID := constant.GetIDByShape[faceTestRes.Shape]
-	if err = db.Model(&datastruct.User{}).
+
+	return
+}
+
+// setUserFaceShape mirrors the active scan onto the user row read by the rest of the app.
+func setUserFaceShape(tx *gorm.DB, userID, faceShapeID uint64, currentTime time.Time) error {
+	return tx.Model(&datastruct.User{}).
 		Where("id = ?", userID).
 		Updates(map[string]interface{}{
 			"face_shape_id":         faceShapeID,
 			"is_complete_face_test": 1,
-			"updated_at":            time.Now(),
-		}).Error; err != nil {
+			"updated_at":            currentTime,
+		}).Error
+}
+
+func GetFaceScans(userID uint64) (res []datastruct.FaceScan, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	if err = db.Table("face_scans fs").
+		Select([]string{
+			"fs.*",
+			"f.name as face_shape",
+		}).
+		Joins("LEFT JOIN face_shapes f on f.id = fs.face_shape_id").
+		Where("fs.user_id = ?", userID).
+		Order("fs.created_at DESC, fs.id DESC").
+		Find(&res).Error; err != nil {
 		return res, http.StatusInternalServerError, err
 	}
 
-	res.Result = faceTestRes.Shape
 	return
 }
+
+// ActivateFaceScan makes a past scan the one recommendations are based on.
+func ActivateFaceScan(userID, scanID uint64) (res datastruct.FaceScan, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	if err = db.Table("face_scans fs").
+		Select([]string{
+			"fs.*",
+			"f.name as face_shape",
+		}).
+		Joins("LEFT JOIN face_shapes f on f.id = fs.face_shape_id").
+		Where("fs.id = ? AND fs.user_id = ?", scanID, userID).
+		First(&res).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res, http.StatusNotFound, errors.New("face scan not found")
+		}
+		return res, http.StatusInternalServerError, err
+	}
+	if res.IsActive {
+		return
+	}
+
+	currentTime := time.Now()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&datastruct.FaceScan{}).
+			Where("user_id = ? AND is_active = 1", userID).
+			Updates(map[string]interface{}{
+				"is_active":  0,
+				"updated_at": currentTime,
+			}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&datastruct.FaceScan{}).
+			Where("id = ?", scanID).
+			Updates(map[string]interface{}{
+				"is_active":  1,
+				"updated_at": currentTime,
+			}).Error; err != nil {
+			return err
+		}
+
+		return setUserFaceShape(tx, userID, res.FaceShapeID, currentTime)
+	})
+	if err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	res.IsActive = true
+	res.UpdatedAt = currentTime
+	return
+}
+
+// getActiveFaceShapeID returns the face shape of the user's active scan,
+// falling back to the user row for accounts without scan history.
+func getActiveFaceShapeID(db *gorm.DB, userID, fallback uint64) (faceShapeID uint64, err error) {
+	var faceShapeIDs []uint64
+	if err = db.Model(&datastruct.FaceScan{}).
+		Where("user_id = ? AND is_active = 1", userID).
+		Order("id DESC").
+		Limit(1).
+		Pluck("face_shape_id", &faceShapeIDs).Error; err != nil {
+		return
+	}
+	if len(faceShapeIDs) == 0 {
+		return fallback, nil
+	}
+
+	return faceShapeIDs[0], nil
+}
+
+// validateFaceScanImage runs the local checks on a face scan before it is
+// stored: format, dimensions, brightness and blur. A threshold set to 0
+// disables its check.
+func validateFaceScanImage(imageData []byte) error {
+	quality, err := imaging.Analyze(imageData)
+	if err != nil || !faceScanMimeTypes[quality.MimeType] {
+		return &faceScanRejection{"photo must be a JPEG or PNG image"}
+	}
+
+	minDimension := utils.StrToInt(os.Getenv("FACE_SCAN_MIN_DIMENSION"), defaultFaceScanMinDimension)
+	if quality.Width < minDimension || quality.Height < minDimension {
+		return &faceScanRejection{fmt.Sprintf("photo is too small, use a photo of at least %dx%d pixels", minDimension, minDimension)}
+	}
+
+	minBrightness := utils.StrToInt(os.Getenv("FACE_SCAN_MIN_BRIGHTNESS"), defaultFaceScanMinBrightness)
+	if quality.Brightness < float64(minBrightness) {
+		return &faceScanRejection{"face too dark, move somewhere brighter"}
+	}
+
+	maxBrightness := utils.StrToInt(os.Getenv("FACE_SCAN_MAX_BRIGHTNESS"), defaultFaceScanMaxBrightness)
+	if maxBrightness > 0 && quality.Brightness > float64(maxBrightness) {
+		return &faceScanRejection{"photo too bright, avoid direct light on your face"}
+	}
+
+	minSharpness := utils.StrToInt(os.Getenv("FACE_SCAN_MIN_SHARPNESS"), defaultFaceScanMinSharpness)
+	if quality.Sharpness < float64(minSharpness) {
+		return &faceScanRejection{"photo is blurry, hold the camera steady"}
+	}
+
+	return nil
+}
+
+// checkFaceScanWithML runs the ML checks listed in FACE_SCAN_ML_CHECKS
+// (comma separated: is_human, single_face) before the face shape call.
+func checkFaceScanWithML(encodedImg string) error {
+	checks := map[string]bool{}
+	for _, v := range strings.Split(os.Getenv("FACE_SCAN_ML_CHECKS"), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			checks[v] = true
+		}
+	}
+	if !checks[faceScanCheckIsHuman] && !checks[faceScanCheckSingleFace] {
+		return nil
+	}
+
+	isHumanRes, err := mlclient.Default().IsHuman(context.Background(), encodedImg)
+	if err != nil {
+		return err
+	}
+
+	if checks[faceScanCheckIsHuman] && !isHumanRes.Result {
+		return errNotHuman
+	}
+	// older models do not report a face count, so the check only applies when they do
+	if checks[faceScanCheckSingleFace] && isHumanRes.FaceCount != nil {
+		if *isHumanRes.FaceCount == 0 {
+			return errNotHuman
+		}
+		if *isHumanRes.FaceCount > 1 {
+			return &faceScanRejection{"more than one face detected, make sure only you are in the photo"}
+		}
+	}
+
+	return nil
+}
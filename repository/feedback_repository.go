@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yusufwib/arvigo-backend/datastruct"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SubmitFaceScanFeedback confirms or corrects the shape detected by one of the
+// user's scans. Sending feedback again replaces the previous label.
+func SubmitFaceScanFeedback(userID, scanID uint64, data datastruct.FaceScanFeedbackInput) (res datastruct.FaceScanFeedback, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	var scan datastruct.FaceScan
+	if err = db.Where("id = ? AND user_id = ?", scanID, userID).First(&scan).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res, http.StatusNotFound, errors.New("face scan not found")
+		}
+		return res, http.StatusInternalServerError, err
+	}
+
+	isCorrect := *data.IsCorrect
+	var correctedFaceShapeID *uint64
+	if !isCorrect {
+		if strings.TrimSpace(data.FaceShape) == "" {
+			return res, http.StatusBadRequest, errors.New("face_shape is required when the result is not correct")
+		}
+
+		faceShapeID, err := GetFaceShapeIDByName(data.FaceShape)
+		if err != nil {
+			return res, http.StatusInternalServerError, err
+		}
+		if faceShapeID == 0 {
+			return res, http.StatusBadRequest, errors.New("unknown face shape")
+		}
+
+		// naming the detected shape is a confirmation
+		if faceShapeID == scan.FaceShapeID {
+			isCorrect = true
+		} else {
+			correctedFaceShapeID = &faceShapeID
+		}
+	}
+
+	currentTime := time.Now()
+	res = datastruct.FaceScanFeedback{
+		FaceScanID:           scanID,
+		UserID:               userID,
+		IsCorrect:            isCorrect,
+		CorrectedFaceShapeID: correctedFaceShapeID,
+		CreatedAt:            currentTime,
+		UpdatedAt:            currentTime,
+	}
+	if comment := strings.TrimSpace(data.Comment); comment != "" {
+		res.Comment = &comment
+	}
+
+	if err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "face_scan_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"is_correct", "corrected_face_shape_id", "comment", "updated_at"}),
+	}).Create(&res).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	return
+}
+
+// SubmitPersonalityFeedback rates the user's latest questionnaire result.
+// Rating again replaces the previous rating.
+func SubmitPersonalityFeedback(userID uint64, data datastruct.PersonalityFeedbackInput) (res datastruct.PersonalityFeedback, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	var personality datastruct.UserPersonality
+	if err = db.Where("user_id = ? AND is_active = 1", userID).
+		Order("id DESC").
+		First(&personality).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res, http.StatusNotFound, errors.New("personality result not found")
+		}
+		return res, http.StatusInternalServerError, err
+	}
+
+	currentTime := time.Now()
+	res = datastruct.PersonalityFeedback{
+		UserPersonalityID: personality.ID,
+		UserID:            userID,
+		Rating:            data.Rating,
+		CreatedAt:         currentTime,
+		UpdatedAt:         currentTime,
+	}
+	if comment := strings.TrimSpace(data.Comment); comment != "" {
+		res.Comment = &comment
+	}
+
+	if err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_personality_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rating", "comment", "updated_at"}),
+	}).Create(&res).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	return
+}
+
+// GetFaceShapeTrainingSamples returns every labelled face scan with its image,
+// the predicted and the user-confirmed shape, optionally only those labelled
+// since the given time.
+func GetFaceShapeTrainingSamples(since *time.Time) (res []datastruct.FaceShapeTrainingSample, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	query := db.Table("face_scan_feedbacks fb").
+		Select([]string{
+			"fs.id as face_scan_id",
+			"fs.image_url",
+			"pf.name as predicted_shape",
+			"COALESCE(cf.name, pf.name) as label_shape",
+			"fb.is_correct",
+			"fs.confidence",
+			"fs.model_version",
+			"fb.comment",
+			"fs.created_at as scanned_at",
+			"fb.updated_at as labelled_at",
+		}).
+		Joins("JOIN face_scans fs on fs.id = fb.face_scan_id").
+		Joins("LEFT JOIN face_shapes pf on pf.id = fs.face_shape_id").
+		Joins("LEFT JOIN face_shapes cf on cf.id = fb.corrected_face_shape_id").
+		Where("fs.image_url IS NOT NULL")
+	if since != nil {
+		query = query.Where("fb.updated_at >= ?", *since)
+	}
+
+	if err = query.Order("fb.id ASC").Find(&res).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	return
+}
+
+// GetPersonalityTrainingSamples returns every rated questionnaire result with
+// its answers and predicted percentages, optionally only those rated since
+// the given time.
+func GetPersonalityTrainingSamples(since *time.Time) (res []datastruct.PersonalityTrainingSample, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	query := db.Model(&datastruct.PersonalityFeedback{})
+	if since != nil {
+		query = query.Where("updated_at >= ?", *since)
+	}
+
+	var feedbacks []datastruct.PersonalityFeedback
+	if err = query.Order("id ASC").Find(&feedbacks).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+	if len(feedbacks) == 0 {
+		return
+	}
+
+	personalityIDs := make([]uint64, 0, len(feedbacks))
+	for _, v := range feedbacks {
+		personalityIDs = append(personalityIDs, v.UserPersonalityID)
+	}
+
+	var personalities []datastruct.UserPersonality
+	if err = db.Where("id IN (?)", personalityIDs).Find(&personalities).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	personalityByID := make(map[uint64]datastruct.UserPersonality, len(personalities))
+	for _, v := range personalities {
+		personalityByID[v.ID] = v
+	}
+
+	answers, err := getPersonalityAnswers(db, personalityIDs)
+	if err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	for _, v := range feedbacks {
+		personality, ok := personalityByID[v.UserPersonalityID]
+		if !ok {
+			continue
+		}
+
+		res = append(res, datastruct.PersonalityTrainingSample{
+			UserPersonalityID:      personality.ID,
+			QuestionnaireVersionID: personality.QuestionnaireVersionID,
+			Answers:                answers[personality.ID],
+			Predicted: datastruct.PersonalityPercentages{
+				Agreeable:     personality.AgrResult,
+				Conscientious: personality.CsnResult,
+				Extraversion:  personality.ExtResult,
+				Neurotic:      personality.EstResult,
+				Openness:      personality.OpnResult,
+			},
+			Rating:       v.Rating,
+			Comment:      v.Comment,
+			ModelVersion: personality.ModelVersion,
+			Source:       personality.Source,
+			AnsweredAt:   personality.CreatedAt,
+			LabelledAt:   v.UpdatedAt,
+		})
+	}
+
+	return
+}
+
+// getPersonalityAnswers loads the answers of questionnaire results, keyed by
+// result and then by question type, e.g. EXT1.
+func getPersonalityAnswers(db *gorm.DB, personalityIDs []uint64) (res map[uint64]map[string]int, err error) {
+	var rows []struct {
+		UserPersonalityID uint64
+		Type              string
+		Answer            int
+	}
+	if err = db.Table("user_personality_answers upa").
+		Select("upa.user_personality_id, pq.type, upa.answer").
+		Joins("JOIN personality_questionnaires pq on pq.id = upa.questionnaire_id").
+		Where("upa.user_personality_id IN (?)", personalityIDs).
+		Find(&rows).Error; err != nil {
+		return
+	}
+
+	res = make(map[uint64]map[string]int, len(personalityIDs))
+	for _, v := range rows {
+		if res[v.UserPersonalityID] == nil {
+			res[v.UserPersonalityID] = map[string]int{}
+		}
+		res[v.UserPersonalityID][v.Type] = v.Answer
+	}
+
+	return
+}
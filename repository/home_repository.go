@@ -1,14 +1,14 @@
 package repository
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
-	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/yusufwib/arvigo-backend/constant"
 	"github.com/yusufwib/arvigo-backend/datastruct"
+	"github.com/yusufwib/arvigo-backend/pkg/mlclient"
 	"github.com/yusufwib/arvigo-backend/utils"
 )
 
@@ -44,6 +44,21 @@ func GetHome(userID uint64) (res datastruct.HomeResponse, statusCode int, err er
 
 	// faceshape
 	if user.IsCompleteFaceTest {
+		faceShapeID, err := getActiveFaceShapeID(db, user.ID, user.FaceShapeID)
+		if err != nil {
+			return res, http.StatusInternalServerError, err
+		}
+
+		faceShapeTagIDs, err := GetFaceShapeTagIDs(faceShapeID)
+		if err != nil {
+			return res, http.StatusInternalServerError, err
+		}
+
+		categoryIDs, err := getCategoryIDsByRecommendationSource(db, constant.RecommendationFaceShape)
+		if err != nil {
+			return res, http.StatusInternalServerError, err
+		}
+
 		if err := db.Table("products p").
 			Select([]string{
 				"p.id",
@@ -53,7 +68,7 @@ func GetHome(userID uint64) (res datastruct.HomeResponse, statusCode int, err er
 			}).
 			Joins("LEFT JOIN brands b on b.id = p.brand_id").
 			Joins("LEFT JOIN detail_product_tags dpt on p.id = dpt.product_id").
-			Where("p.merchant_id = 0 AND p.category_id = ? AND dpt.tag_id IN (?)", constant.GlassesCategoryID, constant.GetFaceShapeTags[user.FaceShapeID]).
+			Where("p.merchant_id = 0 AND p.category_id IN (?) AND dpt.tag_id IN (?)", categoryIDs, faceShapeTagIDs).
 			Find(&faceShapeProduct).
 			Error; err != nil {
 			return res, http.StatusInternalServerError, err
@@ -61,8 +76,8 @@ func GetHome(userID uint64) (res datastruct.HomeResponse, statusCode int, err er
 
 		for i, v := range faceShapeProduct {
 			faceShapeProduct[i].Image = strings.Split(v.Image, ",")[0]
+			faceShapeProduct[i].ImageRenditions = GetImageRenditions(faceShapeProduct[i].Image)
 			var tagIDs []uint64
-			var tags []string
 			if err := db.Table("detail_product_tags").
 				Select([]string{
 					"tag_id",
@@ -73,14 +88,16 @@ func GetHome(userID uint64) (res datastruct.HomeResponse, statusCode int, err er
 				return res, http.StatusInternalServerError, err
 			}
 
-			for _, v := range tagIDs {
-				tags = append(tags, constant.GetTagNameByDetailTag[v]...)
-			}
-			faceShapeProduct[i].Tags = utils.RemoveDuplicates(tags)
+			faceShapeProduct[i].Tags = GetTagLabels(tagIDs)
 		}
 	}
 
 	if user.IsCompletePersonalityTest {
+		categoryIDs, err := getCategoryIDsByRecommendationSource(db, constant.RecommendationPersonality)
+		if err != nil {
+			return res, http.StatusInternalServerError, err
+		}
+
 		if err := db.Table("products p").
 			Select([]string{
 				"p.id",
@@ -90,7 +107,7 @@ func GetHome(userID uint64) (res datastruct.HomeResponse, statusCode int, err er
 			}).
 			Joins("LEFT JOIN brands b on b.id = p.brand_id").
 			Joins("LEFT JOIN detail_product_tags dpt on p.id = dpt.product_id").
-			Where("p.merchant_id = 0 AND p.category_id = ? AND dpt.tag_id IN (?)", constant.MakeupCategoryID, user.TagIDs).
+			Where("p.merchant_id = 0 AND p.category_id IN (?) AND dpt.tag_id IN (?)", categoryIDs, user.TagIDs).
 			Find(&personalityProduct).
 			Error; err != nil {
 			return res, http.StatusInternalServerError, err
@@ -98,8 +115,8 @@ func GetHome(userID uint64) (res datastruct.HomeResponse, statusCode int, err er
 
 		for i, v := range personalityProduct {
 			personalityProduct[i].Image = strings.Split(v.Image, ",")[0]
+			personalityProduct[i].ImageRenditions = GetImageRenditions(personalityProduct[i].Image)
 			var tagIDs []uint64
-			var tags []string
 			if err := db.Table("detail_product_tags").
 				Select([]string{
 					"tag_id",
@@ -110,10 +127,7 @@ func GetHome(userID uint64) (res datastruct.HomeResponse, statusCode int, err er
 				return res, http.StatusInternalServerError, err
 			}
 
-			for _, v := range tagIDs {
-				tags = append(tags, constant.GetTagNameByDetailTag[v]...)
-			}
-			personalityProduct[i].Tags = utils.RemoveDuplicates(tags)
+			personalityProduct[i].Tags = GetTagLabels(tagIDs)
 		}
 	}
 
@@ -134,8 +148,8 @@ func GetHome(userID uint64) (res datastruct.HomeResponse, statusCode int, err er
 
 	for i, v := range recommendationProduct {
 		recommendationProduct[i].Image = strings.Split(v.Image, ",")[0]
+		recommendationProduct[i].ImageRenditions = GetImageRenditions(recommendationProduct[i].Image)
 		var tagIDs []uint64
-		var tags []string
 		if err := db.Table("detail_product_tags").
 			Select([]string{
 				"tag_id",
@@ -146,10 +160,7 @@ func GetHome(userID uint64) (res datastruct.HomeResponse, statusCode int, err er
 			return res, http.StatusInternalServerError, err
 		}
 
-		for _, v := range tagIDs {
-			tags = append(tags, constant.GetTagNameByDetailTag[v]...)
-		}
-		recommendationProduct[i].Tags = utils.RemoveDuplicates(tags)
+		recommendationProduct[i].Tags = GetTagLabels(tagIDs)
 	}
 
 	res = datastruct.HomeResponse{
@@ -165,16 +176,9 @@ func GetHomeSearch(search string) (res []datastruct.HomeProduct, statusCode int,
 	statusCode = http.StatusOK
 
 	var pIDs []string
-	response, err := utils.FetchMachineLearningAPI("GET", fmt.Sprintf("/product_search?query=%s", search), nil)
+	products, err := mlclient.Default().ProductSearch(context.Background(), search)
 	if err != nil {
-		statusCode = http.StatusInternalServerError
-		return
-	}
-
-	var products []datastruct.ProductFromML
-	err = json.Unmarshal(response, &products)
-	if err != nil {
-		fmt.Printf("Error unmarshaling response body: %v", err)
+		statusCode = mlclient.StatusCode(err)
 		return
 	}
 
@@ -203,12 +207,13 @@ func GetHomeSearch(search string) (res []datastruct.HomeProduct, statusCode int,
 
 	for i, v := range res {
 		res[i].Image = strings.Split(v.Image, ",")[0]
+		res[i].ImageRenditions = GetImageRenditions(res[i].Image)
 	}
 
 	return
 }
 
-func GetHomeMerchant() (merchants []datastruct.HomeMerchantResponse, statusCode int, err error) {
+func GetHomeMerchant(hideOutOfStock bool) (merchants []datastruct.HomeMerchantResponse, statusCode int, err error) {
 	db := Database()
 	statusCode = http.StatusOK
 
@@ -253,6 +258,23 @@ func GetHomeMerchant() (merchants []datastruct.HomeMerchantResponse, statusCode
 			return merchants, http.StatusInternalServerError, err
 		}
 
+		var offlineListingIDs []uint64
+		for _, v := range merchantProduct {
+			if v.AddressID != 0 {
+				offlineListingIDs = append(offlineListingIDs, v.ID)
+			}
+		}
+		stockStatuses := getListingStockStatuses(db, offlineListingIDs)
+
+		visibleProduct := merchantProduct[:0]
+		for _, v := range merchantProduct {
+			if hideOutOfStock && v.AddressID != 0 && stockStatuses[v.ID] == constant.StockStatusOutOfStock {
+				continue
+			}
+			visibleProduct = append(visibleProduct, v)
+		}
+		merchantProduct = visibleProduct
+
 		for i, v := range merchantProduct {
 			merchantProduct[i].Image = strings.Split(v.Image, ",")[0]
 
@@ -262,13 +284,19 @@ func GetHomeMerchant() (merchants []datastruct.HomeMerchantResponse, statusCode
 				if err == nil {
 					merchantProduct[i].Address = &addr
 				}
+				if status, ok := stockStatuses[v.ID]; ok {
+					merchantProduct[i].StockStatus = &status
+				}
 				continue
 			}
 
 			if v.MarketplaceID != 0 {
 				merchantProduct[i].Type = "online"
-				marketplaceName := constant.Marketplace[v.MarketplaceID]
-				merchantProduct[i].Marketplace = &marketplaceName
+				if marketplace, ok := GetMarketplaceByID(v.MarketplaceID); ok {
+					merchantProduct[i].Marketplace = &marketplace.Name
+					merchantProduct[i].MarketplaceLogo = &marketplace.Image
+					merchantProduct[i].DeepLink = MarketplaceDeepLink(marketplace, merchantProduct[i].MarketplaceLink)
+				}
 			}
 		}
 
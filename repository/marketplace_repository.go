@@ -0,0 +1,429 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yusufwib/arvigo-backend/constant"
+	"github.com/yusufwib/arvigo-backend/datastruct"
+	"github.com/yusufwib/arvigo-backend/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultMarketplaceCacheTTLSeconds = 300
+	defaultLinkResolveTimeoutSeconds  = 5
+	maxLinkRedirects                  = 5
+	maxListingLinkLength              = 500
+)
+
+// marketplaceRegistry is the in-memory copy of the marketplaces table,
+// refreshed after MARKETPLACE_CACHE_TTL_SECONDS or on any admin change.
+type marketplaceRegistry struct {
+	byID     map[uint64]datastruct.Marketplace
+	loadedAt time.Time
+}
+
+var (
+	marketplaceCacheMu sync.RWMutex
+	marketplaceCache   *marketplaceRegistry
+)
+
+func getMarketplaceRegistry() (*marketplaceRegistry, error) {
+	ttl := time.Duration(utils.StrToInt(os.Getenv("MARKETPLACE_CACHE_TTL_SECONDS"), defaultMarketplaceCacheTTLSeconds)) * time.Second
+
+	marketplaceCacheMu.RLock()
+	cached := marketplaceCache
+	marketplaceCacheMu.RUnlock()
+	if cached != nil && time.Since(cached.loadedAt) < ttl {
+		return cached, nil
+	}
+
+	var marketplaces []datastruct.Marketplace
+	if err := Database().Find(&marketplaces).Error; err != nil {
+		if cached != nil {
+			log.Println("Failed to refresh marketplaces:", err)
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	registry := &marketplaceRegistry{
+		byID:     make(map[uint64]datastruct.Marketplace, len(marketplaces)),
+		loadedAt: time.Now(),
+	}
+	for _, v := range marketplaces {
+		registry.byID[v.ID] = v
+	}
+
+	marketplaceCacheMu.Lock()
+	marketplaceCache = registry
+	marketplaceCacheMu.Unlock()
+
+	return registry, nil
+}
+
+func invalidateMarketplaceRegistry() {
+	marketplaceCacheMu.Lock()
+	marketplaceCache = nil
+	marketplaceCacheMu.Unlock()
+}
+
+// GetMarketplaceByID returns the marketplace from the cached registry.
+func GetMarketplaceByID(marketplaceID uint64) (datastruct.Marketplace, bool) {
+	registry, err := getMarketplaceRegistry()
+	if err != nil {
+		log.Println("Failed to load marketplaces:", err)
+		return datastruct.Marketplace{}, false
+	}
+
+	marketplace, ok := registry.byID[marketplaceID]
+	return marketplace, ok
+}
+
+// MarketplaceDeepLink fills the marketplace deep-link template for a listing
+// link. {url} is the full link and {path} the link path with its query.
+func MarketplaceDeepLink(marketplace datastruct.Marketplace, link *string) *string {
+	if marketplace.DeepLinkTemplate == "" || link == nil || *link == "" {
+		return nil
+	}
+
+	parsed, err := url.Parse(*link)
+	if err != nil {
+		return nil
+	}
+
+	path := strings.TrimPrefix(parsed.EscapedPath(), "/")
+	if parsed.RawQuery != "" {
+		path += "?" + parsed.RawQuery
+	}
+
+	deepLink := strings.NewReplacer("{url}", url.QueryEscape(*link), "{path}", path).Replace(marketplace.DeepLinkTemplate)
+	return &deepLink
+}
+
+// MarketplaceHostAllowed reports whether the host matches one of the marketplace
+// host patterns. A pattern matches the host itself and its subdomains.
+func MarketplaceHostAllowed(marketplace datastruct.Marketplace, host string) bool {
+	return matchHostPatterns(marketplace.HostPatterns, host)
+}
+
+func matchHostPatterns(patterns, host string) bool {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	for _, pattern := range utils.ConvertStringToSlice(patterns, ",") {
+		pattern = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(pattern)), "www.")
+		if pattern == "*" || host == pattern || strings.HasSuffix(host, "."+pattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CanonicalizeMarketplaceLink validates a listing link against the marketplace:
+// short links are resolved, the host must match the marketplace host patterns,
+// tracking parameters are dropped and the shop/product identifiers extracted.
+func CanonicalizeMarketplaceLink(marketplaceID uint64, rawLink string) (res datastruct.MarketplaceLink, statusCode int, err error) {
+	statusCode = http.StatusOK
+
+	marketplace, ok := GetMarketplaceByID(marketplaceID)
+	if !ok {
+		return res, http.StatusBadRequest, fmt.Errorf("marketplace %d not found", marketplaceID)
+	}
+	if !marketplace.IsActive {
+		return res, http.StatusBadRequest, fmt.Errorf("marketplace %s is not active", marketplace.Name)
+	}
+
+	rawLink = strings.TrimSpace(rawLink)
+	if rawLink == "" {
+		return res, http.StatusBadRequest, errors.New("marketplace listing must have a link")
+	}
+	if !strings.Contains(rawLink, "://") {
+		rawLink = "https://" + rawLink
+	}
+
+	link, err := url.Parse(rawLink)
+	if err != nil || link.Hostname() == "" || (link.Scheme != "http" && link.Scheme != "https") {
+		return res, http.StatusBadRequest, fmt.Errorf("invalid link: %s", rawLink)
+	}
+
+	if matchHostPatterns(marketplace.ShortLinkHosts, link.Hostname()) {
+		timeout := time.Duration(utils.StrToInt(os.Getenv("LINK_RESOLVE_TIMEOUT_SECONDS"), defaultLinkResolveTimeoutSeconds)) * time.Second
+		// every hop must stay on the short link service until it reaches the marketplace
+		resolved, err := utils.ResolveRedirects(link.String(), maxLinkRedirects, timeout, func(hop *url.URL) (bool, error) {
+			if MarketplaceHostAllowed(marketplace, hop.Hostname()) {
+				return true, nil
+			}
+			if matchHostPatterns(marketplace.ShortLinkHosts, hop.Hostname()) {
+				return false, nil
+			}
+			return false, fmt.Errorf("redirect to %s is not allowed", hop.Hostname())
+		})
+		if err != nil {
+			log.Println("Failed to resolve short link:", err)
+			return res, http.StatusBadRequest, fmt.Errorf("failed to resolve short link %s", rawLink)
+		}
+		if link, err = url.Parse(resolved); err != nil {
+			return res, http.StatusBadRequest, fmt.Errorf("invalid link: %s", resolved)
+		}
+	}
+
+	if !MarketplaceHostAllowed(marketplace, link.Hostname()) {
+		return res, http.StatusBadRequest, fmt.Errorf("link %s is not a %s link", link.Hostname(), marketplace.Name)
+	}
+
+	link.Scheme = "https"
+	link.Host = strings.ToLower(link.Host)
+	link.User = nil
+	link.Fragment = ""
+	link.RawFragment = ""
+	if link.Path != "/" {
+		link.Path = strings.TrimSuffix(link.Path, "/")
+		link.RawPath = ""
+	}
+	utils.StripQueryParams(link, constant.TrackingParams, constant.TrackingParamPrefixes)
+
+	res.Link = link.String()
+	if len(res.Link) > maxListingLinkLength {
+		return res, http.StatusBadRequest, fmt.Errorf("link must be at most %d characters", maxListingLinkLength)
+	}
+
+	if marketplace.IdentifierPattern != "" {
+		pattern, err := regexp.Compile(marketplace.IdentifierPattern)
+		if err != nil {
+			log.Println("Invalid marketplace identifier pattern:", err)
+			return res, http.StatusOK, nil
+		}
+
+		match := pattern.FindStringSubmatch(link.Path)
+		if match == nil {
+			return res, http.StatusBadRequest, fmt.Errorf("link is not a %s product link", marketplace.Name)
+		}
+		for i, name := range pattern.SubexpNames() {
+			switch name {
+			case "shop":
+				res.ShopIdentifier = match[i]
+			case "product":
+				res.ProductIdentifier = match[i]
+			}
+		}
+	}
+
+	return
+}
+
+// checkDuplicateListing rejects a link that is already listed on the same
+// marketplace, by any merchant, ignoring the listings in excludeIDs.
+func checkDuplicateListing(db *gorm.DB, marketplaceID uint64, link datastruct.MarketplaceLink, excludeIDs []uint64) (statusCode int, err error) {
+	statusCode = http.StatusOK
+
+	query := db.Model(&datastruct.DetailProductMarketplace{}).Where("marketplace_id = ?", marketplaceID)
+	if link.ProductIdentifier != "" {
+		query = query.Where("(link = ? OR (product_identifier = ? AND IFNULL(shop_identifier, '') = ?))", link.Link, link.ProductIdentifier, link.ShopIdentifier)
+	} else {
+		query = query.Where("link = ?", link.Link)
+	}
+	if len(excludeIDs) > 0 {
+		query = query.Where("id NOT IN (?)", excludeIDs)
+	}
+
+	var duplicate int64
+	if err = query.Count(&duplicate).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if duplicate > 0 {
+		return http.StatusConflict, fmt.Errorf("%s is already listed", link.Link)
+	}
+
+	return
+}
+
+// canonicalizeListingLink canonicalizes the link and rejects it when it repeats
+// another link of the same request (tracked in seen) or an existing listing.
+func canonicalizeListingLink(db *gorm.DB, marketplaceID uint64, rawLink string, excludeIDs []uint64, seen map[string]bool) (res datastruct.MarketplaceLink, statusCode int, err error) {
+	if res, statusCode, err = CanonicalizeMarketplaceLink(marketplaceID, rawLink); err != nil {
+		return
+	}
+
+	key := fmt.Sprintf("%d|%s", marketplaceID, res.Link)
+	if res.ProductIdentifier != "" {
+		key = fmt.Sprintf("%d|%s|%s", marketplaceID, res.ShopIdentifier, res.ProductIdentifier)
+	}
+	if seen[key] {
+		return res, http.StatusBadRequest, fmt.Errorf("%s is submitted more than once", res.Link)
+	}
+	seen[key] = true
+
+	statusCode, err = checkDuplicateListing(db, marketplaceID, res, excludeIDs)
+	return
+}
+
+func GetMarketplaces(includeInactive bool) (res []datastruct.Marketplace, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	query := db.Order("id")
+	if !includeInactive {
+		query = query.Where("is_active = 1")
+	}
+
+	if err = query.Find(&res).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	return
+}
+
+func CreateMarketplace(data datastruct.MarketplaceInput) (res datastruct.Marketplace, statusCode int, err error) {
+	db := Database()
+	currentTime := time.Now()
+
+	if statusCode, err = validateMarketplaceInput(db, data, 0); err != nil {
+		return
+	}
+
+	res = datastruct.Marketplace{
+		IsActive:  true,
+		CreatedAt: currentTime,
+	}
+	if statusCode, err = applyMarketplaceInput(&res, data, currentTime); err != nil {
+		return
+	}
+
+	if err = db.Create(&res).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	invalidateMarketplaceRegistry()
+	return res, http.StatusCreated, nil
+}
+
+func UpdateMarketplace(marketplaceID uint64, data datastruct.MarketplaceInput) (res datastruct.Marketplace, statusCode int, err error) {
+	db := Database()
+
+	if err = db.Where("id = ?", marketplaceID).First(&res).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res, http.StatusNotFound, errors.New("marketplace not found")
+		}
+		return res, http.StatusInternalServerError, err
+	}
+
+	if statusCode, err = validateMarketplaceInput(db, data, marketplaceID); err != nil {
+		return
+	}
+
+	if statusCode, err = applyMarketplaceInput(&res, data, time.Now()); err != nil {
+		return
+	}
+
+	if err = db.Save(&res).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	invalidateMarketplaceRegistry()
+	return res, http.StatusOK, nil
+}
+
+func DeleteMarketplace(marketplaceID uint64) (statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	var usage int64
+	if err = db.Model(&datastruct.DetailProductMarketplace{}).Where("marketplace_id = ?", marketplaceID).Count(&usage).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if usage > 0 {
+		return http.StatusConflict, errors.New("marketplace still has listings, deactivate it instead")
+	}
+
+	result := db.Where("id = ?", marketplaceID).Delete(&datastruct.Marketplace{})
+	if result.Error != nil {
+		return http.StatusInternalServerError, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return http.StatusNotFound, errors.New("marketplace not found")
+	}
+
+	invalidateMarketplaceRegistry()
+	return
+}
+
+func validateMarketplaceInput(db *gorm.DB, data datastruct.MarketplaceInput, marketplaceID uint64) (statusCode int, err error) {
+	statusCode = http.StatusOK
+
+	if strings.TrimSpace(data.Name) == "" {
+		return http.StatusBadRequest, errors.New("name cannot be empty")
+	}
+
+	if data.WebsiteURL != "" {
+		parsed, err := url.Parse(data.WebsiteURL)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return http.StatusBadRequest, errors.New("website_url must be an http or https URL")
+		}
+	}
+
+	if data.DeepLinkTemplate != "" && !strings.Contains(data.DeepLinkTemplate, "{path}") && !strings.Contains(data.DeepLinkTemplate, "{url}") {
+		return http.StatusBadRequest, errors.New("deep_link_template must contain {path} or {url}")
+	}
+
+	if data.IdentifierPattern != "" {
+		if _, err := regexp.Compile(data.IdentifierPattern); err != nil {
+			return http.StatusBadRequest, fmt.Errorf("identifier_pattern is not a valid regular expression: %v", err)
+		}
+	}
+
+	var duplicate int64
+	if err = db.Model(&datastruct.Marketplace{}).
+		Where("LOWER(name) = LOWER(?) AND id != ?", strings.TrimSpace(data.Name), marketplaceID).
+		Count(&duplicate).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if duplicate > 0 {
+		return http.StatusConflict, errors.New("marketplace already exists")
+	}
+
+	return
+}
+
+func applyMarketplaceInput(marketplace *datastruct.Marketplace, data datastruct.MarketplaceInput, currentTime time.Time) (statusCode int, err error) {
+	statusCode = http.StatusOK
+
+	if data.Image != nil {
+		url, err := UploadImage(data.Image, constant.AssetKindMarketplace)
+		if err != nil {
+			return ImageStatusCode(err), err
+		}
+		marketplace.Image = url
+	}
+
+	var hostPatterns []string
+	for _, v := range utils.ConvertStringToSlice(data.HostPatterns, ",") {
+		hostPatterns = append(hostPatterns, strings.ToLower(strings.TrimSpace(v)))
+	}
+
+	var shortLinkHosts []string
+	for _, v := range utils.ConvertStringToSlice(data.ShortLinkHosts, ",") {
+		shortLinkHosts = append(shortLinkHosts, strings.ToLower(strings.TrimSpace(v)))
+	}
+
+	marketplace.Name = strings.TrimSpace(data.Name)
+	marketplace.WebsiteURL = data.WebsiteURL
+	marketplace.HostPatterns = strings.Join(hostPatterns, ",")
+	marketplace.DeepLinkTemplate = data.DeepLinkTemplate
+	marketplace.ShortLinkHosts = strings.Join(shortLinkHosts, ",")
+	marketplace.IdentifierPattern = data.IdentifierPattern
+	if data.IsActive != nil {
+		marketplace.IsActive = *data.IsActive
+	}
+	marketplace.UpdatedAt = currentTime
+
+	return
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/yusufwib/arvigo-backend/constant"
+	"github.com/yusufwib/arvigo-backend/pkg/cache"
+	"github.com/yusufwib/arvigo-backend/pkg/imaging"
+	"github.com/yusufwib/arvigo-backend/utils"
+)
+
+const (
+	defaultMLCacheTTLSeconds = 7 * 24 * 60 * 60
+	mlCacheKeyPrefix         = "ml"
+	mlCacheTimeout           = 500 * time.Millisecond
+)
+
+// ML responses are cached in Redis under ml:<endpoint>:<model version>:<fingerprint>.
+// The model version of an endpoint is learned from its latest response and kept
+// under ml:<endpoint>:version, so once the service ships a new model the old
+// entries stop matching and simply expire.
+
+func mlCacheTTL() time.Duration {
+	return time.Duration(utils.StrToInt(os.Getenv("ML_CACHE_TTL_SECONDS"), defaultMLCacheTTLSeconds)) * time.Second
+}
+
+// getCachedMLResult decodes a cached response into out and reports whether
+// there was one. Cache failures only cost a cache miss.
+func getCachedMLResult(endpoint, fingerprint string, out interface{}) bool {
+	if fingerprint == "" || mlCacheTTL() <= 0 {
+		return false
+	}
+
+	client, err := cache.Default()
+	if err != nil {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mlCacheTimeout)
+	defer cancel()
+
+	version, err := client.Get(ctx, mlCacheVersionKey(endpoint)).Result()
+	if err != nil {
+		if err != redis.Nil {
+			log.Println("Failed to read ML cache version:", err)
+		}
+		return false
+	}
+
+	cached, err := client.Get(ctx, mlCacheKey(endpoint, version, fingerprint)).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			log.Println("Failed to read ML cache:", err)
+		}
+		return false
+	}
+
+	return json.Unmarshal(cached, out) == nil
+}
+
+// cacheMLResult stores a fresh response and records the model version that produced it.
+func cacheMLResult(endpoint, fingerprint, version string, value interface{}) {
+	ttl := mlCacheTTL()
+	if fingerprint == "" || ttl <= 0 {
+		return
+	}
+
+	client, err := cache.Default()
+	if err != nil {
+		return
+	}
+
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	if version == "" {
+		version = constant.MLModelVersionUnknown
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mlCacheTimeout)
+	defer cancel()
+
+	_, err = client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, mlCacheVersionKey(endpoint), version, ttl)
+		pipe.Set(ctx, mlCacheKey(endpoint, version, fingerprint), payload, ttl)
+		return nil
+	})
+	if err != nil {
+		log.Println("Failed to write ML cache:", err)
+	}
+}
+
+func mlCacheVersionKey(endpoint string) string {
+	return fmt.Sprintf("%s:%s:version", mlCacheKeyPrefix, endpoint)
+}
+
+func mlCacheKey(endpoint, version, fingerprint string) string {
+	return fmt.Sprintf("%s:%s:%s:%s", mlCacheKeyPrefix, endpoint, version, fingerprint)
+}
+
+// imageFingerprint is the user's id and the perceptual hash of the image, so
+// a retake of the same photo or a re-encoded copy maps to the same entry. The
+// hash alone is too coarse to tell different faces apart, so entries are never
+// shared between users. Empty when the image cannot be hashed.
+func imageFingerprint(userID uint64, imageData []byte) string {
+	hash, err := imaging.DifferenceHash(bytes.NewReader(imageData))
+	if err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%d:%016x", userID, hash)
+}
+
+// answersFingerprint hashes the questionnaire version and a sorted
+// question=answer list, so the same answers always give the same key.
+func answersFingerprint(versionID uint64, answers map[string]int) string {
+	fields := make([]string, 0, len(answers))
+	for name, answer := range answers {
+		fields = append(fields, fmt.Sprintf("%s=%d", strings.ToUpper(name), answer))
+	}
+	sort.Strings(fields)
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("v%d:%s", versionID, strings.Join(fields, ","))))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/yusufwib/arvigo-backend/constant"
+	"github.com/yusufwib/arvigo-backend/datastruct"
+)
+
+var errInvalidMLResultType = errors.New("type must be face_shape or personality")
+
+// latestPersonalitiesQuery selects the current questionnaire result of every user.
+const latestPersonalitiesQuery = "SELECT user_id, MAX(id) AS id FROM user_personalities WHERE is_active = 1 GROUP BY user_id"
+
+// GetModelVersionReport counts the stored results of the given type per model
+// version, with how many are still what users see and the feedback they got.
+func GetModelVersionReport(resultType string) (res []datastruct.ModelVersionReport, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	switch resultType {
+	case constant.MLResultFaceShape:
+		err = db.Table("face_scans fs").
+			Select(strings.Join([]string{
+				"COALESCE(fs.model_version, ?) as model_version",
+				"COUNT(*) as results",
+				"COUNT(DISTINCT fs.user_id) as users",
+				"SUM(fs.is_active) as active_results",
+				"COUNT(fb.id) as feedbacks",
+				"SUM(CASE WHEN fb.is_correct = 0 THEN 1 ELSE 0 END) as corrections",
+				"MIN(fs.created_at) as first_seen",
+				"MAX(fs.created_at) as last_seen",
+			}, ", "), constant.MLModelVersionUnknown).
+			Joins("LEFT JOIN face_scan_feedbacks fb on fb.face_scan_id = fs.id").
+			Group("fs.model_version").
+			Order("last_seen DESC").
+			Find(&res).Error
+	case constant.MLResultPersonality:
+		err = db.Table("user_personalities up").
+			Select(strings.Join([]string{
+				"COALESCE(up.model_version, ?) as model_version",
+				"COUNT(*) as results",
+				"COUNT(DISTINCT up.user_id) as users",
+				"COUNT(latest.id) as active_results",
+				"COUNT(pf.id) as feedbacks",
+				"AVG(pf.rating) as average_rating",
+				"MIN(up.created_at) as first_seen",
+				"MAX(up.created_at) as last_seen",
+			}, ", "), constant.MLModelVersionUnknown).
+			Joins("LEFT JOIN (" + latestPersonalitiesQuery + ") latest on latest.id = up.id").
+			Joins("LEFT JOIN personality_feedbacks pf on pf.user_personality_id = up.id").
+			Group("up.model_version").
+			Order("last_seen DESC").
+			Find(&res).Error
+	default:
+		return res, http.StatusBadRequest, errInvalidMLResultType
+	}
+	if err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	return
+}
+
+// GetModelAffectedUsers lists the users whose current result of the given
+// type was produced by modelVersion, so they can be asked to retake the test.
+func GetModelAffectedUsers(resultType, modelVersion string) (res []datastruct.ModelAffectedUser, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	switch resultType {
+	case constant.MLResultFaceShape:
+		err = db.Table("face_scans fs").
+			Select([]string{
+				"u.id as user_id",
+				"u.email",
+				"u.full_name",
+				"fs.id as result_id",
+				"fs.created_at",
+			}).
+			Joins("JOIN users u on u.id = fs.user_id").
+			Where("fs.is_active = 1 AND COALESCE(fs.model_version, ?) = ?", constant.MLModelVersionUnknown, modelVersion).
+			Order("fs.created_at ASC").
+			Find(&res).Error
+	case constant.MLResultPersonality:
+		err = db.Table("user_personalities up").
+			Select([]string{
+				"u.id as user_id",
+				"u.email",
+				"u.full_name",
+				"up.id as result_id",
+				"up.created_at",
+			}).
+			Joins("JOIN ("+latestPersonalitiesQuery+") latest on latest.id = up.id").
+			Joins("JOIN users u on u.id = up.user_id").
+			Where("COALESCE(up.model_version, ?) = ?", constant.MLModelVersionUnknown, modelVersion).
+			Order("up.created_at ASC").
+			Find(&res).Error
+	default:
+		return res, http.StatusBadRequest, errInvalidMLResultType
+	}
+	if err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	return
+}
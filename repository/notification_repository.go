@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/yusufwib/arvigo-backend/datastruct"
+)
+
+func GetUserNotifications(userID uint64) (res []datastruct.Notification, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	if err = db.Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(100).
+		Find(&res).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	return
+}
+
+func ReadNotification(userID, notificationID uint64) (statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+	currentTime := time.Now()
+
+	result := db.Model(&datastruct.Notification{}).
+		Where("id = ? AND user_id = ?", notificationID, userID).
+		Updates(map[string]interface{}{
+			"read_at":    currentTime,
+			"updated_at": currentTime,
+		})
+	if result.Error != nil {
+		return http.StatusInternalServerError, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return http.StatusNotFound, errors.New("notification not found")
+	}
+
+	return
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/yusufwib/arvigo-backend/constant"
+	"github.com/yusufwib/arvigo-backend/datastruct"
+	"github.com/yusufwib/arvigo-backend/utils"
+	"gorm.io/gorm"
+)
+
+// GetInitialProductOffers lists every approved merchant listing linked to the
+// initial product. Boosted (subscribed) listings come first, then the rest are
+// ordered by price or by distance from lat/lng.
+func GetInitialProductOffers(initialProductID uint64, sortBy string, lat, lng *float64, hideOutOfStock bool) (res datastruct.ProductOffersResponse, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	if sortBy == "" {
+		sortBy = constant.OfferSortPrice
+	}
+	switch sortBy {
+	case constant.OfferSortPrice, constant.OfferSortPriceDesc:
+	case constant.OfferSortDistance:
+		if lat == nil || lng == nil {
+			return res, http.StatusBadRequest, errors.New("lat and lng are required to sort by distance")
+		}
+	default:
+		return res, http.StatusBadRequest, errors.New("sort must be one of price, price_desc or distance")
+	}
+
+	var product datastruct.Product
+	if err = db.Where("id = ? AND merchant_id = 0", initialProductID).First(&product).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res, http.StatusNotFound, errors.New("product not found")
+		}
+		return res, http.StatusInternalServerError, err
+	}
+
+	var offers []datastruct.ProductOffer
+	if err = db.Table("detail_linked_products dlp").
+		Select([]string{
+			"dpm.id",
+			"p.id AS product_id",
+			"p.price",
+			"p.merchant_id",
+			"m.name AS merchant",
+			"p.is_subscription_active",
+			"dpm.link AS marketplace_link",
+			"dpm.marketplace_id",
+			"IFNULL(dpm.addresses_id, 0) AS addresses_id",
+			"a.latitude",
+			"a.longitude",
+		}).
+		Joins("JOIN products p ON p.id = dlp.merchant_product_id").
+		Joins("JOIN detail_product_marketplaces dpm ON dpm.product_id = p.id").
+		Joins("LEFT JOIN merchants m ON m.id = p.merchant_id").
+		Joins("LEFT JOIN addresses a ON a.id = dpm.addresses_id").
+		Where("dlp.initial_product_id = ? AND p.status IN (?)", initialProductID, []string{constant.StatusApproved, constant.StatusSubscribed}).
+		Find(&offers).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	var offlineListingIDs []uint64
+	for _, v := range offers {
+		if v.AddressID != 0 {
+			offlineListingIDs = append(offlineListingIDs, v.ID)
+		}
+	}
+	stockStatuses := getListingStockStatuses(db, offlineListingIDs)
+
+	visibleOffers := offers[:0]
+	for _, v := range offers {
+		if hideOutOfStock && v.AddressID != 0 && stockStatuses[v.ID] == constant.StockStatusOutOfStock {
+			continue
+		}
+		visibleOffers = append(visibleOffers, v)
+	}
+	offers = visibleOffers
+
+	var addressIDs []uint64
+	for _, v := range offers {
+		if v.AddressID != 0 {
+			addressIDs = append(addressIDs, v.AddressID)
+		}
+	}
+	addresses := getAddressesByIDs(db, addressIDs)
+
+	for i, v := range offers {
+		if v.AddressID != 0 {
+			offers[i].Type = "offline"
+			if status, ok := stockStatuses[v.ID]; ok {
+				offers[i].StockStatus = &status
+			}
+			offers[i].MarketplaceLink = nil
+			if addr, ok := addresses[v.AddressID]; ok {
+				offers[i].Address = &addr.Address
+				offers[i].Location = &addr.Location
+			}
+
+			if lat != nil && lng != nil && v.Latitude != nil && v.Longitude != nil {
+				distance := utils.RoundFloat64(utils.HaversineKm(*lat, *lng, *v.Latitude, *v.Longitude), 2)
+				offers[i].DistanceKm = &distance
+			}
+		} else if v.MarketplaceID != 0 {
+			offers[i].Type = "online"
+			if marketplace, ok := GetMarketplaceByID(v.MarketplaceID); ok {
+				offers[i].Marketplace = &marketplace.Name
+				offers[i].MarketplaceLogo = &marketplace.Image
+				offers[i].DeepLink = MarketplaceDeepLink(marketplace, offers[i].MarketplaceLink)
+			}
+		}
+	}
+
+	sort.SliceStable(offers, func(i, j int) bool {
+		a, b := offers[i], offers[j]
+		if a.IsBoosted != b.IsBoosted {
+			return a.IsBoosted
+		}
+
+		switch sortBy {
+		case constant.OfferSortPriceDesc:
+			return a.Price > b.Price
+		case constant.OfferSortDistance:
+			// listings without a known distance (online or no coordinates) go last
+			if (a.DistanceKm == nil) != (b.DistanceKm == nil) {
+				return a.DistanceKm != nil
+			}
+			if a.DistanceKm != nil && *a.DistanceKm != *b.DistanceKm {
+				return *a.DistanceKm < *b.DistanceKm
+			}
+			return a.Price < b.Price
+		default:
+			return a.Price < b.Price
+		}
+	})
+
+	res = datastruct.ProductOffersResponse{
+		InitialProductID: product.ID,
+		Name:             product.Name,
+		Offers:           offers,
+	}
+	for i, v := range offers {
+		if i == 0 || v.Price < res.LowestPrice {
+			res.LowestPrice = v.Price
+		}
+		if v.Price > res.HighestPrice {
+			res.HighestPrice = v.Price
+		}
+	}
+	if res.Offers == nil {
+		res.Offers = []datastruct.ProductOffer{}
+	}
+
+	return
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/yusufwib/arvigo-backend/constant"
+	"github.com/yusufwib/arvigo-backend/datastruct"
+	"gorm.io/gorm"
+)
+
+// recordPriceChange stores a price history entry when the price actually changed.
+func recordPriceChange(tx *gorm.DB, productID uint64, previousPrice *float64, price float64, currentTime time.Time) error {
+	if previousPrice != nil && *previousPrice == price {
+		return nil
+	}
+
+	return tx.Create(&datastruct.PriceHistory{
+		ProductID:     productID,
+		Price:         price,
+		PreviousPrice: previousPrice,
+		CreatedAt:     currentTime,
+		UpdatedAt:     currentTime,
+	}).Error
+}
+
+// GetPriceHistory returns the price changes of a merchant product, optionally
+// limited to the last given days.
+func GetPriceHistory(productID uint64, days int) (res datastruct.PriceHistoryResponse, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	var product datastruct.Product
+	if err = db.Where("id = ? AND merchant_id != 0", productID).First(&product).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res, http.StatusNotFound, errors.New("product not found")
+		}
+		return res, http.StatusInternalServerError, err
+	}
+
+	query := db.Where("product_id = ?", productID)
+	if days > 0 {
+		query = query.Where("created_at >= ?", time.Now().AddDate(0, 0, -days))
+	}
+
+	var histories []datastruct.PriceHistory
+	if err = query.Order("created_at ASC").Find(&histories).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	res = datastruct.PriceHistoryResponse{
+		ProductID:    product.ID,
+		CurrentPrice: product.Price,
+		LowestPrice:  product.Price,
+		HighestPrice: product.Price,
+		Histories:    histories,
+	}
+	for _, v := range histories {
+		if v.Price < res.LowestPrice {
+			res.LowestPrice = v.Price
+		}
+		if v.Price > res.HighestPrice {
+			res.HighestPrice = v.Price
+		}
+	}
+
+	return
+}
+
+// PriceDropAlertCronJob notifies users whose wishlisted listing is now cheaper
+// than when they saved it. Each lower price is only announced once.
+func PriceDropAlertCronJob() (res datastruct.PriceAlertReport, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+	currentTime := time.Now()
+
+	var candidates []datastruct.PriceDropCandidate
+	if err = db.Table("wishlists w").
+		Select(`w.id AS wishlist_id, w.user_id, dpm.id AS listing_id, p.name AS product_name,
+			IFNULL(m.name, 'Offline') AS marketplace, w.saved_price, w.alerted_price, p.price AS current_price`).
+		Joins("JOIN detail_product_marketplaces dpm ON dpm.id = w.detail_product_marketplace_id").
+		Joins("JOIN products p ON p.id = dpm.product_id").
+		Joins("LEFT JOIN marketplaces m ON m.id = dpm.marketplace_id").
+		Where("w.saved_price IS NOT NULL AND p.price < w.saved_price").
+		Where("w.alerted_price IS NULL OR p.price < w.alerted_price").
+		Where("p.status IN (?)", []string{constant.StatusApproved, constant.StatusSubscribed}).
+		Find(&candidates).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	for _, v := range candidates {
+		res.Checked++
+
+		notification := datastruct.Notification{
+			UserID:      v.UserID,
+			Type:        constant.NotificationTypePriceDrop,
+			Title:       "Price drop",
+			Body:        fmt.Sprintf("%s on %s is now Rp%.0f, down from Rp%.0f when you saved it", v.ProductName, v.Marketplace, v.CurrentPrice, v.SavedPrice),
+			ReferenceID: v.ListingID,
+			CreatedAt:   currentTime,
+			UpdatedAt:   currentTime,
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&notification).Error; err != nil {
+				return err
+			}
+
+			return tx.Model(&datastruct.Wishlist{}).
+				Where("id = ?", v.WishlistID).
+				Updates(map[string]interface{}{
+					"alerted_price": v.CurrentPrice,
+					"updated_at":    currentTime,
+				}).Error
+		})
+		if err != nil {
+			log.Printf("Failed to send price drop alert for wishlist %d: %v", v.WishlistID, err)
+			continue
+		}
+
+		res.Notified++
+	}
+
+	return res, http.StatusOK, nil
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,11 +14,16 @@ import (
 
 	"github.com/yusufwib/arvigo-backend/constant"
 	"github.com/yusufwib/arvigo-backend/datastruct"
+	"github.com/yusufwib/arvigo-backend/pkg/mlclient"
 	"github.com/yusufwib/arvigo-backend/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-func CreateInitialProduct(data datastruct.CreateInitialProductInput) (statusCode int, err error) {
+// CreateInitialProduct adds a product to the initial catalog. Products that
+// look like an existing one of the same brand and category are only created
+// once the admin confirms, otherwise they come back as duplicates with a 409.
+func CreateInitialProduct(data datastruct.CreateInitialProductInput) (duplicates []datastruct.DuplicateProduct, statusCode int, err error) {
 	statusCode = http.StatusCreated
 
 	var (
@@ -30,13 +36,28 @@ func CreateInitialProduct(data datastruct.CreateInitialProductInput) (statusCode
 
 	err = json.Unmarshal([]byte(data.DetailProductVariants), &detailVariants)
 	if err != nil {
-		return http.StatusBadRequest, errors.New("failed to parse variants")
+		return nil, http.StatusBadRequest, errors.New("failed to parse variants")
+	}
+
+	attributes, attributesStatusCode, err := ValidateProductAttributes(db, data.CategoryID, data.Attributes)
+	if err != nil {
+		return nil, attributesStatusCode, err
+	}
+
+	imageHashes := hashProductImages(data.Images)
+	if !data.ConfirmDuplicate {
+		if duplicates, err = findDuplicateProducts(db, 0, data.BrandID, data.CategoryID, data.Name, imageHashes); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		if len(duplicates) > 0 {
+			return duplicates, http.StatusConflict, errors.New("similar products already exist, send confirm_duplicate=true to create it anyway")
+		}
 	}
 
 	for _, img := range data.Images {
-		url, err := UploadImageToGCS(img)
+		url, err := UploadImage(img, constant.AssetKindProduct)
 		if err != nil {
-			return http.StatusInternalServerError, err
+			return nil, ImageStatusCode(err), err
 		}
 		imagesURL = append(imagesURL, url)
 	}
@@ -49,6 +70,7 @@ func CreateInitialProduct(data datastruct.CreateInitialProductInput) (statusCode
 		CategoryID:   data.CategoryID,
 		BrandID:      data.BrandID,
 		MerchantID:   0, // 0 is for create from admin
+		Attributes:   attributes,
 		CreatedAt:    currentTime,
 		UpdatedAt:    currentTime,
 	}
@@ -64,14 +86,14 @@ func CreateInitialProduct(data datastruct.CreateInitialProductInput) (statusCode
 	}()
 
 	if err = tx.Create(&productPayload).Error; err != nil {
-		return http.StatusInternalServerError, err
+		return nil, http.StatusInternalServerError, err
 	}
 
 	explodedTags := strings.Split(data.DetailProductTags, ",")
 	for _, tagID := range explodedTags {
 		tagIDNum := utils.StrToUint64(tagID, 0)
 		if tagIDNum == 0 {
-			return http.StatusInternalServerError, errors.New("cannot add tags")
+			return nil, http.StatusInternalServerError, errors.New("cannot add tags")
 		}
 
 		productTagsPayload = append(productTagsPayload, datastruct.DetailProductTag{
@@ -82,8 +104,13 @@ func CreateInitialProduct(data datastruct.CreateInitialProductInput) (statusCode
 		})
 	}
 
+	if err = syncProductImageHashes(tx, productPayload.ID, imagesURL, imagesURL, imageHashes, currentTime); err != nil {
+		tx.Rollback()
+		return nil, http.StatusInternalServerError, err
+	}
+
 	if err = tx.Create(&productTagsPayload).Error; err != nil {
-		return http.StatusInternalServerError, err
+		return nil, http.StatusInternalServerError, err
 	}
 
 	// Create variants
@@ -93,16 +120,18 @@ func CreateInitialProduct(data datastruct.CreateInitialProductInput) (statusCode
 		detailVariants[i].UpdatedAt = currentTime
 	}
 	if err = tx.Create(&detailVariants).Error; err != nil {
-		return http.StatusInternalServerError, err
+		return nil, http.StatusInternalServerError, err
 	}
 
 	// Commit the transaction if all queries succeed
 	if err = tx.Commit().Error; err != nil {
 		tx.Rollback()
 		log.Println("Error committing transaction:", err)
-		return http.StatusInternalServerError, err
+		return nil, http.StatusInternalServerError, err
 	}
 
+	invalidateSimilarProducts()
+
 	return
 }
 
@@ -122,10 +151,15 @@ func UpdateInitialProduct(data datastruct.CreateInitialProductInput, productID u
 		return http.StatusBadRequest, errors.New("failed to parse variants")
 	}
 
+	attributes, attributesStatusCode, err := ValidateProductAttributes(db, data.CategoryID, data.Attributes)
+	if err != nil {
+		return attributesStatusCode, err
+	}
+
 	for _, img := range data.Images {
-		url, err := UploadImageToGCS(img)
+		url, err := UploadImage(img, constant.AssetKindProduct)
 		if err != nil {
-			return http.StatusInternalServerError, err
+			return ImageStatusCode(err), err
 		}
 		imagesURL = append(imagesURL, url)
 	}
@@ -139,6 +173,7 @@ func UpdateInitialProduct(data datastruct.CreateInitialProductInput, productID u
 		CategoryID:   data.CategoryID,
 		BrandID:      data.BrandID,
 		MerchantID:   0, // 0 is for create from admin
+		Attributes:   attributes,
 		UpdatedAt:    currentTime,
 	}
 
@@ -156,6 +191,18 @@ func UpdateInitialProduct(data datastruct.CreateInitialProductInput, productID u
 		return http.StatusInternalServerError, err
 	}
 
+	// Updates skips nil fields, so clear attributes the form no longer sends explicitly
+	if err = tx.Model(&datastruct.Product{}).Where("id", productPayload.ID).Update("attributes", attributes).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if len(imagesURL) > 0 {
+		if err = syncProductImageHashes(tx, productPayload.ID, imagesURL, imagesURL, hashProductImages(data.Images), currentTime); err != nil {
+			tx.Rollback()
+			return http.StatusInternalServerError, err
+		}
+	}
+
 	explodedTags := strings.Split(data.DetailProductTags, ",")
 	for _, tagID := range explodedTags {
 		tagIDNum := utils.StrToUint64(tagID, 0)
@@ -202,10 +249,301 @@ func UpdateInitialProduct(data datastruct.CreateInitialProductInput, productID u
 		return http.StatusInternalServerError, err
 	}
 
+	invalidateSimilarProducts()
+
 	return
 }
 
-func CreateMerchantProduct(data datastruct.CreateMerchantProductInput) (statusCode int, err error) {
+// PatchInitialProduct applies only the changes present in data: scalar fields,
+// image additions, removals and ordering, and individual tag changes.
+func PatchInitialProduct(data datastruct.PatchInitialProductInput, productID uint64) (statusCode int, err error) {
+	statusCode = http.StatusOK
+
+	var (
+		db             = Database()
+		currentTime    = time.Now()
+		product        datastruct.Product
+		newImagesURL   []string
+		detailVariants []datastruct.DetailProductVariant
+		addTags        []uint64
+		removeTags     []uint64
+		updates        = make(map[string]interface{})
+	)
+
+	if data.Name != nil {
+		if strings.TrimSpace(*data.Name) == "" {
+			return http.StatusBadRequest, errors.New("name cannot be empty")
+		}
+		updates["name"] = *data.Name
+	}
+	if data.Description != nil {
+		updates["description"] = *data.Description
+	}
+	if data.LinkExternal != nil {
+		updates["link_external"] = *data.LinkExternal
+	}
+	if data.CategoryID != nil {
+		if *data.CategoryID == 0 {
+			return http.StatusBadRequest, errors.New("invalid category id")
+		}
+		updates["category_id"] = *data.CategoryID
+	}
+	if data.BrandID != nil {
+		if *data.BrandID == 0 {
+			return http.StatusBadRequest, errors.New("invalid brand id")
+		}
+		updates["brand_id"] = *data.BrandID
+	}
+
+	if data.DetailProductVariants != nil {
+		if err = json.Unmarshal([]byte(*data.DetailProductVariants), &detailVariants); err != nil {
+			return http.StatusBadRequest, errors.New("failed to parse variants")
+		}
+	}
+
+	if addTags, err = parseTagIDs(data.AddTags); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if removeTags, err = parseTagIDs(data.RemoveTags); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if err = db.Where("id = ? AND merchant_id = 0", productID).First(&product).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return http.StatusNotFound, errors.New("product not found")
+		}
+		return http.StatusInternalServerError, err
+	}
+
+	// attributes are checked against the target category, so a category change
+	// revalidates the stored attributes when new ones are not sent
+	if data.Attributes != nil || data.CategoryID != nil {
+		categoryID := product.CategoryID
+		if data.CategoryID != nil {
+			categoryID = *data.CategoryID
+		}
+
+		rawAttributes := ""
+		if data.Attributes != nil {
+			rawAttributes = *data.Attributes
+		} else if product.Attributes != nil {
+			rawAttributes = *product.Attributes
+		}
+
+		attributes, attributesStatusCode, err := ValidateProductAttributes(db, categoryID, rawAttributes)
+		if err != nil {
+			return attributesStatusCode, err
+		}
+		updates["attributes"] = attributes
+	}
+
+	// upload before the transaction so slow storage does not hold the row lock;
+	// objects left behind by a failed patch are reclaimed by the asset sweeper
+	for _, img := range data.Images {
+		url, err := UploadImage(img, constant.AssetKindProduct)
+		if err != nil {
+			return ImageStatusCode(err), err
+		}
+		newImagesURL = append(newImagesURL, url)
+	}
+
+	// Begin a transaction
+	tx := db.Begin()
+
+	// Defer the rollback function in case of error
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	statusCode, err = patchInitialProductTx(tx, data, productID, newImagesURL, hashProductImages(data.Images), detailVariants, addTags, removeTags, updates, currentTime)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	// Commit the transaction if all queries succeed
+	if err = tx.Commit().Error; err != nil {
+		tx.Rollback()
+		log.Println("Error committing transaction:", err)
+		return http.StatusInternalServerError, err
+	}
+
+	invalidateSimilarProducts()
+
+	return http.StatusOK, nil
+}
+
+// patchInitialProductTx applies the patch inside tx. The caller rolls tx back
+// when it returns an error.
+func patchInitialProductTx(tx *gorm.DB, data datastruct.PatchInitialProductInput, productID uint64, newImagesURL []string, newImageHashes map[int]uint64, detailVariants []datastruct.DetailProductVariant, addTags, removeTags []uint64, updates map[string]interface{}, currentTime time.Time) (statusCode int, err error) {
+	var product datastruct.Product
+
+	// lock the row so concurrent patches do not lose each other's image changes
+	if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND merchant_id = 0", productID).
+		First(&product).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return http.StatusNotFound, errors.New("product not found")
+		}
+		return http.StatusInternalServerError, err
+	}
+
+	if data.RemoveImages != "" || data.ImageOrder != "" || len(newImagesURL) > 0 {
+		images, err := patchImageList(utils.ConvertStringToSlice(product.Images, ","), data.RemoveImages, data.ImageOrder, newImagesURL)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		updates["images"] = strings.Join(images, ",")
+
+		if err = syncProductImageHashes(tx, productID, images, newImagesURL, newImageHashes, currentTime); err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+
+	if len(updates) > 0 {
+		updates["updated_at"] = currentTime
+		if err = tx.Model(&datastruct.Product{}).Where("id = ?", productID).Updates(updates).Error; err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+
+	if len(removeTags) > 0 {
+		if err = tx.Where("product_id = ? AND tag_id IN (?)", productID, removeTags).
+			Delete(&datastruct.DetailProductTag{}).Error; err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+
+	if len(addTags) > 0 {
+		var existingTags []uint64
+		if err = tx.Model(&datastruct.DetailProductTag{}).
+			Where("product_id = ? AND tag_id IN (?)", productID, addTags).
+			Pluck("tag_id", &existingTags).Error; err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		existing := make(map[uint64]bool, len(existingTags))
+		for _, tagID := range existingTags {
+			existing[tagID] = true
+		}
+
+		var productTagsPayload []datastruct.DetailProductTag
+		for _, tagID := range addTags {
+			if existing[tagID] {
+				continue
+			}
+			existing[tagID] = true
+			productTagsPayload = append(productTagsPayload, datastruct.DetailProductTag{
+				TagID:     tagID,
+				ProductID: productID,
+				CreatedAt: currentTime,
+				UpdatedAt: currentTime,
+			})
+		}
+
+		if len(productTagsPayload) > 0 {
+			if err = tx.Create(&productTagsPayload).Error; err != nil {
+				return http.StatusInternalServerError, err
+			}
+		}
+	}
+
+	if data.DetailProductVariants != nil {
+		if err = tx.Where("product_id = ?", productID).Delete(&datastruct.DetailProductVariant{}).Error; err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		for i := range detailVariants {
+			detailVariants[i].ID = 0
+			detailVariants[i].ProductID = productID
+			detailVariants[i].CreatedAt = currentTime
+			detailVariants[i].UpdatedAt = currentTime
+		}
+		if len(detailVariants) > 0 {
+			if err = tx.Create(&detailVariants).Error; err != nil {
+				return http.StatusInternalServerError, err
+			}
+		}
+	}
+
+	return http.StatusOK, nil
+}
+
+// patchImageList removes the given URLs, appends the new uploads and then moves
+// the URLs listed in order to the front. Images not listed keep their relative order.
+func patchImageList(current []string, remove, order string, added []string) (res []string, err error) {
+	existing := make(map[string]bool, len(current))
+	for _, url := range current {
+		existing[strings.TrimSpace(url)] = true
+	}
+
+	removed := make(map[string]bool)
+	for _, url := range utils.ConvertStringToSlice(remove, ",") {
+		url = strings.TrimSpace(url)
+		if !existing[url] {
+			return nil, fmt.Errorf("image %s does not belong to the product", url)
+		}
+		removed[url] = true
+	}
+
+	var images []string
+	for _, url := range current {
+		url = strings.TrimSpace(url)
+		if !removed[url] {
+			images = append(images, url)
+		}
+	}
+	images = append(images, added...)
+
+	present := make(map[string]bool, len(images))
+	for _, url := range images {
+		present[url] = true
+	}
+
+	ordered := make(map[string]bool)
+	for _, url := range utils.ConvertStringToSlice(order, ",") {
+		url = strings.TrimSpace(url)
+		if !present[url] {
+			return nil, fmt.Errorf("cannot order image %s, it does not belong to the product", url)
+		}
+		if ordered[url] {
+			continue
+		}
+		ordered[url] = true
+		res = append(res, url)
+	}
+	for _, url := range images {
+		if !ordered[url] {
+			res = append(res, url)
+		}
+	}
+
+	if len(res) == 0 {
+		return nil, errors.New("product must have at least one image")
+	}
+
+	return
+}
+
+// parseTagIDs parses a comma separated list of tag ids.
+func parseTagIDs(tags string) (res []uint64, err error) {
+	for _, tagID := range utils.ConvertStringToSlice(tags, ",") {
+		tagIDNum := utils.StrToUint64(strings.TrimSpace(tagID), 0)
+		if tagIDNum == 0 {
+			return nil, fmt.Errorf("invalid tag id: %s", tagID)
+		}
+		res = append(res, tagIDNum)
+	}
+
+	return
+}
+
+// CreateMerchantProduct lists an initial product for a merchant. Listings that
+// look like one the merchant already has are only created once confirmed,
+// otherwise they come back as duplicates with a 409.
+func CreateMerchantProduct(data datastruct.CreateMerchantProductInput) (duplicates []datastruct.DuplicateProduct, statusCode int, err error) {
 	statusCode = http.StatusCreated
 
 	var (
@@ -221,28 +559,55 @@ func CreateMerchantProduct(data datastruct.CreateMerchantProductInput) (statusCo
 
 	err = json.Unmarshal([]byte(data.DetailProductMarketplace), &detailMarketplaces)
 	if err != nil {
-		return http.StatusBadRequest, errors.New("failed to parse marketplaces")
+		return nil, http.StatusBadRequest, errors.New("failed to parse marketplaces")
 	}
 
-	for _, img := range data.Images {
-		url, err := UploadImageToGCS(img)
+	// the payload carries the marketplace in id, offline stores have none
+	seenLinks := make(map[string]bool)
+	for i, v := range detailMarketplaces {
+		if v.ID == 0 {
+			continue
+		}
+
+		link, statusCode, err := canonicalizeListingLink(db, v.ID, v.Link, nil, seenLinks)
 		if err != nil {
-			return http.StatusInternalServerError, err
+			return nil, statusCode, err
 		}
-		imagesURL = append(imagesURL, url)
+
+		detailMarketplaces[i].Link = link.Link
+		detailMarketplaces[i].ShopIdentifier = link.ShopIdentifier
+		detailMarketplaces[i].ProductIdentifier = link.ProductIdentifier
 	}
 
 	// select initial products
 	if err = db.Table("products").
 		Select("*").
+		Where("id = ? AND merchant_id = 0", data.ProductID).
 		Find(&initialProduct).
-		Where("id = ?", data.ProductID).
 		Error; err != nil {
-		return http.StatusInternalServerError, err
+		return nil, http.StatusInternalServerError, err
 	}
 
 	if initialProduct.ID == 0 {
-		return http.StatusNotFound, errors.New("initial products not found")
+		return nil, http.StatusNotFound, errors.New("initial products not found")
+	}
+
+	imageHashes := hashProductImages(data.Images)
+	if !data.ConfirmDuplicate {
+		if duplicates, err = findDuplicateProducts(db, data.MerchantID, initialProduct.BrandID, initialProduct.CategoryID, data.Name, imageHashes); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		if len(duplicates) > 0 {
+			return duplicates, http.StatusConflict, errors.New("you already have similar products, send confirm_duplicate=true to create it anyway")
+		}
+	}
+
+	for _, img := range data.Images {
+		url, err := UploadImage(img, constant.AssetKindProduct)
+		if err != nil {
+			return nil, ImageStatusCode(err), err
+		}
+		imagesURL = append(imagesURL, url)
 	}
 
 	// Begin a transaction
@@ -266,13 +631,24 @@ func CreateMerchantProduct(data datastruct.CreateMerchantProductInput) (statusCo
 		BrandID:      initialProduct.BrandID,
 		MerchantID:   data.MerchantID,
 		Price:        data.Price,
+		Attributes:   initialProduct.Attributes,
 		Status:       constant.StatusWaiting,
 		CreatedAt:    currentTime,
 		UpdatedAt:    currentTime,
 	}
 
 	if err = tx.Create(&initialProduct).Error; err != nil {
-		return http.StatusInternalServerError, err
+		return nil, http.StatusInternalServerError, err
+	}
+
+	if err = recordPriceChange(tx, initialProduct.ID, nil, initialProduct.Price, currentTime); err != nil {
+		tx.Rollback()
+		return nil, http.StatusInternalServerError, err
+	}
+
+	if err = syncProductImageHashes(tx, initialProduct.ID, imagesURL, imagesURL, imageHashes, currentTime); err != nil {
+		tx.Rollback()
+		return nil, http.StatusInternalServerError, err
 	}
 
 	// select initial products variants
@@ -281,11 +657,11 @@ func CreateMerchantProduct(data datastruct.CreateMerchantProductInput) (statusCo
 		Where("product_id = ?", data.ProductID).
 		Find(&initialProductVariant).
 		Error; err != nil {
-		return http.StatusInternalServerError, err
+		return nil, http.StatusInternalServerError, err
 	}
 
 	if len(initialProductVariant) == 0 {
-		return http.StatusNotFound, errors.New("initial product variants not found")
+		return nil, http.StatusNotFound, errors.New("initial product variants not found")
 	}
 
 	for i, variant := range initialProductVariant {
@@ -301,7 +677,7 @@ func CreateMerchantProduct(data datastruct.CreateMerchantProductInput) (statusCo
 	}
 
 	if err = tx.Create(&initialProductVariant).Error; err != nil {
-		return http.StatusInternalServerError, err
+		return nil, http.StatusInternalServerError, err
 	}
 
 	// select initial product tags
@@ -310,11 +686,11 @@ func CreateMerchantProduct(data datastruct.CreateMerchantProductInput) (statusCo
 		Where("product_id = ?", data.ProductID).
 		Find(&initialProductTag).
 		Error; err != nil {
-		return http.StatusInternalServerError, err
+		return nil, http.StatusInternalServerError, err
 	}
 
 	if len(initialProductTag) == 0 {
-		return http.StatusNotFound, errors.New("initial product tags not found")
+		return nil, http.StatusNotFound, errors.New("initial product tags not found")
 	}
 
 	for i, tag := range initialProductTag {
@@ -328,24 +704,26 @@ func CreateMerchantProduct(data datastruct.CreateMerchantProductInput) (statusCo
 	}
 
 	if err = tx.Create(&initialProductTag).Error; err != nil {
-		return http.StatusInternalServerError, err
+		return nil, http.StatusInternalServerError, err
 	}
 
 	for i, marketplace := range detailMarketplaces {
 		detailMarketplaces[i] = datastruct.DetailProductMarketplace{
-			MarketplaceID:   marketplace.ID,
-			ParentProductID: data.ProductID,
-			ProductID:       initialProduct.ID,
-			AddressID:       marketplace.AddressID,
-			Link:            marketplace.Link,
-			Clicked:         0,
-			CreatedAt:       currentTime,
-			UpdatedAt:       currentTime,
+			MarketplaceID:     marketplace.ID,
+			ParentProductID:   data.ProductID,
+			ProductID:         initialProduct.ID,
+			AddressID:         marketplace.AddressID,
+			Link:              marketplace.Link,
+			ShopIdentifier:    marketplace.ShopIdentifier,
+			ProductIdentifier: marketplace.ProductIdentifier,
+			Clicked:           0,
+			CreatedAt:         currentTime,
+			UpdatedAt:         currentTime,
 		}
 	}
 
 	if err = tx.Create(&detailMarketplaces).Error; err != nil {
-		return http.StatusInternalServerError, err
+		return nil, http.StatusInternalServerError, err
 	}
 
 	linkedProduct = datastruct.DetailLinkedProduct{
@@ -357,16 +735,18 @@ func CreateMerchantProduct(data datastruct.CreateMerchantProductInput) (statusCo
 	}
 
 	if err = tx.Create(&linkedProduct).Error; err != nil {
-		return http.StatusInternalServerError, err
+		return nil, http.StatusInternalServerError, err
 	}
 
 	// Commit the transaction if all queries succeed
 	if err = tx.Commit().Error; err != nil {
 		tx.Rollback()
 		log.Println("Error committing transaction:", err)
-		return http.StatusInternalServerError, err
+		return nil, http.StatusInternalServerError, err
 	}
 
+	invalidateSimilarProducts()
+
 	return
 }
 
@@ -387,6 +767,7 @@ func GetInitialProductByCategoryID(categoryID uint64) (res []datastruct.InitialP
 			"p.description",
 			"p.images",
 			"p.link_external",
+			"p.attributes",
 			"c.name as category_name",
 			"b.name as brand_name",
 		}).
@@ -425,10 +806,13 @@ func GetInitialProductByCategoryID(categoryID uint64) (res []datastruct.InitialP
 	}
 
 	for _, product := range products {
+		images := strings.Split(product.Images, ",")
 		res = append(res, datastruct.InitialProductResponse{
-			InitialProduct: product,
-			Images:         strings.Split(product.Images, ","),
-			Variants:       productVariantMap[product.ID],
+			InitialProduct:  product,
+			Images:          images,
+			ImageRenditions: GetImageRenditionsList(images),
+			Variants:        productVariantMap[product.ID],
+			Attributes:      parseProductAttributes(product.RawAttributes),
 		})
 	}
 
@@ -451,6 +835,7 @@ func GetInitialProductByID(productID uint64) (res datastruct.InitialProductRespo
 			"p.description",
 			"p.images",
 			"p.link_external",
+			"p.attributes",
 			"p.status",
 			"p.is_subscription_active",
 			"p.rejected_note",
@@ -528,14 +913,16 @@ func GetInitialProductByID(productID uint64) (res datastruct.InitialProductRespo
 
 			if v.MarketplaceID != 0 {
 				merchantProduct[i].Type = "online"
-				marketplaceName := constant.Marketplace[v.MarketplaceID]
-				merchantProduct[i].Marketplace = &marketplaceName
+				if marketplace, ok := GetMarketplaceByID(v.MarketplaceID); ok {
+					merchantProduct[i].Marketplace = &marketplace.Name
+					merchantProduct[i].MarketplaceLogo = &marketplace.Image
+					merchantProduct[i].DeepLink = MarketplaceDeepLink(marketplace, merchantProduct[i].MarketplaceLink)
+				}
 			}
 		}
 	}
 
 	var tagIDs []uint64
-	var tags []string
 	if err := db.Table("detail_product_tags").
 		Select([]string{
 			"tag_id",
@@ -545,21 +932,11 @@ func GetInitialProductByID(productID uint64) (res datastruct.InitialProductRespo
 		Error; err != nil {
 		return res, http.StatusInternalServerError, err
 	}
+	tags := GetTagLabels(tagIDs)
 
-	for _, v := range tagIDs {
-		tags = append(tags, constant.GetTagNameByDetailTag[v]...)
-	}
-
-	response, err := utils.FetchMachineLearningAPI("GET", "/product_recommendation", nil)
+	productsMLIDs, err := mlclient.Default().ProductRecommendation(context.Background())
 	if err != nil {
-		statusCode = http.StatusInternalServerError
-		return
-	}
-
-	var productsMLIDs map[string][]string
-	err = json.Unmarshal(response, &productsMLIDs)
-	if err != nil {
-		fmt.Println("Error parsing JSON:", err)
+		statusCode = mlclient.StatusCode(err)
 		return
 	}
 
@@ -589,13 +966,16 @@ func GetInitialProductByID(productID uint64) (res datastruct.InitialProductRespo
 		}
 	}
 
+	images := strings.Split(products.Images, ",")
 	res = datastruct.InitialProductResponse{
 		InitialProduct:        products,
-		Images:                strings.Split(products.Images, ","),
+		Images:                images,
+		ImageRenditions:       GetImageRenditionsList(images),
 		Variants:              productVariants,
 		ListMarketplace:       merchantProduct,
-		Tags:                  utils.RemoveDuplicates(tags),
+		Tags:                  tags,
 		RecommendationProduct: recommendationProduct,
+		Attributes:            parseProductAttributes(products.RawAttributes),
 	}
 	return
 }
@@ -630,16 +1010,29 @@ func GetMarketplaceProductByID(productID, userID uint64) (merchantProduct datast
 	}
 
 	merchantProduct.Images = strings.Split(merchantProduct.Image, ",")
+	merchantProduct.ImageRenditions = GetImageRenditionsList(merchantProduct.Images)
 	if merchantProduct.AddressID != 0 {
 		merchantProduct.Type = "offline"
 		addr, _, _, err := GetAddressByID(merchantProduct.AddressID)
 		if err == nil {
 			merchantProduct.Address = &addr
 		}
+
+		if status, ok := getListingStockStatuses(db, []uint64{merchantProduct.ID})[merchantProduct.ID]; ok {
+			stocks, err := getListingVariantStocks(db, merchantProduct.ID, merchantProduct.ProductID)
+			if err != nil {
+				return merchantProduct, http.StatusInternalServerError, err
+			}
+			merchantProduct.StockStatus = &status
+			merchantProduct.Stocks = stocks
+		}
 	} else if merchantProduct.MarketplaceID != 0 {
 		merchantProduct.Type = "online"
-		marketplaceName := constant.Marketplace[merchantProduct.MarketplaceID]
-		merchantProduct.Marketplace = &marketplaceName
+		if marketplace, ok := GetMarketplaceByID(merchantProduct.MarketplaceID); ok {
+			merchantProduct.Marketplace = &marketplace.Name
+			merchantProduct.MarketplaceLogo = &marketplace.Image
+			merchantProduct.DeepLink = MarketplaceDeepLink(marketplace, merchantProduct.MarketplaceLink)
+		}
 	}
 
 	if err = db.Table("detail_product_marketplaces").
@@ -773,22 +1166,393 @@ func VerifyMerchantProduct(data datastruct.VerifyProductInput) (statusCode int,
 		return http.StatusInternalServerError, err
 	}
 
+	invalidateSimilarProducts()
 	return
 }
 
 func UpdateMerchantProduct(data datastruct.UpdateProductInput) (statusCode int, err error) {
 	db := Database()
 	statusCode = http.StatusOK
+	currentTime := time.Now()
 
-	if err = db.Model(&datastruct.Product{}).
+	var product datastruct.Product
+	if err = db.Where("id = ?", data.ProductID).First(&product).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return http.StatusNotFound, errors.New("product not found")
+		}
+		return http.StatusInternalServerError, err
+	}
+
+	// Begin a transaction
+	tx := db.Begin()
+
+	// Defer the rollback function in case of error
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = tx.Model(&datastruct.Product{}).
 		Where("id = ?", data.ProductID).
 		Updates(map[string]interface{}{
 			"price":       data.Price,
 			"description": data.Description,
-			"updated_at":  time.Now(),
+			"updated_at":  currentTime,
 		}).Error; err != nil {
+		tx.Rollback()
+		return http.StatusInternalServerError, err
+	}
+
+	if err = recordPriceChange(tx, product.ID, &product.Price, data.Price, currentTime); err != nil {
+		tx.Rollback()
+		return http.StatusInternalServerError, err
+	}
+
+	// Commit the transaction if all queries succeed
+	if err = tx.Commit().Error; err != nil {
+		tx.Rollback()
+		log.Println("Error committing transaction:", err)
+		return http.StatusInternalServerError, err
+	}
+
+	invalidateSimilarProducts()
+
+	return
+}
+
+// EditMerchantProduct updates a merchant product owned by the user. Changing the
+// name, images or listings sends the product back to the admin review queue.
+func EditMerchantProduct(data datastruct.EditMerchantProductInput, productID, userID uint64) (res datastruct.EditMerchantProductResponse, statusCode int, err error) {
+	statusCode = http.StatusOK
+
+	var (
+		db                 = Database()
+		currentTime        = time.Now()
+		product            datastruct.Product
+		merchantID         uint64
+		imagesURL          []string
+		upsertListings     []datastruct.MerchantListingInput
+		removeListings     []uint64
+		updates            = make(map[string]interface{})
+		significantChanged bool
+	)
+
+	if merchantID, statusCode, err = getUserMerchantID(db, userID); err != nil {
+		return
+	}
+
+	if err = db.Where("id = ? AND merchant_id = ?", productID, merchantID).First(&product).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res, http.StatusNotFound, errors.New("product not found")
+		}
+		return res, http.StatusInternalServerError, err
+	}
+
+	if data.Name != nil {
+		if strings.TrimSpace(*data.Name) == "" {
+			return res, http.StatusBadRequest, errors.New("name cannot be empty")
+		}
+		if *data.Name != product.Name {
+			significantChanged = true
+		}
+		updates["name"] = *data.Name
+	}
+	if data.Description != nil {
+		updates["description"] = *data.Description
+	}
+	if data.Price != nil {
+		if *data.Price <= 0 {
+			return res, http.StatusBadRequest, errors.New("price must be greater than 0")
+		}
+		updates["price"] = *data.Price
+	}
+
+	if data.UpsertMarketplaces != "" {
+		if err = json.Unmarshal([]byte(data.UpsertMarketplaces), &upsertListings); err != nil {
+			return res, http.StatusBadRequest, errors.New("failed to parse marketplaces")
+		}
+	}
+	for _, listingID := range utils.ConvertStringToSlice(data.RemoveMarketplaces, ",") {
+		listingIDNum := utils.StrToUint64(strings.TrimSpace(listingID), 0)
+		if listingIDNum == 0 {
+			return res, http.StatusBadRequest, fmt.Errorf("invalid marketplace listing id: %s", listingID)
+		}
+		removeListings = append(removeListings, listingIDNum)
+	}
+
+	if statusCode, err = validateMerchantListings(db, merchantID, upsertListings, removeListings); err != nil {
+		return
+	}
+
+	for _, img := range data.Images {
+		url, err := UploadImage(img, constant.AssetKindProduct)
+		if err != nil {
+			return res, ImageStatusCode(err), err
+		}
+		imagesURL = append(imagesURL, url)
+	}
+	if len(imagesURL) > 0 {
+		updates["images"] = strings.Join(imagesURL, ",")
+		significantChanged = true
+	}
+
+	// Begin a transaction
+	tx := db.Begin()
+
+	// Defer the rollback function in case of error
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	listingsChanged, statusCode, err := saveMerchantListings(tx, product, upsertListings, removeListings, currentTime)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	if len(imagesURL) > 0 {
+		if err = syncProductImageHashes(tx, product.ID, imagesURL, imagesURL, hashProductImages(data.Images), currentTime); err != nil {
+			tx.Rollback()
+			return res, http.StatusInternalServerError, err
+		}
+	}
+	if listingsChanged {
+		significantChanged = true
+	}
+
+	res.Status = product.Status
+	if significantChanged && product.Status != constant.StatusWaiting {
+		updates["status"] = constant.StatusWaiting
+		updates["rejected_note"] = ""
+		res.Status = constant.StatusWaiting
+	}
+
+	if len(updates) > 0 {
+		updates["updated_at"] = currentTime
+		if err = tx.Model(&datastruct.Product{}).Where("id = ?", product.ID).Updates(updates).Error; err != nil {
+			tx.Rollback()
+			return res, http.StatusInternalServerError, err
+		}
+	}
+
+	if data.Price != nil {
+		if err = recordPriceChange(tx, product.ID, &product.Price, *data.Price, currentTime); err != nil {
+			tx.Rollback()
+			return res, http.StatusInternalServerError, err
+		}
+	}
+
+	// Commit the transaction if all queries succeed
+	if err = tx.Commit().Error; err != nil {
+		tx.Rollback()
+		log.Println("Error committing transaction:", err)
+		return res, http.StatusInternalServerError, err
+	}
+
+	invalidateSimilarProducts()
+
+	res.ID = product.ID
+	res.InReview = res.Status == constant.StatusWaiting
+	return res, http.StatusOK, nil
+}
+
+// validateMerchantListings checks that online listings point to a known
+// marketplace with a valid, not yet listed link, which it replaces by its
+// canonical form, and that offline listings carry a new store address or one
+// the merchant already owns. Listings in remove are about to go and are not
+// counted as duplicates.
+func validateMerchantListings(db *gorm.DB, merchantID uint64, listings []datastruct.MerchantListingInput, remove []uint64) (statusCode int, err error) {
+	statusCode = http.StatusOK
+
+	var addressIDs []uint64
+	seen := make(map[string]bool)
+	for i, listing := range listings {
+		if listing.MarketplaceID == 0 {
+			if listing.Address == nil && listing.AddressID == 0 {
+				return http.StatusBadRequest, errors.New("offline store listing must have an address")
+			}
+			if listing.Address != nil {
+				if validationErrors := utils.ValidateStruct(*listing.Address); len(validationErrors) > 0 {
+					return http.StatusBadRequest, errors.New("offline store address is not valid")
+				}
+			} else {
+				addressIDs = append(addressIDs, listing.AddressID)
+			}
+			continue
+		}
+
+		excludeIDs := remove
+		if listing.ID != 0 {
+			excludeIDs = append([]uint64{listing.ID}, remove...)
+		}
+
+		link, statusCode, err := canonicalizeListingLink(db, listing.MarketplaceID, listing.Link, excludeIDs, seen)
+		if err != nil {
+			return statusCode, err
+		}
+
+		listings[i].Link = link.Link
+		listings[i].ShopIdentifier = link.ShopIdentifier
+		listings[i].ProductIdentifier = link.ProductIdentifier
+	}
+
+	return checkMerchantAddresses(db, merchantID, addressIDs)
+}
+
+// checkMerchantAddresses makes sure every address is the merchant's own: the
+// address of one of its users or of one of its existing offline listings.
+func checkMerchantAddresses(db *gorm.DB, merchantID uint64, addressIDs []uint64) (statusCode int, err error) {
+	if len(addressIDs) == 0 {
+		return http.StatusOK, nil
+	}
+
+	var userAddressIDs, listingAddressIDs []uint64
+	if err = db.Table("users").
+		Where("merchant_id = ? AND addresses_id IN (?)", merchantID, addressIDs).
+		Pluck("addresses_id", &userAddressIDs).Error; err != nil {
 		return http.StatusInternalServerError, err
 	}
+	if err = db.Table("detail_product_marketplaces dpm").
+		Joins("JOIN products p ON p.id = dpm.product_id").
+		Where("p.merchant_id = ? AND dpm.addresses_id IN (?)", merchantID, addressIDs).
+		Pluck("dpm.addresses_id", &listingAddressIDs).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	owned := make(map[uint64]bool, len(userAddressIDs)+len(listingAddressIDs))
+	for _, addressID := range append(userAddressIDs, listingAddressIDs...) {
+		owned[addressID] = true
+	}
+	for _, addressID := range addressIDs {
+		if !owned[addressID] {
+			return http.StatusForbidden, fmt.Errorf("address %d does not belong to the merchant", addressID)
+		}
+	}
+
+	return http.StatusOK, nil
+}
+
+// saveMerchantListings removes, updates and adds the product marketplace and
+// offline store listings, reporting whether anything changed.
+func saveMerchantListings(tx *gorm.DB, product datastruct.Product, upsert []datastruct.MerchantListingInput, remove []uint64, currentTime time.Time) (changed bool, statusCode int, err error) {
+	statusCode = http.StatusOK
+
+	var existingListings []datastruct.DetailProductMarketplace
+	if err = tx.Where("product_id = ?", product.ID).Find(&existingListings).Error; err != nil {
+		return false, http.StatusInternalServerError, err
+	}
+
+	existing := make(map[uint64]datastruct.DetailProductMarketplace, len(existingListings))
+	for _, v := range existingListings {
+		existing[v.ID] = v
+	}
+
+	if len(remove) > 0 {
+		for _, listingID := range remove {
+			if _, ok := existing[listingID]; !ok {
+				return false, http.StatusBadRequest, fmt.Errorf("marketplace listing %d does not belong to the product", listingID)
+			}
+		}
+
+		if err = tx.Where("detail_product_marketplace_id IN (?)", remove).Delete(&datastruct.Wishlist{}).Error; err != nil {
+			return false, http.StatusInternalServerError, err
+		}
+		if err = tx.Where("detail_product_marketplace_id IN (?)", remove).Delete(&datastruct.ProductStock{}).Error; err != nil {
+			return false, http.StatusInternalServerError, err
+		}
+		if err = tx.Where("id IN (?)", remove).Delete(&datastruct.DetailProductMarketplace{}).Error; err != nil {
+			return false, http.StatusInternalServerError, err
+		}
+		changed = true
+	}
+
+	// parent product is the initial product the merchant product was created from
+	var parentProductID uint64
+	if len(existingListings) > 0 {
+		parentProductID = existingListings[0].ParentProductID
+	}
+	if parentProductID == 0 {
+		if err = tx.Table("detail_linked_products").
+			Select("initial_product_id").
+			Where("merchant_product_id = ?", product.ID).
+			Limit(1).
+			Scan(&parentProductID).Error; err != nil {
+			return false, http.StatusInternalServerError, err
+		}
+	}
+
+	for _, listing := range upsert {
+		addressID := listing.AddressID
+		if listing.MarketplaceID == 0 && listing.Address != nil {
+			addressPayload := datastruct.Address{
+				Street:        listing.Address.Street,
+				ProvinceID:    listing.Address.ProvinceID,
+				CityID:        listing.Address.CityID,
+				DistrictID:    listing.Address.DistrictID,
+				SubdistrictID: listing.Address.SubdistrictID,
+				PostalCodeID:  listing.Address.PostalCodeID,
+				Latitude:      listing.Address.Latitude,
+				Longitude:     listing.Address.Longitude,
+				CreatedAt:     currentTime,
+				UpdatedAt:     currentTime,
+			}
+			if err = tx.Create(&addressPayload).Error; err != nil {
+				return false, http.StatusInternalServerError, err
+			}
+			addressID = addressPayload.ID
+		}
+
+		link, shopIdentifier, productIdentifier := listing.Link, listing.ShopIdentifier, listing.ProductIdentifier
+		if listing.MarketplaceID == 0 {
+			link, shopIdentifier, productIdentifier = "", "", ""
+		} else {
+			addressID = 0
+		}
+
+		if listing.ID == 0 {
+			payload := datastruct.DetailProductMarketplace{
+				MarketplaceID:     listing.MarketplaceID,
+				ParentProductID:   parentProductID,
+				ProductID:         product.ID,
+				AddressID:         addressID,
+				Link:              link,
+				ShopIdentifier:    shopIdentifier,
+				ProductIdentifier: productIdentifier,
+				CreatedAt:         currentTime,
+				UpdatedAt:         currentTime,
+			}
+			if err = tx.Create(&payload).Error; err != nil {
+				return false, http.StatusInternalServerError, err
+			}
+			changed = true
+			continue
+		}
+
+		current, ok := existing[listing.ID]
+		if !ok {
+			return false, http.StatusBadRequest, fmt.Errorf("marketplace listing %d does not belong to the product", listing.ID)
+		}
+		if current.MarketplaceID == listing.MarketplaceID && current.Link == link && current.AddressID == addressID {
+			continue
+		}
+
+		if err = tx.Model(&datastruct.DetailProductMarketplace{}).
+			Where("id = ?", listing.ID).
+			Updates(map[string]interface{}{
+				"marketplace_id":     listing.MarketplaceID,
+				"addresses_id":       addressID,
+				"link":               link,
+				"shop_identifier":    shopIdentifier,
+				"product_identifier": productIdentifier,
+				"updated_at":         currentTime,
+			}).Error; err != nil {
+			return false, http.StatusInternalServerError, err
+		}
+		changed = true
+	}
 
 	return
 }
@@ -801,18 +1565,28 @@ func DeleteProduct(id uint64) (statusCode int, err error) {
 	// Delete records from detail_product_tags
 	db.Exec("DELETE FROM detail_product_tags WHERE product_id = ?", id)
 
+	// Delete records from detail_product_stocks
+	db.Exec("DELETE FROM detail_product_stocks WHERE detail_product_marketplace_id IN (SELECT id FROM detail_product_marketplaces WHERE product_id = ?)", id)
+
 	// Delete records from detail_product_marketplaces
 	db.Exec("DELETE FROM detail_product_marketplaces WHERE product_id = ?", id)
 
 	// Delete records from wishlists
 	db.Exec("DELETE FROM wishlists WHERE product_id = ?", id)
 
+	// Delete records from product_image_hashes
+	db.Exec("DELETE FROM product_image_hashes WHERE product_id = ?", id)
+
+	// Delete records from price_histories
+	db.Exec("DELETE FROM price_histories WHERE product_id = ?", id)
+
 	// Delete records from detail_linked_products
 	db.Exec("DELETE FROM detail_linked_products WHERE merchant_product_id = ?", id)
 
 	// Delete record from products
 	db.Exec("DELETE FROM products WHERE id = ?", id)
 
+	invalidateSimilarProducts()
 	return
 }
 
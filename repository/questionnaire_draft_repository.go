@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/yusufwib/arvigo-backend/datastruct"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Answers can be saved one or a few at a time and are kept per user, so the
+// questionnaire can be continued on another device. Drafts only count towards
+// the active version; answers left from an older version are dropped on the
+// next save.
+
+// GetQuestionnaireProgress returns the saved answers of the user and what is
+// still missing from the active questionnaire.
+func GetQuestionnaireProgress(userID uint64) (res datastruct.QuestionnaireProgress, statusCode int, err error) {
+	db := Database()
+
+	version, statusCode, err := getQuestionnaireVersion(db, 0)
+	if err != nil {
+		return
+	}
+
+	questions, err := getQuestionnaireQuestions(db, version.ID)
+	if err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	answers, updatedAt, err := getDraftAnswers(db, userID, questions)
+	if err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	return questionnaireProgress(version.ID, questions, answers, updatedAt), http.StatusOK, nil
+}
+
+// SaveQuestionnaireAnswers adds the given answers to the user's draft,
+// replacing earlier answers to the same questions.
+func SaveQuestionnaireAnswers(userID uint64, data datastruct.QuestionnaireRequest) (res datastruct.QuestionnaireProgress, statusCode int, err error) {
+	db := Database()
+	currentTime := time.Now()
+
+	version, statusCode, err := getQuestionnaireVersion(db, data.QuestionnaireVersionID)
+	if err != nil {
+		return
+	}
+	if !version.IsActive {
+		return res, http.StatusConflict, errors.New("the questionnaire has changed, please answer the current version")
+	}
+	if len(data.Answers) == 0 {
+		return res, http.StatusBadRequest, errors.New("answers are required")
+	}
+
+	questions, err := getQuestionnaireQuestions(db, version.ID)
+	if err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	if err = checkQuestionnaireAnswers(questions, data.Answers, false); err != nil {
+		return res, http.StatusBadRequest, err
+	}
+
+	drafts := make([]datastruct.QuestionnaireDraftAnswer, 0, len(data.Answers))
+	questionIDs := make([]uint64, 0, len(questions))
+	for _, question := range questions {
+		questionIDs = append(questionIDs, question.ID)
+		if answer, ok := data.Answers[question.Type]; ok {
+			drafts = append(drafts, datastruct.QuestionnaireDraftAnswer{
+				UserID:          userID,
+				QuestionnaireID: question.ID,
+				Answer:          answer,
+				CreatedAt:       currentTime,
+				UpdatedAt:       currentTime,
+			})
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND questionnaire_id NOT IN (?)", userID, questionIDs).
+			Delete(&datastruct.QuestionnaireDraftAnswer{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "questionnaire_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"answer", "updated_at"}),
+		}).Create(&drafts).Error
+	})
+	if err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	answers, updatedAt, err := getDraftAnswers(db, userID, questions)
+	if err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	return questionnaireProgress(version.ID, questions, answers, updatedAt), http.StatusOK, nil
+}
+
+// DeleteQuestionnaireAnswers discards the user's draft to start over.
+func DeleteQuestionnaireAnswers(userID uint64) (statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	if err = db.Where("user_id = ?", userID).Delete(&datastruct.QuestionnaireDraftAnswer{}).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return
+}
+
+// getDraftAnswers returns the user's saved answers to the given questions,
+// keyed by question type, and when they were last changed.
+func getDraftAnswers(db *gorm.DB, userID uint64, questions []datastruct.Questionnaire) (answers map[string]int, updatedAt *time.Time, err error) {
+	answers = map[string]int{}
+	if len(questions) == 0 {
+		return
+	}
+
+	typeByID := make(map[uint64]string, len(questions))
+	questionIDs := make([]uint64, 0, len(questions))
+	for _, question := range questions {
+		typeByID[question.ID] = question.Type
+		questionIDs = append(questionIDs, question.ID)
+	}
+
+	var drafts []datastruct.QuestionnaireDraftAnswer
+	if err = db.Where("user_id = ? AND questionnaire_id IN (?)", userID, questionIDs).
+		Find(&drafts).Error; err != nil {
+		return
+	}
+
+	for _, v := range drafts {
+		answers[typeByID[v.QuestionnaireID]] = v.Answer
+		if updatedAt == nil || v.UpdatedAt.After(*updatedAt) {
+			draftUpdatedAt := v.UpdatedAt
+			updatedAt = &draftUpdatedAt
+		}
+	}
+
+	return
+}
+
+func questionnaireProgress(versionID uint64, questions []datastruct.Questionnaire, answers map[string]int, updatedAt *time.Time) datastruct.QuestionnaireProgress {
+	res := datastruct.QuestionnaireProgress{
+		QuestionnaireVersionID: versionID,
+		Answered:               len(answers),
+		Total:                  len(questions),
+		Answers:                answers,
+		Missing:                []string{},
+		UpdatedAt:              updatedAt,
+	}
+
+	for _, question := range questions {
+		if _, ok := answers[question.Type]; !ok {
+			res.Missing = append(res.Missing, question.Type)
+		}
+	}
+
+	if res.Total > 0 {
+		res.Percentage = math.Round(float64(res.Answered)/float64(res.Total)*10000) / 100
+	}
+	res.IsComplete = res.Total > 0 && len(res.Missing) == 0
+
+	return res
+}
@@ -1,132 +1,240 @@
 package repository
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"math"
 	"net/http"
+	"os"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/yusufwib/arvigo-backend/constant"
 	"github.com/yusufwib/arvigo-backend/datastruct"
-	"github.com/yusufwib/arvigo-backend/utils"
+	"github.com/yusufwib/arvigo-backend/pkg/mlclient"
+	"github.com/yusufwib/arvigo-backend/pkg/personality"
+	"gorm.io/gorm"
 )
 
-func GetQuestionnaire() (res []datastruct.Questionnaire, statusCode int, err error) {
+// GetQuestionnaire returns the questions of the active questionnaire version in
+// the given language. Questions not translated to it use the default language.
+func GetQuestionnaire(language string) (res []datastruct.Questionnaire, statusCode int, err error) {
 	db := Database()
 	statusCode = http.StatusOK
 
-	if err = db.Find(&res).Error; err != nil {
+	version, statusCode, err := getQuestionnaireVersion(db, 0)
+	if err != nil {
+		return
+	}
+
+	language = normalizeQuestionnaireLanguage(language)
+	if err = db.Table("personality_questionnaires pq").
+		Select(strings.Join([]string{
+			"pq.*",
+			"COALESCE(t.question, d.question) as question",
+			"COALESCE(t.language, d.language) as language",
+		}, ", ")).
+		Joins("LEFT JOIN personality_questionnaire_translations t ON t.questionnaire_id = pq.id AND t.language = ?", language).
+		Joins("LEFT JOIN personality_questionnaire_translations d ON d.questionnaire_id = pq.id AND d.language = ?", constant.QuestionnaireDefaultLanguage).
+		Where("pq.questionnaire_version_id = ?", version.ID).
+		Order("pq.sort_order ASC, pq.id ASC").
+		Find(&res).Error; err != nil {
 		return res, http.StatusInternalServerError, err
 	}
 
 	return
 }
 
+// GenerateQuestionnaireResult scores the user's answers: those saved as a draft
+// with SaveQuestionnaireAnswers, overridden by the ones sent now. Every question
+// must be answered; the draft is cleared once the result is stored.
 func GenerateQuestionnaireResult(data datastruct.QuestionnaireRequest, userID uint64) (res datastruct.PersonalityPercentages, statusCode int, err error) {
 	db := Database()
 	statusCode = http.StatusOK
 
-	response, err := utils.FetchMachineLearningAPI("POST", "/detect_personality", data)
+	version, statusCode, err := getQuestionnaireVersion(db, data.QuestionnaireVersionID)
 	if err != nil {
-		statusCode = http.StatusInternalServerError
 		return
 	}
+	if !version.IsActive {
+		return res, http.StatusConflict, errors.New("the questionnaire has changed, please answer the current version")
+	}
+
+	questions, err := getQuestionnaireQuestions(db, version.ID)
+	if err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	answers, _, err := getDraftAnswers(db, userID, questions)
+	if err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+	for questionType, answer := range data.Answers {
+		answers[questionType] = answer
+	}
+
+	items, err := personalityItems(questions, answers)
+	if err != nil {
+		return res, http.StatusBadRequest, err
+	}
 
-	err = json.Unmarshal(response, &res)
+	res, statusCode, err = scorePersonality(version.ID, items)
 	if err != nil {
-		fmt.Printf("Error unmarshaling response body: %v", err)
 		return
 	}
 
 	top2 := GetTop2FieldNames(res)
 	var resTagIDs []string
 	for _, v := range top2 {
-		tag := constant.GetPersonalityTag[v]
-		tagSplit := strings.Split(tag, ",")
-		resTagIDs = append(resTagIDs, tagSplit...)
+		tagIDs, err := GetPersonalityTagIDs(v)
+		if err != nil {
+			return res, http.StatusInternalServerError, err
+		}
+		for _, tagID := range tagIDs {
+			resTagIDs = append(resTagIDs, strconv.FormatUint(tagID, 10))
+		}
 	}
 
 	resTagIDsStr := strings.Join(resTagIDs, ",")
+	var modelVersion *string
+	if res.ModelVersion != "" {
+		modelVersion = &res.ModelVersion
+	}
+	currentTime := time.Now()
 	payload := datastruct.UserPersonality{
-		UserID:    userID,
-		IsActive:  1,
-		TagIDs:    resTagIDsStr,
-		ExtResult: res.Extraversion,
-		EstResult: res.Neurotic,
-		AgrResult: res.Agreeable,
-		CsnResult: res.Conscientious,
-		OpnResult: res.Openness,
-		EXT1:      data.EXT1,
-		EXT2:      data.EXT2,
-		EXT3:      data.EXT3,
-		EXT4:      data.EXT4,
-		EXT5:      data.EXT5,
-		EXT6:      data.EXT6,
-		EXT7:      data.EXT7,
-		EXT8:      data.EXT8,
-		EXT9:      data.EXT9,
-		EXT10:     data.EXT10,
-		EST1:      data.EST1,
-		EST2:      data.EST2,
-		EST3:      data.EST3,
-		EST4:      data.EST4,
-		EST5:      data.EST5,
-		EST6:      data.EST6,
-		EST7:      data.EST7,
-		EST8:      data.EST8,
-		EST9:      data.EST9,
-		EST10:     data.EST10,
-		AGR1:      data.AGR1,
-		AGR2:      data.AGR2,
-		AGR3:      data.AGR3,
-		AGR4:      data.AGR4,
-		AGR5:      data.AGR5,
-		AGR6:      data.AGR6,
-		AGR7:      data.AGR7,
-		AGR8:      data.AGR8,
-		AGR9:      data.AGR9,
-		AGR10:     data.AGR10,
-		CSN1:      data.CSN1,
-		CSN2:      data.CSN2,
-		CSN3:      data.CSN3,
-		CSN4:      data.CSN4,
-		CSN5:      data.CSN5,
-		CSN6:      data.CSN6,
-		CSN7:      data.CSN7,
-		CSN8:      data.CSN8,
-		CSN9:      data.CSN9,
-		CSN10:     data.CSN10,
-		OPN1:      data.OPN1,
-		OPN2:      data.OPN2,
-		OPN3:      data.OPN3,
-		OPN4:      data.OPN4,
-		OPN5:      data.OPN5,
-		OPN6:      data.OPN6,
-		OPN7:      data.OPN7,
-		OPN8:      data.OPN8,
-		OPN9:      data.OPN9,
-		OPN10:     data.OPN10,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-
-	if err = db.Table("user_personalities").
-		Create(&payload).Error; err != nil {
+		UserID:                 userID,
+		IsActive:               1,
+		TagIDs:                 resTagIDsStr,
+		QuestionnaireVersionID: version.ID,
+		ExtResult:              res.Extraversion,
+		EstResult:              res.Neurotic,
+		AgrResult:              res.Agreeable,
+		CsnResult:              res.Conscientious,
+		OpnResult:              res.Openness,
+		ModelVersion:           modelVersion,
+		Source:                 res.Source,
+		CreatedAt:              currentTime,
+		UpdatedAt:              currentTime,
+	}
+
+	// Begin a transaction
+	tx := db.Begin()
+
+	// Defer the rollback function in case of error
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = tx.Create(&payload).Error; err != nil {
+		tx.Rollback()
 		return res, http.StatusInternalServerError, err
 	}
 
-	if err = db.Model(&datastruct.User{}).
+	personalityAnswers := make([]datastruct.UserPersonalityAnswer, 0, len(questions))
+	for i, question := range questions {
+		personalityAnswers = append(personalityAnswers, datastruct.UserPersonalityAnswer{
+			UserPersonalityID: payload.ID,
+			QuestionnaireID:   question.ID,
+			Answer:            items[i].Answer,
+			CreatedAt:         currentTime,
+		})
+	}
+	if err = tx.Create(&personalityAnswers).Error; err != nil {
+		tx.Rollback()
+		return res, http.StatusInternalServerError, err
+	}
+
+	if err = tx.Where("user_id = ?", userID).Delete(&datastruct.QuestionnaireDraftAnswer{}).Error; err != nil {
+		tx.Rollback()
+		return res, http.StatusInternalServerError, err
+	}
+
+	if err = tx.Model(&datastruct.User{}).
 		Where("id = ?", userID).
 		Updates(map[string]interface{}{
 			"is_complete_personality_test": 1,
-			"updated_at":                   time.Now(),
+			"updated_at":                   currentTime,
 		}).Error; err != nil {
+		tx.Rollback()
 		return res, http.StatusInternalServerError, err
 	}
 
+	// Commit the transaction if all queries succeed
+	if err = tx.Commit().Error; err != nil {
+		tx.Rollback()
+		log.Println("Error committing transaction:", err)
+		return res, http.StatusInternalServerError, err
+	}
+
+	return
+}
+
+func getQuestionnaireQuestions(db *gorm.DB, versionID uint64) (res []datastruct.Questionnaire, err error) {
+	err = db.Where("questionnaire_version_id = ?", versionID).
+		Order("sort_order ASC, id ASC").
+		Find(&res).Error
+	return
+}
+
+// checkQuestionnaireAnswers validates answers against the questions of a
+// version: each one must belong to a question and be on the 1-5 scale, and
+// with complete set every question must be answered.
+func checkQuestionnaireAnswers(questions []datastruct.Questionnaire, answers map[string]int, complete bool) error {
+	known := make(map[string]bool, len(questions))
+	var missing []string
+	for _, question := range questions {
+		known[question.Type] = true
+		if _, ok := answers[question.Type]; !ok && complete {
+			missing = append(missing, question.Type)
+		}
+	}
+
+	var unknown, invalid []string
+	for questionType, answer := range answers {
+		if !known[questionType] {
+			unknown = append(unknown, questionType)
+		} else if answer < personality.MinAnswer || answer > personality.MaxAnswer {
+			invalid = append(invalid, questionType)
+		}
+	}
+	sort.Strings(unknown)
+	sort.Strings(invalid)
+
+	if len(unknown) > 0 {
+		return fmt.Errorf("not questions of this questionnaire: %s", strings.Join(unknown, ", "))
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("%w: %s", personality.ErrInvalidAnswer, strings.Join(invalid, ", "))
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%d questions are not answered: %s", len(missing), strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// personalityItems pairs every question with its answer, in question order.
+func personalityItems(questions []datastruct.Questionnaire, answers map[string]int) (items []personality.Item, err error) {
+	if err = checkQuestionnaireAnswers(questions, answers, true); err != nil {
+		return nil, err
+	}
+
+	for _, question := range questions {
+		items = append(items, personality.Item{
+			Type:         question.Type,
+			Trait:        question.Trait,
+			ReverseKeyed: question.IsReverseKeyed,
+			Answer:       answers[question.Type],
+		})
+	}
+
 	return
 }
 
@@ -143,6 +251,9 @@ func GetTop2FieldNames(p datastruct.PersonalityPercentages) []string {
 	typeOfP := val.Type()
 	for i := 0; i < val.NumField(); i++ {
 		field := val.Field(i)
+		if field.Kind() != reflect.Float64 {
+			continue
+		}
 		fieldName := typeOfP.Field(i).Name
 		fieldValue := field.Float()
 
@@ -159,3 +270,74 @@ func GetTop2FieldNames(p datastruct.PersonalityPercentages) []string {
 
 	return topFields
 }
+
+// scorePersonality scores the answers as configured by PERSONALITY_SCORING.
+// By default the ML service is used and the local keyed scorer takes over when
+// the service is unavailable; answers the service rejects are not retried locally.
+// Versions whose questions are not the IPIP-50 set are always scored locally.
+func scorePersonality(versionID uint64, items []personality.Item) (res datastruct.PersonalityPercentages, statusCode int, err error) {
+	statusCode = http.StatusOK
+
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("PERSONALITY_SCORING")))
+	if mode == constant.PersonalityScoringLocal || !personality.IsIPIP50(items) {
+		return scorePersonalityLocally(items)
+	}
+
+	answers := make(map[string]int, len(items))
+	for _, item := range items {
+		answers[item.Type] = item.Answer
+	}
+
+	fingerprint := answersFingerprint(versionID, answers)
+	if !getCachedMLResult(mlclient.EndpointDetectPersonality, fingerprint, &res) {
+		res, err = mlclient.Default().DetectPersonality(context.Background(), answers)
+		if err != nil {
+			var mlErr *mlclient.Error
+			canFallback := errors.As(err, &mlErr) && mlErr.Kind != mlclient.KindRejected
+			if mode == constant.PersonalityScoringML || !canFallback {
+				return res, mlclient.StatusCode(err), err
+			}
+
+			log.Println("Personality ML unavailable, scoring locally:", err)
+			return scorePersonalityLocally(items)
+		}
+		cacheMLResult(mlclient.EndpointDetectPersonality, fingerprint, res.ModelVersion, res)
+	}
+	res.Source = constant.PersonalitySourceML
+
+	if mode == constant.PersonalityScoringCompare {
+		if local, err := personality.Score(items); err == nil {
+			log.Printf("Personality scoring comparison (ml %s vs local %s): max trait difference %.2f",
+				res.ModelVersion, local.ModelVersion, maxTraitDifference(res, local))
+		}
+	}
+
+	return
+}
+
+func scorePersonalityLocally(items []personality.Item) (res datastruct.PersonalityPercentages, statusCode int, err error) {
+	res, err = personality.Score(items)
+	if err != nil {
+		if errors.Is(err, personality.ErrInvalidAnswer) {
+			return res, http.StatusBadRequest, err
+		}
+		return res, http.StatusInternalServerError, err
+	}
+
+	res.Source = constant.PersonalitySourceLocal
+	return res, http.StatusOK, nil
+}
+
+func maxTraitDifference(a, b datastruct.PersonalityPercentages) (diff float64) {
+	for _, v := range [][2]float64{
+		{a.Agreeable, b.Agreeable},
+		{a.Conscientious, b.Conscientious},
+		{a.Extraversion, b.Extraversion},
+		{a.Neurotic, b.Neurotic},
+		{a.Openness, b.Openness},
+	} {
+		diff = math.Max(diff, math.Abs(v[0]-v[1]))
+	}
+
+	return
+}
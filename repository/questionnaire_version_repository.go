@@ -0,0 +1,468 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yusufwib/arvigo-backend/constant"
+	"github.com/yusufwib/arvigo-backend/datastruct"
+	"github.com/yusufwib/arvigo-backend/pkg/personality"
+	"github.com/yusufwib/arvigo-backend/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Once a version has results its questions and scoring key are frozen, so
+// stored answers keep their meaning; changes go into a new version instead.
+// Question text can still be corrected.
+var errQuestionnaireVersionInUse = errors.New("questionnaire version already has results, create a new version instead")
+
+// getQuestionnaireVersion loads a version, or the active one when versionID is 0.
+func getQuestionnaireVersion(db *gorm.DB, versionID uint64) (res datastruct.QuestionnaireVersion, statusCode int, err error) {
+	query := db.Model(&datastruct.QuestionnaireVersion{})
+	if versionID == 0 {
+		query = query.Where("is_active = 1")
+	} else {
+		query = query.Where("id = ?", versionID)
+	}
+
+	if err = query.Order("id DESC").First(&res).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res, http.StatusNotFound, errors.New("questionnaire version not found")
+		}
+		return res, http.StatusInternalServerError, err
+	}
+
+	return res, http.StatusOK, nil
+}
+
+// normalizeQuestionnaireLanguage maps a lang parameter or Accept-Language
+// header, e.g. "en-US,en;q=0.9", to a supported language.
+func normalizeQuestionnaireLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(strings.Split(language, ",")[0]))
+	language = strings.Split(strings.Split(language, ";")[0], "-")[0]
+	if utils.InArrayString(language, constant.QuestionnaireLanguages) {
+		return language
+	}
+
+	return constant.QuestionnaireDefaultLanguage
+}
+
+func isQuestionnaireVersionInUse(db *gorm.DB, versionID uint64) (bool, error) {
+	var count int64
+	if err := db.Model(&datastruct.UserPersonality{}).
+		Where("questionnaire_version_id = ?", versionID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func GetQuestionnaireVersions() (res []datastruct.QuestionnaireVersion, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	if err = db.Table("questionnaire_versions qv").
+		Select(strings.Join([]string{
+			"qv.*",
+			"(SELECT COUNT(*) FROM personality_questionnaires pq WHERE pq.questionnaire_version_id = qv.id) as question_count",
+			"(SELECT COUNT(*) FROM user_personalities up WHERE up.questionnaire_version_id = qv.id) as result_count",
+		}, ", ")).
+		Order("qv.version DESC").
+		Find(&res).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	return
+}
+
+// CreateQuestionnaireVersion adds an inactive version numbered after the
+// latest one, optionally starting from a copy of another version's questions.
+func CreateQuestionnaireVersion(data datastruct.QuestionnaireVersionInput) (res datastruct.QuestionnaireVersion, statusCode int, err error) {
+	db := Database()
+	currentTime := time.Now()
+
+	var questions []datastruct.Questionnaire
+	var translations []datastruct.QuestionnaireTranslation
+	if data.CopyFromVersionID != 0 {
+		if _, statusCode, err = getQuestionnaireVersion(db, data.CopyFromVersionID); err != nil {
+			return
+		}
+		if err = db.Where("questionnaire_version_id = ?", data.CopyFromVersionID).
+			Order("sort_order ASC, id ASC").
+			Find(&questions).Error; err != nil {
+			return res, http.StatusInternalServerError, err
+		}
+		if err = db.Where("questionnaire_id IN (?)", db.Model(&datastruct.Questionnaire{}).
+			Select("id").
+			Where("questionnaire_version_id = ?", data.CopyFromVersionID)).
+			Find(&translations).Error; err != nil {
+			return res, http.StatusInternalServerError, err
+		}
+	}
+
+	var latest int
+	if err = db.Model(&datastruct.QuestionnaireVersion{}).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&latest).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	res = datastruct.QuestionnaireVersion{
+		Name:      strings.TrimSpace(data.Name),
+		Version:   latest + 1,
+		CreatedAt: currentTime,
+		UpdatedAt: currentTime,
+	}
+
+	// Begin a transaction
+	tx := db.Begin()
+
+	// Defer the rollback function in case of error
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = tx.Create(&res).Error; err != nil {
+		tx.Rollback()
+		return res, http.StatusInternalServerError, err
+	}
+
+	for _, question := range questions {
+		sourceID := question.ID
+		question.ID = 0
+		question.QuestionnaireVersionID = res.ID
+		question.CreatedAt = currentTime
+		question.UpdatedAt = currentTime
+		if err = tx.Create(&question).Error; err != nil {
+			tx.Rollback()
+			return res, http.StatusInternalServerError, err
+		}
+
+		for _, translation := range translations {
+			if translation.QuestionnaireID != sourceID {
+				continue
+			}
+			translation.ID = 0
+			translation.QuestionnaireID = question.ID
+			translation.CreatedAt = currentTime
+			translation.UpdatedAt = currentTime
+			if err = tx.Create(&translation).Error; err != nil {
+				tx.Rollback()
+				return res, http.StatusInternalServerError, err
+			}
+		}
+	}
+
+	// Commit the transaction if all queries succeed
+	if err = tx.Commit().Error; err != nil {
+		tx.Rollback()
+		log.Println("Error committing transaction:", err)
+		return res, http.StatusInternalServerError, err
+	}
+
+	res.QuestionCount = int64(len(questions))
+	return res, http.StatusCreated, nil
+}
+
+// ActivateQuestionnaireVersion makes the version the one users answer. It
+// needs at least one question for every trait.
+func ActivateQuestionnaireVersion(versionID uint64) (res datastruct.QuestionnaireVersion, statusCode int, err error) {
+	db := Database()
+
+	if res, statusCode, err = getQuestionnaireVersion(db, versionID); err != nil {
+		return
+	}
+
+	var traits []string
+	if err = db.Model(&datastruct.Questionnaire{}).
+		Where("questionnaire_version_id = ?", versionID).
+		Distinct().
+		Pluck("trait", &traits).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+	for _, trait := range personality.Traits {
+		if !utils.InArrayString(trait, traits) {
+			return res, http.StatusBadRequest, fmt.Errorf("the questionnaire has no %s questions", trait)
+		}
+	}
+
+	currentTime := time.Now()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&datastruct.QuestionnaireVersion{}).
+			Where("id <> ? AND is_active = 1", versionID).
+			Updates(map[string]interface{}{"is_active": 0, "updated_at": currentTime}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&res).
+			Updates(map[string]interface{}{"is_active": 1, "updated_at": currentTime}).Error
+	})
+	if err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	res.IsActive = true
+	res.UpdatedAt = currentTime
+	return res, http.StatusOK, nil
+}
+
+// GetQuestionnaireItems returns the questions of a version with their scoring
+// key and every translation.
+func GetQuestionnaireItems(versionID uint64) (res []datastruct.QuestionnaireItem, statusCode int, err error) {
+	db := Database()
+
+	if _, statusCode, err = getQuestionnaireVersion(db, versionID); err != nil {
+		return
+	}
+
+	var questions []datastruct.Questionnaire
+	if err = db.Where("questionnaire_version_id = ?", versionID).
+		Order("sort_order ASC, id ASC").
+		Find(&questions).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	res = []datastruct.QuestionnaireItem{}
+	if len(questions) == 0 {
+		return res, http.StatusOK, nil
+	}
+
+	questionIDs := make([]uint64, 0, len(questions))
+	for _, v := range questions {
+		questionIDs = append(questionIDs, v.ID)
+	}
+
+	var translations []datastruct.QuestionnaireTranslation
+	if err = db.Where("questionnaire_id IN (?)", questionIDs).Find(&translations).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	translationMap := make(map[uint64]map[string]string)
+	for _, v := range translations {
+		if translationMap[v.QuestionnaireID] == nil {
+			translationMap[v.QuestionnaireID] = map[string]string{}
+		}
+		translationMap[v.QuestionnaireID][v.Language] = v.Question
+	}
+
+	for _, v := range questions {
+		res = append(res, toQuestionnaireItem(v, translationMap[v.ID]))
+	}
+
+	return res, http.StatusOK, nil
+}
+
+func CreateQuestionnaireItem(versionID uint64, data datastruct.QuestionnaireItemInput) (res datastruct.QuestionnaireItem, statusCode int, err error) {
+	db := Database()
+	currentTime := time.Now()
+
+	if _, statusCode, err = getQuestionnaireVersion(db, versionID); err != nil {
+		return
+	}
+
+	inUse, err := isQuestionnaireVersionInUse(db, versionID)
+	if err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+	if inUse {
+		return res, http.StatusConflict, errQuestionnaireVersionInUse
+	}
+
+	if statusCode, err = validateQuestionnaireItemInput(db, versionID, data, 0); err != nil {
+		return
+	}
+
+	question := datastruct.Questionnaire{
+		QuestionnaireVersionID: versionID,
+		CreatedAt:              currentTime,
+	}
+	applyQuestionnaireItemInput(&question, data, currentTime)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&question).Error; err != nil {
+			return err
+		}
+		return saveQuestionnaireTranslations(tx, question.ID, data.Translations, currentTime)
+	})
+	if err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	return toQuestionnaireItem(question, trimTranslations(data.Translations)), http.StatusCreated, nil
+}
+
+// UpdateQuestionnaireItem edits a question. Versions with results only accept
+// changes to the question text and order.
+func UpdateQuestionnaireItem(versionID, questionID uint64, data datastruct.QuestionnaireItemInput) (res datastruct.QuestionnaireItem, statusCode int, err error) {
+	db := Database()
+	currentTime := time.Now()
+
+	var question datastruct.Questionnaire
+	if err = db.Where("id = ? AND questionnaire_version_id = ?", questionID, versionID).First(&question).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res, http.StatusNotFound, errors.New("question not found")
+		}
+		return res, http.StatusInternalServerError, err
+	}
+
+	if statusCode, err = validateQuestionnaireItemInput(db, versionID, data, questionID); err != nil {
+		return
+	}
+
+	keyChanged := question.Type != strings.TrimSpace(data.Type) ||
+		question.Trait != strings.ToUpper(strings.TrimSpace(data.Trait)) ||
+		question.IsReverseKeyed != data.IsReverseKeyed
+	if keyChanged {
+		inUse, err := isQuestionnaireVersionInUse(db, versionID)
+		if err != nil {
+			return res, http.StatusInternalServerError, err
+		}
+		if inUse {
+			return res, http.StatusConflict, errQuestionnaireVersionInUse
+		}
+	}
+
+	applyQuestionnaireItemInput(&question, data, currentTime)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&question).Error; err != nil {
+			return err
+		}
+		return saveQuestionnaireTranslations(tx, question.ID, data.Translations, currentTime)
+	})
+	if err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	return toQuestionnaireItem(question, trimTranslations(data.Translations)), http.StatusOK, nil
+}
+
+func DeleteQuestionnaireItem(versionID, questionID uint64) (statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	inUse, err := isQuestionnaireVersionInUse(db, versionID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if inUse {
+		return http.StatusConflict, errQuestionnaireVersionInUse
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND questionnaire_version_id = ?", questionID, versionID).Delete(&datastruct.Questionnaire{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Where("questionnaire_id = ?", questionID).Delete(&datastruct.QuestionnaireTranslation{}).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return http.StatusNotFound, errors.New("question not found")
+		}
+		return http.StatusInternalServerError, err
+	}
+
+	return
+}
+
+func validateQuestionnaireItemInput(db *gorm.DB, versionID uint64, data datastruct.QuestionnaireItemInput, questionID uint64) (statusCode int, err error) {
+	if !utils.InArrayString(strings.ToUpper(strings.TrimSpace(data.Trait)), personality.Traits) {
+		return http.StatusBadRequest, fmt.Errorf("trait must be one of %s", strings.Join(personality.Traits, ", "))
+	}
+
+	translations := trimTranslations(data.Translations)
+	for language := range translations {
+		if !utils.InArrayString(language, constant.QuestionnaireLanguages) {
+			return http.StatusBadRequest, fmt.Errorf("language must be one of %s", strings.Join(constant.QuestionnaireLanguages, ", "))
+		}
+	}
+	if translations[constant.QuestionnaireDefaultLanguage] == "" {
+		return http.StatusBadRequest, fmt.Errorf("the %s question text is required", constant.QuestionnaireDefaultLanguage)
+	}
+
+	var count int64
+	if err = db.Model(&datastruct.Questionnaire{}).
+		Where("questionnaire_version_id = ? AND type = ? AND id <> ?", versionID, strings.TrimSpace(data.Type), questionID).
+		Count(&count).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if count > 0 {
+		return http.StatusConflict, errors.New("question type already exists in this version")
+	}
+
+	return http.StatusOK, nil
+}
+
+func applyQuestionnaireItemInput(question *datastruct.Questionnaire, data datastruct.QuestionnaireItemInput, currentTime time.Time) {
+	question.Type = strings.TrimSpace(data.Type)
+	question.Trait = strings.ToUpper(strings.TrimSpace(data.Trait))
+	question.IsReverseKeyed = data.IsReverseKeyed
+	question.SortOrder = data.SortOrder
+	question.UpdatedAt = currentTime
+}
+
+// saveQuestionnaireTranslations replaces the text of a question in every
+// language, dropping languages no longer given.
+func saveQuestionnaireTranslations(tx *gorm.DB, questionID uint64, translations map[string]string, currentTime time.Time) error {
+	translations = trimTranslations(translations)
+
+	languages := make([]string, 0, len(translations))
+	for language, question := range translations {
+		languages = append(languages, language)
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "questionnaire_id"}, {Name: "language"}},
+			DoUpdates: clause.AssignmentColumns([]string{"question", "updated_at"}),
+		}).Create(&datastruct.QuestionnaireTranslation{
+			QuestionnaireID: questionID,
+			Language:        language,
+			Question:        question,
+			CreatedAt:       currentTime,
+			UpdatedAt:       currentTime,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Where("questionnaire_id = ? AND language NOT IN (?)", questionID, languages).
+		Delete(&datastruct.QuestionnaireTranslation{}).Error
+}
+
+// trimTranslations drops blank texts and normalises the language keys.
+func trimTranslations(translations map[string]string) map[string]string {
+	res := make(map[string]string, len(translations))
+	for language, question := range translations {
+		if question = strings.TrimSpace(question); question != "" {
+			res[strings.ToLower(strings.TrimSpace(language))] = question
+		}
+	}
+
+	return res
+}
+
+func toQuestionnaireItem(question datastruct.Questionnaire, translations map[string]string) datastruct.QuestionnaireItem {
+	if translations == nil {
+		translations = map[string]string{}
+	}
+
+	return datastruct.QuestionnaireItem{
+		ID:                     question.ID,
+		QuestionnaireVersionID: question.QuestionnaireVersionID,
+		Type:                   question.Type,
+		Trait:                  question.Trait,
+		IsReverseKeyed:         question.IsReverseKeyed,
+		SortOrder:              question.SortOrder,
+		Translations:           translations,
+	}
+}
@@ -1,16 +1,18 @@
 package repository
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"log"
 	"mime/multipart"
+	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -18,7 +20,9 @@ import (
 	"github.com/yusufwib/arvigo-backend/datastruct"
 	"github.com/yusufwib/arvigo-backend/pkg/cache"
 	"github.com/yusufwib/arvigo-backend/pkg/database"
+	"github.com/yusufwib/arvigo-backend/pkg/imaging"
 	"github.com/yusufwib/arvigo-backend/pkg/storage"
+	"github.com/yusufwib/arvigo-backend/utils"
 	"gorm.io/gorm"
 )
 
@@ -51,41 +55,125 @@ func GenerateToken(user datastruct.User) (tokenString string, err error) {
 	return
 }
 
-func UploadImageToGCS(fileHeader *multipart.FileHeader) (publicURL string, err error) {
-	file, err := fileHeader.Open()
+// UploadImage uploads the image renditions and returns the detail URL stored on records.
+func UploadImage(fileHeader *multipart.FileHeader, kind string) (publicURL string, err error) {
+	renditions, err := UploadImageRenditions(fileHeader, kind)
 	if err != nil {
-		return publicURL, errors.New("failed to open uploaded image")
+		return publicURL, err
 	}
-	defer file.Close()
 
-	// Create a temporary file to save the uploaded image
-	tempDir := "./public/temp"
-	tempFile, err := ioutil.TempFile(tempDir, "uploaded_image_*.jpg")
+	return renditions.Detail, nil
+}
+
+// UploadObject stores the data on the configured storage backend and registers
+// it as an asset of the given kind.
+func UploadObject(kind string, ownerID uint64, objectName string, data []byte, contentType string) (publicURL string, err error) {
+	store, err := storage.Default()
 	if err != nil {
-		return publicURL, errors.New("failed to create temporary file")
+		return publicURL, fmt.Errorf("storage is not available: %v", err)
 	}
-	defer func() {
-		tempFile.Close()
-		os.Remove(tempFile.Name())
-	}()
 
-	// Save the uploaded image to the temporary file
-	_, err = io.Copy(tempFile, file)
+	publicURL, err = store.Put(context.Background(), objectName, bytes.NewReader(data), contentType)
 	if err != nil {
-		return publicURL, errors.New("failed to save uploaded image")
+		return publicURL, fmt.Errorf("failed to upload image: %v", err)
 	}
 
-	// Get the path of the uploaded image
-	imagePath := tempFile.Name()
-	objectName := fileHeader.Filename
-	publicURL, err = storage.UploadImageToGCS(objectName, imagePath, os.Getenv("STORAGE_BUCKET_IMAGE_FOLDER"))
+	if err := RegisterAsset(kind, ownerID, objectName, publicURL, int64(len(data))); err != nil {
+		log.Println("Failed to register asset:", err)
+	}
+
+	return
+}
+
+// UploadImageRenditions validates the uploaded image, strips its metadata and
+// uploads the JPEG and WebP encodings of the thumbnail, list and detail renditions.
+func UploadImageRenditions(fileHeader *multipart.FileHeader, kind string) (res datastruct.ImageRenditions, err error) {
+	file, err := fileHeader.Open()
 	if err != nil {
-		return publicURL, fmt.Errorf("failed to upload image: %v", err)
+		return res, errors.New("failed to open uploaded image")
+	}
+	defer file.Close()
+
+	renditions, err := imaging.Process(file)
+	if err != nil {
+		return res, err
+	}
+
+	baseName := fmt.Sprintf("%s/%s_%s", os.Getenv("STORAGE_BUCKET_IMAGE_FOLDER"), utils.GenerateRandomStringWithTimestamp(10), sanitizeFileName(fileHeader.Filename))
+	for _, rendition := range renditions {
+		objectName := imaging.RenditionObjectName(baseName, rendition)
+		publicURL, err := UploadObject(kind, 0, objectName, rendition.Data, rendition.ContentType)
+		if err != nil {
+			return res, err
+		}
+
+		webp := rendition.ContentType == imaging.WebPMimeType
+		switch {
+		case rendition.Name == imaging.RenditionThumbnail && webp:
+			res.ThumbnailWebP = publicURL
+		case rendition.Name == imaging.RenditionThumbnail:
+			res.Thumbnail = publicURL
+		case rendition.Name == imaging.RenditionList && webp:
+			res.ListWebP = publicURL
+		case rendition.Name == imaging.RenditionList:
+			res.List = publicURL
+		case rendition.Name == imaging.RenditionDetail && webp:
+			res.DetailWebP = publicURL
+		case rendition.Name == imaging.RenditionDetail:
+			res.Detail = publicURL
+		}
 	}
 
 	return
 }
 
+// GetImageRenditions resolves every rendition URL from a stored detail URL.
+func GetImageRenditions(detailURL string) datastruct.ImageRenditions {
+	return datastruct.ImageRenditions{
+		Thumbnail: imaging.RenditionURL(detailURL, imaging.RenditionThumbnail),
+		List:      imaging.RenditionURL(detailURL, imaging.RenditionList),
+		Detail:    detailURL,
+
+		ThumbnailWebP: imaging.RenditionWebPURL(detailURL, imaging.RenditionThumbnail),
+		ListWebP:      imaging.RenditionWebPURL(detailURL, imaging.RenditionList),
+		DetailWebP:    imaging.RenditionWebPURL(detailURL, imaging.RenditionDetail),
+	}
+}
+
+// GetImageRenditionsList resolves the renditions of every stored image URL.
+func GetImageRenditionsList(detailURLs []string) (res []datastruct.ImageRenditions) {
+	for _, v := range detailURLs {
+		res = append(res, GetImageRenditions(v))
+	}
+
+	return
+}
+
+// ImageStatusCode maps upload errors to a client error when the image itself is invalid.
+func ImageStatusCode(err error) int {
+	if errors.Is(err, imaging.ErrInvalidImage) {
+		return http.StatusBadRequest
+	}
+
+	return http.StatusInternalServerError
+}
+
+func sanitizeFileName(filename string) string {
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	name = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+
+	if name == "" {
+		name = "image"
+	}
+
+	return name
+}
+
 func GetUserAuthFromRedis(userID uint64) (*datastruct.UserAuth, error) {
 	// Get the Redis client from the global variable or initialize it if not already done
 	redisClient, err := cache.ConnectRedis()
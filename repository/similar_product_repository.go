@@ -0,0 +1,331 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/yusufwib/arvigo-backend/constant"
+	"github.com/yusufwib/arvigo-backend/datastruct"
+	"github.com/yusufwib/arvigo-backend/pkg/cache"
+	"github.com/yusufwib/arvigo-backend/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultSimilarProductsCacheTTLSeconds = 3600
+	maxSimilarProducts                    = 30
+	similarProductsCacheKeyPrefix         = "similar_products"
+	similarProductsGenerationKey          = similarProductsCacheKeyPrefix + ":generation"
+	similarProductsCacheTimeout           = 500 * time.Millisecond
+)
+
+// productInteractions lists (user, initial product) pairs from wishlists and
+// marketplace clicks; merchant listings count for their initial product.
+const productInteractions = `
+	SELECT user_id, product_id FROM wishlists WHERE product_id IS NOT NULL
+	UNION
+	SELECT w.user_id, dpm.parent_product_id AS product_id FROM wishlists w
+		JOIN detail_product_marketplaces dpm ON dpm.id = w.detail_product_marketplace_id
+	UNION
+	SELECT c.user_id, dpm.parent_product_id AS product_id FROM detail_product_marketplace_clicked c
+		JOIN detail_product_marketplaces dpm ON dpm.id = c.detail_product_marketplaces`
+
+// Rankings are cached in Redis per initial product under
+// similar_products:<generation>:<product id> and refreshed after
+// SIMILAR_PRODUCTS_CACHE_TTL_SECONDS to follow wishlist and click behaviour.
+// Catalog changes bump the generation, which drops the rankings on every
+// replica at once; the old entries simply expire.
+
+func similarProductsCacheTTL() time.Duration {
+	return time.Duration(utils.StrToInt(os.Getenv("SIMILAR_PRODUCTS_CACHE_TTL_SECONDS"), defaultSimilarProductsCacheTTLSeconds)) * time.Second
+}
+
+func invalidateSimilarProducts() {
+	client, err := cache.Default()
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), similarProductsCacheTimeout)
+	defer cancel()
+
+	if err := client.Incr(ctx, similarProductsGenerationKey).Err(); err != nil {
+		log.Println("Failed to invalidate similar products cache:", err)
+	}
+}
+
+// getCachedSimilarProducts returns the cached ranking of the product, if any,
+// and the cache generation a fresh ranking has to be stored under. Cache
+// failures only cost a cache miss.
+func getCachedSimilarProducts(productID uint64) (products []datastruct.SimilarProduct, generation string, ok bool) {
+	client, err := cache.Default()
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), similarProductsCacheTimeout)
+	defer cancel()
+
+	generation, err = client.Get(ctx, similarProductsGenerationKey).Result()
+	if err == redis.Nil {
+		generation = "0"
+	} else if err != nil {
+		log.Println("Failed to read similar products cache generation:", err)
+		return
+	}
+
+	cached, err := client.Get(ctx, similarProductsCacheKey(generation, productID)).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			log.Println("Failed to read similar products cache:", err)
+		}
+		return
+	}
+
+	ok = json.Unmarshal(cached, &products) == nil
+	return
+}
+
+// cacheSimilarProducts stores a ranking under the generation that was current
+// before it was computed, so a catalog change in the meantime discards it.
+func cacheSimilarProducts(generation string, productID uint64, products []datastruct.SimilarProduct) {
+	ttl := similarProductsCacheTTL()
+	if generation == "" || ttl <= 0 {
+		return
+	}
+
+	client, err := cache.Default()
+	if err != nil {
+		return
+	}
+
+	payload, err := json.Marshal(products)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), similarProductsCacheTimeout)
+	defer cancel()
+
+	if err := client.Set(ctx, similarProductsCacheKey(generation, productID), payload, ttl).Err(); err != nil {
+		log.Println("Failed to write similar products cache:", err)
+	}
+}
+
+func similarProductsCacheKey(generation string, productID uint64) string {
+	return fmt.Sprintf("%s:%s:%d", similarProductsCacheKeyPrefix, generation, productID)
+}
+
+// GetSimilarProducts ranks the other initial products by tag overlap, same
+// category and brand, price band and users who wishlisted or clicked both.
+func GetSimilarProducts(productID uint64, limit int) (res []datastruct.SimilarProduct, statusCode int, err error) {
+	statusCode = http.StatusOK
+	if limit <= 0 || limit > maxSimilarProducts {
+		limit = maxSimilarProducts
+	}
+
+	res, generation, ok := getCachedSimilarProducts(productID)
+	if !ok {
+		if res, statusCode, err = rankSimilarProducts(Database(), productID); err != nil {
+			return
+		}
+		cacheSimilarProducts(generation, productID, res)
+	}
+
+	if len(res) > limit {
+		res = res[:limit]
+	}
+
+	return
+}
+
+func rankSimilarProducts(db *gorm.DB, productID uint64) (res []datastruct.SimilarProduct, statusCode int, err error) {
+	statusCode = http.StatusOK
+
+	var products []datastruct.SimilarProduct
+	if err = db.Table("products p").
+		Select([]string{
+			"p.id",
+			"p.name",
+			"p.images",
+			"p.brand_id",
+			"p.category_id",
+			"b.name AS brand_name",
+			"c.name AS category_name",
+		}).
+		Joins("LEFT JOIN brands b ON b.id = p.brand_id").
+		Joins("LEFT JOIN categories c ON c.id = p.category_id").
+		Where("p.merchant_id = 0").
+		Find(&products).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	var target *datastruct.SimilarProduct
+	for i := range products {
+		if products[i].ID == productID {
+			target = &products[i]
+			break
+		}
+	}
+	if target == nil {
+		return res, http.StatusNotFound, errors.New("product not found")
+	}
+
+	var productTags []datastruct.DetailProductTag
+	if err = db.Table("detail_product_tags dpt").
+		Select("dpt.product_id, dpt.tag_id").
+		Joins("JOIN products p ON p.id = dpt.product_id").
+		Where("p.merchant_id = 0").
+		Find(&productTags).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+	tagsByProduct := make(map[uint64]map[uint64]bool)
+	for _, v := range productTags {
+		if tagsByProduct[v.ProductID] == nil {
+			tagsByProduct[v.ProductID] = make(map[uint64]bool)
+		}
+		tagsByProduct[v.ProductID][v.TagID] = true
+	}
+
+	var priceRanges []datastruct.ComparisonPriceRange
+	if err = db.Table("detail_linked_products dlp").
+		Select("dlp.initial_product_id, MIN(p.price) AS lowest_price").
+		Joins("JOIN products p ON p.id = dlp.merchant_product_id").
+		Where("p.status IN (?)", []string{constant.StatusApproved, constant.StatusSubscribed}).
+		Group("dlp.initial_product_id").
+		Find(&priceRanges).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+	lowestPrice := make(map[uint64]float64, len(priceRanges))
+	for _, v := range priceRanges {
+		lowestPrice[v.InitialProductID] = v.LowestPrice
+	}
+
+	var coInteractions []struct {
+		ProductID uint64 `gorm:"column:product_id"`
+		Users     int    `gorm:"column:users"`
+	}
+	if err = db.Raw(`SELECT b.product_id, COUNT(DISTINCT b.user_id) AS users
+		FROM (`+productInteractions+`) a
+		JOIN (`+productInteractions+`) b ON a.user_id = b.user_id
+		WHERE a.product_id = ? AND b.product_id != ?
+		GROUP BY b.product_id`, productID, productID).
+		Scan(&coInteractions).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+	sharedUsers := make(map[uint64]int, len(coInteractions))
+	maxSharedUsers := 0
+	for _, v := range coInteractions {
+		sharedUsers[v.ProductID] = v.Users
+		if v.Users > maxSharedUsers {
+			maxSharedUsers = v.Users
+		}
+	}
+
+	targetTags := tagsByProduct[target.ID]
+	targetPrice, targetHasPrice := lowestPrice[target.ID]
+
+	for _, candidate := range products {
+		if candidate.ID == target.ID {
+			continue
+		}
+
+		var (
+			score   float64
+			reasons []string
+		)
+
+		if similarity := tagSimilarity(targetTags, tagsByProduct[candidate.ID]); similarity > 0 {
+			score += constant.SimilarWeightTags * similarity
+			reasons = append(reasons, constant.SimilarReasonSharedTags)
+		}
+		if candidate.CategoryID == target.CategoryID {
+			score += constant.SimilarWeightCategory
+			reasons = append(reasons, constant.SimilarReasonSameCategory)
+		}
+		if candidate.BrandID == target.BrandID {
+			score += constant.SimilarWeightBrand
+			reasons = append(reasons, constant.SimilarReasonSameBrand)
+		}
+		if price, ok := lowestPrice[candidate.ID]; ok {
+			candidatePrice := price
+			candidate.LowestPrice = &candidatePrice
+
+			if targetHasPrice && targetPrice > 0 && price > 0 {
+				// 1 for the same price, 0 from twice (or half) the price onwards
+				closeness := 1 - (max64(price, targetPrice)/min64(price, targetPrice) - 1)
+				if closeness > 0 {
+					score += constant.SimilarWeightPrice * closeness
+					if closeness >= 0.5 {
+						reasons = append(reasons, constant.SimilarReasonSimilarPrice)
+					}
+				}
+			}
+		}
+		if users := sharedUsers[candidate.ID]; users > 0 {
+			score += constant.SimilarWeightBehaviour * float64(users) / float64(maxSharedUsers)
+			reasons = append(reasons, constant.SimilarReasonAlsoLiked)
+		}
+
+		// products sharing nothing but a price band are not similar
+		if len(reasons) == 0 || (len(reasons) == 1 && reasons[0] == constant.SimilarReasonSimilarPrice) {
+			continue
+		}
+
+		candidate.Image = strings.Split(candidate.Image, ",")[0]
+		candidate.Score = utils.RoundFloat64(score, 4)
+		candidate.Reasons = reasons
+		res = append(res, candidate)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Score != res[j].Score {
+			return res[i].Score > res[j].Score
+		}
+		return res[i].ID < res[j].ID
+	})
+	if len(res) > maxSimilarProducts {
+		res = res[:maxSimilarProducts]
+	}
+
+	return
+}
+
+// tagSimilarity is the Jaccard index of two tag sets.
+func tagSimilarity(a, b map[uint64]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for tagID := range a {
+		if b[tagID] {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+func min64(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max64(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,379 @@
+package repository
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yusufwib/arvigo-backend/constant"
+	"github.com/yusufwib/arvigo-backend/datastruct"
+	"github.com/yusufwib/arvigo-backend/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultLowStockThreshold = 3
+	maxStockImportRows       = 5000
+)
+
+var stockImportColumns = []string{"detail_product_marketplace_id", "variant_id", "quantity"}
+
+// GetMerchantProductStocks lists the stock of every variant at each offline
+// store listing of the merchant product.
+func GetMerchantProductStocks(productID, userID uint64) (res []datastruct.ListingStock, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	merchantID, statusCode, err := getUserMerchantID(db, userID)
+	if err != nil {
+		return
+	}
+
+	var product datastruct.Product
+	if err = db.Where("id = ? AND merchant_id = ?", productID, merchantID).First(&product).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res, http.StatusNotFound, errors.New("product not found")
+		}
+		return res, http.StatusInternalServerError, err
+	}
+
+	var listings []datastruct.DetailProductMarketplace
+	if err = db.Where("product_id = ? AND marketplace_id = 0 AND addresses_id != 0", productID).Find(&listings).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	var variants []datastruct.DetailProductVariant
+	if err = db.Where("product_id = ?", productID).Order("id").Find(&variants).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	listingIDs := make([]uint64, 0, len(listings))
+	addressIDs := make([]uint64, 0, len(listings))
+	for _, v := range listings {
+		listingIDs = append(listingIDs, v.ID)
+		addressIDs = append(addressIDs, v.AddressID)
+	}
+	addresses := getAddressesByIDs(db, addressIDs)
+
+	quantities := make(map[uint64]map[uint64]int)
+	if len(listingIDs) > 0 {
+		var stocks []datastruct.ProductStock
+		if err = db.Where("detail_product_marketplace_id IN (?)", listingIDs).Find(&stocks).Error; err != nil {
+			return res, http.StatusInternalServerError, err
+		}
+		for _, v := range stocks {
+			if quantities[v.DetailProductMarketplaceID] == nil {
+				quantities[v.DetailProductMarketplaceID] = make(map[uint64]int)
+			}
+			quantities[v.DetailProductMarketplaceID][v.DetailProductVariantID] = v.Quantity
+		}
+	}
+
+	res = make([]datastruct.ListingStock, 0, len(listings))
+	for _, listing := range listings {
+		listingStock := datastruct.ListingStock{
+			DetailProductMarketplaceID: listing.ID,
+			Variants:                   make([]datastruct.VariantStock, 0, len(variants)),
+		}
+		if addr, ok := addresses[listing.AddressID]; ok {
+			listingStock.Address = &addr.Address
+		}
+
+		tracked, total := false, 0
+		for _, variant := range variants {
+			quantity, ok := quantities[listing.ID][variant.ID]
+			tracked = tracked || ok
+			total += quantity
+			listingStock.Variants = append(listingStock.Variants, datastruct.VariantStock{
+				VariantID:   variant.ID,
+				VariantName: variant.Name,
+				Quantity:    quantity,
+				StockStatus: getStockStatus(quantity),
+			})
+		}
+		if tracked {
+			status := getStockStatus(total)
+			listingStock.StockStatus = &status
+		}
+
+		res = append(res, listingStock)
+	}
+
+	return
+}
+
+// UpdateMerchantProductStocks sets the stock quantities of the merchant
+// product offline listings.
+func UpdateMerchantProductStocks(productID, userID uint64, data datastruct.StockInput) (statusCode int, err error) {
+	db := Database()
+
+	merchantID, statusCode, err := getUserMerchantID(db, userID)
+	if err != nil {
+		return
+	}
+
+	rowErrors, statusCode, err := saveStocks(db, merchantID, productID, data.Stocks, nil)
+	if err != nil {
+		return
+	}
+	if len(rowErrors) > 0 {
+		return http.StatusBadRequest, errors.New(strings.Join(rowErrors, "; "))
+	}
+
+	return http.StatusOK, nil
+}
+
+// ImportMerchantStocks bulk updates stock from a CSV with the columns
+// detail_product_marketplace_id, variant_id and quantity. Nothing is saved
+// when any row is invalid; the report lists the offending lines.
+func ImportMerchantStocks(userID uint64, file *multipart.FileHeader) (res datastruct.StockImportReport, statusCode int, err error) {
+	db := Database()
+
+	merchantID, statusCode, err := getUserMerchantID(db, userID)
+	if err != nil {
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return res, http.StatusBadRequest, errors.New("failed to open file")
+	}
+	defer src.Close()
+
+	reader := csv.NewReader(src)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return res, http.StatusBadRequest, errors.New("file is empty or not a csv")
+	}
+	columns := make(map[string]int, len(header))
+	for i, v := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(v, "\ufeff")))] = i
+	}
+	for _, v := range stockImportColumns {
+		if _, ok := columns[v]; !ok {
+			return res, http.StatusBadRequest, fmt.Errorf("missing column %s", v)
+		}
+	}
+
+	var (
+		items []datastruct.StockItemInput
+		lines []int
+	)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			res.Errors = append(res.Errors, fmt.Sprintf("line %d: %v", line, err))
+			continue
+		}
+		if len(items) >= maxStockImportRows {
+			return res, http.StatusBadRequest, fmt.Errorf("file must have at most %d rows", maxStockImportRows)
+		}
+
+		listingID := utils.StrToUint64(strings.TrimSpace(record[columns["detail_product_marketplace_id"]]), 0)
+		variantID := utils.StrToUint64(strings.TrimSpace(record[columns["variant_id"]]), 0)
+		quantity, convErr := strconv.Atoi(strings.TrimSpace(record[columns["quantity"]]))
+		if listingID == 0 || variantID == 0 || convErr != nil || quantity < 0 {
+			res.Errors = append(res.Errors, fmt.Sprintf("line %d: invalid listing, variant or quantity", line))
+			continue
+		}
+
+		items = append(items, datastruct.StockItemInput{
+			DetailProductMarketplaceID: listingID,
+			VariantID:                  variantID,
+			Quantity:                   &quantity,
+		})
+		lines = append(lines, line)
+	}
+
+	if len(res.Errors) == 0 && len(items) == 0 {
+		return res, http.StatusBadRequest, errors.New("file has no rows")
+	}
+
+	rowErrors, statusCode, err := saveStocks(db, merchantID, 0, items, lines)
+	if err != nil {
+		return
+	}
+	res.Errors = append(res.Errors, rowErrors...)
+	if len(res.Errors) > 0 {
+		return res, http.StatusBadRequest, errors.New("stock file has invalid rows")
+	}
+
+	res.Updated = len(items)
+	return res, http.StatusOK, nil
+}
+
+// saveStocks checks that every item is an offline listing of the merchant
+// (of productID when set) with one of the listed product variants, then
+// upserts them all. Invalid items are returned as messages instead, labelled
+// by lines when given, and nothing is written.
+func saveStocks(db *gorm.DB, merchantID, productID uint64, items []datastruct.StockItemInput, lines []int) (rowErrors []string, statusCode int, err error) {
+	statusCode = http.StatusOK
+
+	var listingIDs, variantIDs []uint64
+	for _, v := range items {
+		listingIDs = append(listingIDs, v.DetailProductMarketplaceID)
+		variantIDs = append(variantIDs, v.VariantID)
+	}
+
+	var listings []datastruct.DetailProductMarketplace
+	if len(listingIDs) > 0 {
+		query := db.Table("detail_product_marketplaces dpm").
+			Select("dpm.id, dpm.product_id, dpm.marketplace_id, IFNULL(dpm.addresses_id, 0) AS addresses_id").
+			Joins("JOIN products p ON p.id = dpm.product_id").
+			Where("dpm.id IN (?) AND p.merchant_id = ?", listingIDs, merchantID)
+		if productID != 0 {
+			query = query.Where("dpm.product_id = ?", productID)
+		}
+		if err = query.Find(&listings).Error; err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+	}
+	listingByID := make(map[uint64]datastruct.DetailProductMarketplace, len(listings))
+	for _, v := range listings {
+		listingByID[v.ID] = v
+	}
+
+	var variants []datastruct.DetailProductVariant
+	if len(variantIDs) > 0 {
+		if err = db.Where("id IN (?)", variantIDs).Find(&variants).Error; err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+	}
+	variantProduct := make(map[uint64]uint64, len(variants))
+	for _, v := range variants {
+		variantProduct[v.ID] = v.ProductID
+	}
+
+	currentTime := time.Now()
+	stocks := make([]datastruct.ProductStock, 0, len(items))
+	for i, item := range items {
+		label := fmt.Sprintf("item %d", i+1)
+		if i < len(lines) {
+			label = fmt.Sprintf("line %d", lines[i])
+		}
+
+		listing, ok := listingByID[item.DetailProductMarketplaceID]
+		switch {
+		case !ok:
+			rowErrors = append(rowErrors, fmt.Sprintf("%s: listing %d not found", label, item.DetailProductMarketplaceID))
+			continue
+		case listing.MarketplaceID != 0 || listing.AddressID == 0:
+			rowErrors = append(rowErrors, fmt.Sprintf("%s: listing %d is not an offline store", label, item.DetailProductMarketplaceID))
+			continue
+		case variantProduct[item.VariantID] != listing.ProductID:
+			rowErrors = append(rowErrors, fmt.Sprintf("%s: variant %d is not a variant of the listed product", label, item.VariantID))
+			continue
+		}
+
+		stocks = append(stocks, datastruct.ProductStock{
+			DetailProductMarketplaceID: item.DetailProductMarketplaceID,
+			DetailProductVariantID:     item.VariantID,
+			Quantity:                   *item.Quantity,
+			CreatedAt:                  currentTime,
+			UpdatedAt:                  currentTime,
+		})
+	}
+
+	if len(rowErrors) > 0 || len(stocks) == 0 {
+		return
+	}
+
+	if err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "detail_product_marketplace_id"}, {Name: "detail_product_variant_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"quantity", "updated_at"}),
+	}).CreateInBatches(&stocks, 500).Error; err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return
+}
+
+// getListingStockStatuses returns the stock status of each offline listing
+// that tracks stock; listings without stock records are left out.
+func getListingStockStatuses(db *gorm.DB, listingIDs []uint64) (res map[uint64]string) {
+	res = make(map[uint64]string)
+	if len(listingIDs) == 0 {
+		return
+	}
+
+	var totals []struct {
+		DetailProductMarketplaceID uint64 `gorm:"column:detail_product_marketplace_id"`
+		Quantity                   int    `gorm:"column:quantity"`
+	}
+	if err := db.Model(&datastruct.ProductStock{}).
+		Select("detail_product_marketplace_id, SUM(quantity) AS quantity").
+		Where("detail_product_marketplace_id IN (?)", listingIDs).
+		Group("detail_product_marketplace_id").
+		Scan(&totals).Error; err != nil {
+		log.Println("Failed to load stock:", err)
+		return
+	}
+
+	for _, v := range totals {
+		res[v.DetailProductMarketplaceID] = getStockStatus(v.Quantity)
+	}
+
+	return
+}
+
+func getStockStatus(quantity int) string {
+	switch {
+	case quantity <= 0:
+		return constant.StockStatusOutOfStock
+	case quantity <= utils.StrToInt(os.Getenv("LOW_STOCK_THRESHOLD"), defaultLowStockThreshold):
+		return constant.StockStatusLow
+	default:
+		return constant.StockStatusInStock
+	}
+}
+
+// getUserMerchantID resolves the merchant the user belongs to.
+func getUserMerchantID(db *gorm.DB, userID uint64) (merchantID uint64, statusCode int, err error) {
+	statusCode = http.StatusOK
+
+	if err = db.Table("users").Select("IFNULL(merchant_id, 0)").Where("id = ?", userID).Scan(&merchantID).Error; err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+	if merchantID == 0 {
+		return 0, http.StatusForbidden, errors.New("user is not a merchant")
+	}
+
+	return
+}
+
+// getListingVariantStocks returns the stock of each variant of the product at
+// the listing; variants without a stock record count as zero.
+func getListingVariantStocks(db *gorm.DB, listingID, productID uint64) (res []datastruct.VariantStock, err error) {
+	if err = db.Table("detail_product_variants dpv").
+		Select([]string{
+			"dpv.id AS variant_id",
+			"dpv.name AS variant_name",
+			"IFNULL(dps.quantity, 0) AS quantity",
+		}).
+		Joins("LEFT JOIN detail_product_stocks dps ON dps.detail_product_variant_id = dpv.id AND dps.detail_product_marketplace_id = ?", listingID).
+		Where("dpv.product_id = ?", productID).
+		Order("dpv.id").
+		Find(&res).Error; err != nil {
+		return
+	}
+
+	for i, v := range res {
+		res[i].StockStatus = getStockStatus(v.Quantity)
+	}
+
+	return
+}
@@ -0,0 +1,512 @@
+package repository
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yusufwib/arvigo-backend/datastruct"
+	"github.com/yusufwib/arvigo-backend/utils"
+	"gorm.io/gorm"
+)
+
+const defaultTagCacheTTLSeconds = 300
+
+// tagMappings is the in-memory copy of the face shape and personality tag
+// mappings, refreshed after TAG_CACHE_TTL_SECONDS or on any admin change.
+type tagMappings struct {
+	faceShapeIDs    map[string]uint64
+	faceShapeTags   map[uint64][]uint64
+	personalityTags map[string][]uint64
+	tagLabels       map[uint64][]string
+	loadedAt        time.Time
+}
+
+var (
+	tagCacheMu sync.RWMutex
+	tagCache   *tagMappings
+)
+
+func getTagMappings() (*tagMappings, error) {
+	ttl := time.Duration(utils.StrToInt(os.Getenv("TAG_CACHE_TTL_SECONDS"), defaultTagCacheTTLSeconds)) * time.Second
+
+	tagCacheMu.RLock()
+	cached := tagCache
+	tagCacheMu.RUnlock()
+	if cached != nil && time.Since(cached.loadedAt) < ttl {
+		return cached, nil
+	}
+
+	mappings, err := loadTagMappings(Database())
+	if err != nil {
+		// keep serving the stale copy rather than failing home and tests
+		if cached != nil {
+			log.Println("Failed to refresh tag mappings:", err)
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	tagCacheMu.Lock()
+	tagCache = mappings
+	tagCacheMu.Unlock()
+
+	return mappings, nil
+}
+
+func invalidateTagMappings() {
+	tagCacheMu.Lock()
+	tagCache = nil
+	tagCacheMu.Unlock()
+}
+
+func loadTagMappings(db *gorm.DB) (*tagMappings, error) {
+	mappings := &tagMappings{
+		faceShapeIDs:    make(map[string]uint64),
+		faceShapeTags:   make(map[uint64][]uint64),
+		personalityTags: make(map[string][]uint64),
+		tagLabels:       make(map[uint64][]string),
+		loadedAt:        time.Now(),
+	}
+
+	var faceShapes []datastruct.FaceShape
+	if err := db.Find(&faceShapes).Error; err != nil {
+		return nil, err
+	}
+	faceShapeNames := make(map[uint64]string, len(faceShapes))
+	for _, v := range faceShapes {
+		mappings.faceShapeIDs[strings.ToLower(v.Name)] = v.ID
+		faceShapeNames[v.ID] = capitalize(v.Name)
+	}
+
+	var faceShapeTags []datastruct.DetailFaceShapeTag
+	if err := db.Order("face_shape_id, tag_id").Find(&faceShapeTags).Error; err != nil {
+		return nil, err
+	}
+	for _, v := range faceShapeTags {
+		mappings.faceShapeTags[v.FaceShapeID] = append(mappings.faceShapeTags[v.FaceShapeID], v.TagID)
+		if name, ok := faceShapeNames[v.FaceShapeID]; ok {
+			mappings.tagLabels[v.TagID] = append(mappings.tagLabels[v.TagID], name)
+		}
+	}
+
+	var traits []datastruct.PersonalityTrait
+	if err := db.Find(&traits).Error; err != nil {
+		return nil, err
+	}
+	traitByID := make(map[uint64]datastruct.PersonalityTrait, len(traits))
+	for _, v := range traits {
+		traitByID[v.ID] = v
+	}
+
+	var personalityTags []datastruct.DetailPersonalityTag
+	if err := db.Order("personality_trait_id, tag_id").Find(&personalityTags).Error; err != nil {
+		return nil, err
+	}
+	for _, v := range personalityTags {
+		trait, ok := traitByID[v.PersonalityTraitID]
+		if !ok {
+			continue
+		}
+		mappings.personalityTags[trait.Code] = append(mappings.personalityTags[trait.Code], v.TagID)
+		mappings.tagLabels[v.TagID] = append(mappings.tagLabels[v.TagID], trait.Name)
+	}
+
+	return mappings, nil
+}
+
+// GetTagLabels returns the face shape and personality names matching the
+// given product tags, as shown on product cards.
+func GetTagLabels(tagIDs []uint64) []string {
+	mappings, err := getTagMappings()
+	if err != nil {
+		log.Println("Failed to load tag mappings:", err)
+		return nil
+	}
+
+	var labels []string
+	for _, v := range tagIDs {
+		labels = append(labels, mappings.tagLabels[v]...)
+	}
+
+	return utils.RemoveDuplicates(labels)
+}
+
+// GetFaceShapeTagIDs returns the tags recommended for a face shape.
+func GetFaceShapeTagIDs(faceShapeID uint64) ([]uint64, error) {
+	mappings, err := getTagMappings()
+	if err != nil {
+		return nil, err
+	}
+
+	return mappings.faceShapeTags[faceShapeID], nil
+}
+
+// GetSuitableFaceShapes returns the names of the face shapes recommended for
+// at least one of the given product tags.
+func GetSuitableFaceShapes(tagIDs []uint64) []string {
+	mappings, err := getTagMappings()
+	if err != nil {
+		log.Println("Failed to load tag mappings:", err)
+		return nil
+	}
+
+	productTags := make(map[uint64]bool, len(tagIDs))
+	for _, v := range tagIDs {
+		productTags[v] = true
+	}
+
+	var faceShapes []string
+	for name, faceShapeID := range mappings.faceShapeIDs {
+		for _, tagID := range mappings.faceShapeTags[faceShapeID] {
+			if productTags[tagID] {
+				faceShapes = append(faceShapes, capitalize(name))
+				break
+			}
+		}
+	}
+	sort.Strings(faceShapes)
+
+	return faceShapes
+}
+
+// GetFaceShapeIDByName resolves the shape label returned by the face model.
+func GetFaceShapeIDByName(name string) (uint64, error) {
+	mappings, err := getTagMappings()
+	if err != nil {
+		return 0, err
+	}
+
+	return mappings.faceShapeIDs[strings.ToLower(strings.TrimSpace(name))], nil
+}
+
+// GetPersonalityTagIDs returns the tags recommended for a personality trait code.
+func GetPersonalityTagIDs(code string) ([]uint64, error) {
+	mappings, err := getTagMappings()
+	if err != nil {
+		return nil, err
+	}
+
+	return mappings.personalityTags[code], nil
+}
+
+func GetTags(categoryID uint64) (res []datastruct.Tag, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	query := db.Order("category_id, name")
+	if categoryID != 0 {
+		query = query.Where("category_id = ?", categoryID)
+	}
+
+	if err = query.Find(&res).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	return
+}
+
+func CreateTag(data datastruct.TagInput) (res datastruct.Tag, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusCreated
+	currentTime := time.Now()
+
+	if statusCode, err = validateTagInput(db, data, 0); err != nil {
+		return
+	}
+
+	res = datastruct.Tag{
+		Name:       strings.TrimSpace(data.Name),
+		CategoryID: data.CategoryID,
+		CreatedAt:  currentTime,
+		UpdatedAt:  currentTime,
+	}
+	if err = db.Create(&res).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	return res, http.StatusCreated, nil
+}
+
+func UpdateTag(tagID uint64, data datastruct.TagInput) (res datastruct.Tag, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	if err = db.Where("id = ?", tagID).First(&res).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res, http.StatusNotFound, errors.New("tag not found")
+		}
+		return res, http.StatusInternalServerError, err
+	}
+
+	if statusCode, err = validateTagInput(db, data, tagID); err != nil {
+		return
+	}
+
+	res.Name = strings.TrimSpace(data.Name)
+	res.CategoryID = data.CategoryID
+	res.UpdatedAt = time.Now()
+	if err = db.Save(&res).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	invalidateTagMappings()
+	return res, http.StatusOK, nil
+}
+
+func DeleteTag(tagID uint64) (statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	var usage int64
+	if err = db.Model(&datastruct.DetailProductTag{}).Where("tag_id = ?", tagID).Count(&usage).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if usage > 0 {
+		return http.StatusConflict, errors.New("tag is still used by products")
+	}
+
+	// Begin a transaction
+	tx := db.Begin()
+
+	// Defer the rollback function in case of error
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	result := tx.Where("id = ?", tagID).Delete(&datastruct.Tag{})
+	if result.Error != nil {
+		tx.Rollback()
+		return http.StatusInternalServerError, result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return http.StatusNotFound, errors.New("tag not found")
+	}
+
+	if err = tx.Where("tag_id = ?", tagID).Delete(&datastruct.DetailFaceShapeTag{}).Error; err != nil {
+		tx.Rollback()
+		return http.StatusInternalServerError, err
+	}
+	if err = tx.Where("tag_id = ?", tagID).Delete(&datastruct.DetailPersonalityTag{}).Error; err != nil {
+		tx.Rollback()
+		return http.StatusInternalServerError, err
+	}
+
+	// Commit the transaction if all queries succeed
+	if err = tx.Commit().Error; err != nil {
+		tx.Rollback()
+		log.Println("Error committing transaction:", err)
+		return http.StatusInternalServerError, err
+	}
+
+	invalidateTagMappings()
+	return
+}
+
+func GetTagMappings() (res datastruct.TagMappingResponse, statusCode int, err error) {
+	db := Database()
+	statusCode = http.StatusOK
+
+	mappings, err := loadTagMappings(db)
+	if err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+
+	var faceShapes []datastruct.FaceShape
+	if err = db.Order("id").Find(&faceShapes).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+	for _, v := range faceShapes {
+		res.FaceShapes = append(res.FaceShapes, datastruct.TagMapping{
+			ID:     v.ID,
+			Name:   v.Name,
+			TagIDs: nonNilTagIDs(mappings.faceShapeTags[v.ID]),
+		})
+	}
+
+	var traits []datastruct.PersonalityTrait
+	if err = db.Order("id").Find(&traits).Error; err != nil {
+		return res, http.StatusInternalServerError, err
+	}
+	for _, v := range traits {
+		res.Personalities = append(res.Personalities, datastruct.TagMapping{
+			ID:     v.ID,
+			Name:   v.Name,
+			TagIDs: nonNilTagIDs(mappings.personalityTags[v.Code]),
+		})
+	}
+
+	return
+}
+
+// UpdateFaceShapeTags replaces the tags recommended for a face shape.
+func UpdateFaceShapeTags(faceShapeID uint64, data datastruct.TagMappingInput) (statusCode int, err error) {
+	db := Database()
+
+	var faceShape datastruct.FaceShape
+	if err = db.Where("id = ?", faceShapeID).First(&faceShape).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return http.StatusNotFound, errors.New("face shape not found")
+		}
+		return http.StatusInternalServerError, err
+	}
+
+	return replaceTagMapping(db, data.TagIDs, func(tx *gorm.DB, tagIDs []uint64, currentTime time.Time) error {
+		if err := tx.Where("face_shape_id = ?", faceShapeID).Delete(&datastruct.DetailFaceShapeTag{}).Error; err != nil {
+			return err
+		}
+
+		var payload []datastruct.DetailFaceShapeTag
+		for _, tagID := range tagIDs {
+			payload = append(payload, datastruct.DetailFaceShapeTag{
+				FaceShapeID: faceShapeID,
+				TagID:       tagID,
+				CreatedAt:   currentTime,
+				UpdatedAt:   currentTime,
+			})
+		}
+		if len(payload) == 0 {
+			return nil
+		}
+
+		return tx.Create(&payload).Error
+	})
+}
+
+// UpdatePersonalityTags replaces the tags recommended for a personality trait.
+func UpdatePersonalityTags(traitID uint64, data datastruct.TagMappingInput) (statusCode int, err error) {
+	db := Database()
+
+	var trait datastruct.PersonalityTrait
+	if err = db.Where("id = ?", traitID).First(&trait).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return http.StatusNotFound, errors.New("personality trait not found")
+		}
+		return http.StatusInternalServerError, err
+	}
+
+	return replaceTagMapping(db, data.TagIDs, func(tx *gorm.DB, tagIDs []uint64, currentTime time.Time) error {
+		if err := tx.Where("personality_trait_id = ?", traitID).Delete(&datastruct.DetailPersonalityTag{}).Error; err != nil {
+			return err
+		}
+
+		var payload []datastruct.DetailPersonalityTag
+		for _, tagID := range tagIDs {
+			payload = append(payload, datastruct.DetailPersonalityTag{
+				PersonalityTraitID: traitID,
+				TagID:              tagID,
+				CreatedAt:          currentTime,
+				UpdatedAt:          currentTime,
+			})
+		}
+		if len(payload) == 0 {
+			return nil
+		}
+
+		return tx.Create(&payload).Error
+	})
+}
+
+// replaceTagMapping checks the tags exist and runs the replacement in a transaction.
+func replaceTagMapping(db *gorm.DB, tagIDs []uint64, replace func(tx *gorm.DB, tagIDs []uint64, currentTime time.Time) error) (statusCode int, err error) {
+	statusCode = http.StatusOK
+
+	unique := make(map[uint64]bool, len(tagIDs))
+	var ids []uint64
+	for _, v := range tagIDs {
+		if v == 0 || unique[v] {
+			continue
+		}
+		unique[v] = true
+		ids = append(ids, v)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	if len(ids) > 0 {
+		var found int64
+		if err = db.Model(&datastruct.Tag{}).Where("id IN (?)", ids).Count(&found).Error; err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if int(found) != len(ids) {
+			return http.StatusBadRequest, errors.New("some tags do not exist")
+		}
+	}
+
+	// Begin a transaction
+	tx := db.Begin()
+
+	// Defer the rollback function in case of error
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = replace(tx, ids, time.Now()); err != nil {
+		tx.Rollback()
+		return http.StatusInternalServerError, err
+	}
+
+	// Commit the transaction if all queries succeed
+	if err = tx.Commit().Error; err != nil {
+		tx.Rollback()
+		log.Println("Error committing transaction:", err)
+		return http.StatusInternalServerError, err
+	}
+
+	invalidateTagMappings()
+	return
+}
+
+func validateTagInput(db *gorm.DB, data datastruct.TagInput, tagID uint64) (statusCode int, err error) {
+	statusCode = http.StatusOK
+
+	name := strings.TrimSpace(data.Name)
+	if name == "" {
+		return http.StatusBadRequest, errors.New("name cannot be empty")
+	}
+
+	var category datastruct.Category
+	if err = db.Where("id = ?", data.CategoryID).First(&category).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return http.StatusBadRequest, errors.New("category not found")
+		}
+		return http.StatusInternalServerError, err
+	}
+
+	var duplicate int64
+	if err = db.Model(&datastruct.Tag{}).
+		Where("category_id = ? AND LOWER(name) = LOWER(?) AND id != ?", data.CategoryID, name, tagID).
+		Count(&duplicate).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if duplicate > 0 {
+		return http.StatusConflict, errors.New("tag already exists in this category")
+	}
+
+	return
+}
+
+func nonNilTagIDs(tagIDs []uint64) []uint64 {
+	if tagIDs == nil {
+		return []uint64{}
+	}
+
+	return tagIDs
+}
+
+func capitalize(text string) string {
+	if text == "" {
+		return text
+	}
+
+	return strings.ToUpper(text[:1]) + text[1:]
+}
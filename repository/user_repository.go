@@ -38,6 +38,16 @@ func GetUserByID(id uint64) (res datastruct.UserDetailResponse, statusCode int,
 		}
 
 		userDetail.FaceShape = &faceShape.Name
+
+		var activeScan datastruct.FaceScan
+		if err := db.Where("user_id = ? AND is_active = 1", userDetail.ID).
+			Order("id DESC").
+			Limit(1).
+			Find(&activeScan).
+			Error; err != nil {
+			return res, http.StatusInternalServerError, err
+		}
+		userDetail.FaceShapeModelVersion = activeScan.ModelVersion
 	}
 
 	var personality datastruct.PersonalityPercentagesUser
@@ -45,6 +55,8 @@ func GetUserByID(id uint64) (res datastruct.UserDetailResponse, statusCode int,
 		if err := db.Table("user_personalities").
 			Select("*").
 			Where("user_id = ? AND is_active = 1", userDetail.ID).
+			Order("id DESC").
+			Limit(1).
 			Find(&personality).
 			Error; err != nil {
 			return res, http.StatusNotFound, err
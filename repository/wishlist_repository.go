@@ -5,7 +5,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/yusufwib/arvigo-backend/constant"
 	"github.com/yusufwib/arvigo-backend/datastruct"
 )
 
@@ -22,6 +21,19 @@ func AddWhislistProduct(userID uint64, data datastruct.AddWhislistProductInput)
 		UpdatedAt:                  currentTime,
 	}
 
+	// remember the listing price so a later drop can be announced
+	if data.DetailProductMarketplaceID != nil {
+		var savedPrice float64
+		if err = db.Table("detail_product_marketplaces dpm").
+			Select("p.price").
+			Joins("JOIN products p ON p.id = dpm.product_id").
+			Where("dpm.id = ?", data.DetailProductMarketplaceID).
+			Scan(&savedPrice).Error; err != nil {
+			return http.StatusInternalServerError, err
+		}
+		wishlistPayload.SavedPrice = &savedPrice
+	}
+
 	if err = db.Create(&wishlistPayload).Error; err != nil {
 		return http.StatusInternalServerError, err
 	}
@@ -120,8 +132,11 @@ func GetUserWishlist(userID uint64) (res datastruct.UserWishlistResponse, status
 
 		if v.MarketplaceID != 0 {
 			merchantProduct[i].Type = "online"
-			marketplaceName := constant.Marketplace[v.MarketplaceID]
-			merchantProduct[i].Marketplace = &marketplaceName
+			if marketplace, ok := GetMarketplaceByID(v.MarketplaceID); ok {
+				merchantProduct[i].Marketplace = &marketplace.Name
+				merchantProduct[i].MarketplaceLogo = &marketplace.Image
+				merchantProduct[i].DeepLink = MarketplaceDeepLink(marketplace, merchantProduct[i].MarketplaceLink)
+			}
 		}
 	}
 
@@ -20,4 +20,9 @@ func RegisterAllRoutes(e *echo.Echo) {
 	handler.RegisterMerchantRoutes(e)
 	handler.RegisterSubscriptionRoutes(e)
 	handler.RegisterCronJobRoutes(e)
+	handler.RegisterNotificationRoutes(e)
+	handler.RegisterTagRoutes(e)
+	handler.RegisterMarketplaceRoutes(e)
+	handler.RegisterFeedbackRoutes(e)
+	handler.RegisterMLModelRoutes(e)
 }
@@ -8,3 +8,26 @@ func InArray(needle interface{}, haystack []interface{}) bool {
 	}
 	return false
 }
+
+func InArrayString(needle string, haystack []string) bool {
+	for _, value := range haystack {
+		if value == needle {
+			return true
+		}
+	}
+	return false
+}
+
+func RemoveDuplicatesUint64(values []uint64) []uint64 {
+	encountered := map[uint64]bool{}
+	result := []uint64{}
+
+	for _, v := range values {
+		if !encountered[v] {
+			encountered[v] = true
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
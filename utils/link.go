@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ResolveRedirects follows the redirect chain of a short link, up to maxHops,
+// and returns the URL it finally lands on. checkHop is called with every URL
+// before it is fetched: returning done ends the chain there, an error aborts it.
+// Hosts resolving to private, loopback or link-local addresses are never fetched.
+func ResolveRedirects(link string, maxHops int, timeout time.Duration, checkHop func(hop *url.URL) (done bool, err error)) (string, error) {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: rejectNonPublicAddress,
+	}
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	current, err := url.Parse(link)
+	if err != nil {
+		return "", err
+	}
+
+	for hop := 0; hop < maxHops; hop++ {
+		if current.Scheme != "http" && current.Scheme != "https" {
+			return "", fmt.Errorf("unsupported redirect scheme %q", current.Scheme)
+		}
+		done, err := checkHop(current)
+		if err != nil {
+			return "", err
+		}
+		if done {
+			return current.String(), nil
+		}
+
+		response, err := client.Get(current.String())
+		if err != nil {
+			return "", err
+		}
+		response.Body.Close()
+
+		if response.StatusCode < 300 || response.StatusCode >= 400 {
+			return current.String(), nil
+		}
+
+		location := response.Header.Get("Location")
+		if location == "" {
+			return "", fmt.Errorf("redirect without location from %s", current.Host)
+		}
+		if current, err = current.Parse(location); err != nil {
+			return "", err
+		}
+	}
+
+	return "", errors.New("too many redirects")
+}
+
+// carrierGradeNAT is the shared address space of RFC 6598, not covered by net.IP.IsPrivate.
+var carrierGradeNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// rejectNonPublicAddress runs after DNS resolution, so a public name pointing
+// at an internal address is refused as well.
+func rejectNonPublicAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() ||
+		carrierGradeNAT.Contains(ip) {
+		return fmt.Errorf("address %s is not public", host)
+	}
+
+	return nil
+}
+
+// StripQueryParams removes the given query parameters, and every parameter
+// starting with one of the prefixes, from the URL.
+func StripQueryParams(u *url.URL, params, prefixes []string) {
+	query := u.Query()
+	for key := range query {
+		for _, param := range params {
+			if strings.EqualFold(key, param) {
+				query.Del(key)
+			}
+		}
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(strings.ToLower(key), prefix) {
+				query.Del(key)
+			}
+		}
+	}
+	u.RawQuery = query.Encode()
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NormalizeName lowercases the text and keeps only letters and digits,
+// separated by single spaces.
+func NormalizeName(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// NameSimilarity scores two product names from 0 to 1, taking the better of
+// the edit distance ratio and the shared word ratio so both typos and
+// reordered or extra words are tolerated.
+func NameSimilarity(a, b string) float64 {
+	a, b = NormalizeName(a), NormalizeName(b)
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	editRatio := 1 - float64(levenshtein(ra, rb))/float64(longest)
+
+	wordsA, wordsB := make(map[string]bool), make(map[string]bool)
+	for _, v := range strings.Fields(a) {
+		wordsA[v] = true
+	}
+	for _, v := range strings.Fields(b) {
+		wordsB[v] = true
+	}
+	shared := 0
+	for v := range wordsA {
+		if wordsB[v] {
+			shared++
+		}
+	}
+	wordRatio := float64(shared) / float64(len(wordsA)+len(wordsB)-shared)
+
+	if wordRatio > editRatio {
+		return wordRatio
+	}
+	return editRatio
+}
+
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j] + 1
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+			if previous[j-1]+cost < current[j] {
+				current[j] = previous[j-1] + cost
+			}
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}